@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hytonhan/certwatch/internal/app"
+	"github.com/hytonhan/certwatch/internal/audit"
+	"github.com/hytonhan/certwatch/internal/auth"
+	"github.com/hytonhan/certwatch/internal/config"
+	"github.com/hytonhan/certwatch/internal/http/handler"
+	"github.com/hytonhan/certwatch/internal/repository"
+	"github.com/hytonhan/certwatch/internal/service"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+// cliActor is recorded in the audit trail for changes made by commands.
+const cliActor = "system:cli"
+
+const usage = `usage: certwatch                                                       run the server
+       certwatch audit verify                                          walk the audit hash chain
+       certwatch apikey create -name NAME -scopes SCOPES [-team TEAM]  create an API key and print its token
+       certwatch apikey list                                           list API keys
+       certwatch apikey revoke ID                                      revoke an API key`
+
+var errUsage = errors.New("usage")
+
+// runCommand runs a command against the database in conf and returns the
+// exit code: 0 on success, 1 if the command ran but reported a failure,
+// and 2 if it could not run.
+func runCommand(ctx context.Context, conf config.Config, args []string) int {
+	ctx = auth.WithPrincipal(ctx, auth.System("cli"))
+	ctx = audit.WithActor(ctx, cliActor)
+	code, err := dispatch(ctx, conf, args, os.Stdout)
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", strings.Join(args[:min(len(args), 2)], " "), err)
+		return 2
+	}
+	return code
+}
+
+func dispatch(ctx context.Context, conf config.Config, args []string, out io.Writer) (int, error) {
+	if len(args) < 2 {
+		return 0, errUsage
+	}
+	switch args[0] + " " + args[1] {
+	case "audit verify":
+		if len(args) != 2 {
+			return 0, errUsage
+		}
+		return verifyAudit(ctx, conf, out)
+	case "apikey create":
+		return createAPIKey(ctx, conf, args[2:], out)
+	case "apikey list":
+		if len(args) != 2 {
+			return 0, errUsage
+		}
+		return listAPIKeys(ctx, conf, out)
+	case "apikey revoke":
+		if len(args) != 3 {
+			return 0, errUsage
+		}
+		return revokeAPIKey(ctx, conf, args[2])
+	}
+	return 0, errUsage
+}
+
+// verifyAudit prints the verification and exits with 1 for a broken chain.
+func verifyAudit(ctx context.Context, conf config.Config, out io.Writer) (int, error) {
+	db, err := app.OpenDatabase(ctx, conf)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	result, err := service.NewAuditService(repository.NewAuditRepository(db)).Verify(ctx)
+	if err != nil {
+		return 0, err
+	}
+	printJSON(out, handler.NewAuditVerificationResponse(result))
+	if !result.Valid {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// createAPIKey is how the first admin key is made, before there is any key
+// to call the API with.
+func createAPIKey(ctx context.Context, conf config.Config, args []string, out io.Writer) (int, error) {
+	flags := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	name := flags.String("name", "", "")
+	scopes := flags.String("scopes", "", "")
+	team := flags.String("team", "", "")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 || *name == "" || *scopes == "" {
+		return 0, errUsage
+	}
+
+	db, err := app.OpenDatabase(ctx, conf)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	created, err := service.NewAPIKeyService(repository.NewAPIKeyRepository(db)).Create(ctx, dto.CreateAPIKeyInput{
+		Name:   *name,
+		Scopes: strings.Split(*scopes, ","),
+		Team:   *team,
+	})
+	if err != nil {
+		return 0, err
+	}
+	printJSON(out, handler.CreatedAPIKeyResponse{APIKeyResponse: handler.NewAPIKeyResponse(created.Key), Token: created.Token})
+	return 0, nil
+}
+
+func listAPIKeys(ctx context.Context, conf config.Config, out io.Writer) (int, error) {
+	db, err := app.OpenDatabase(ctx, conf)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	keys, err := service.NewAPIKeyService(repository.NewAPIKeyRepository(db)).List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	response := make([]handler.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, handler.NewAPIKeyResponse(key))
+	}
+	printJSON(out, response)
+	return 0, nil
+}
+
+func revokeAPIKey(ctx context.Context, conf config.Config, id string) (int, error) {
+	db, err := app.OpenDatabase(ctx, conf)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	if err := service.NewAPIKeyService(repository.NewAPIKeyRepository(db)).Revoke(ctx, id); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+func printJSON(out io.Writer, value any) {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
@@ -24,6 +24,12 @@ func main() {
 	logger := audit.NewLogger()
 	conf := config.New()
 
+	if len(os.Args) > 1 {
+		code := runCommand(ctx, conf, os.Args[1:])
+		stop()
+		os.Exit(code)
+	}
+
 	app, aerr := app.New(conf)
 	if aerr != nil {
 		logger.Warn("error occured")
@@ -2,24 +2,41 @@ package app
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/hytonhan/certwatch/internal/audit"
+	"github.com/hytonhan/certwatch/internal/auth"
 	"github.com/hytonhan/certwatch/internal/config"
 	"github.com/hytonhan/certwatch/internal/db"
 	"github.com/hytonhan/certwatch/internal/http/handler"
+	"github.com/hytonhan/certwatch/internal/lint"
+	"github.com/hytonhan/certwatch/internal/metrics"
+	"github.com/hytonhan/certwatch/internal/middleware"
 	"github.com/hytonhan/certwatch/internal/monitor"
+	"github.com/hytonhan/certwatch/internal/notify"
 	"github.com/hytonhan/certwatch/internal/repository"
+	"github.com/hytonhan/certwatch/internal/scanner"
 	"github.com/hytonhan/certwatch/internal/service"
+	"github.com/hytonhan/certwatch/internal/tlsserver"
+	"github.com/hytonhan/certwatch/internal/tracing"
 )
 
 type App struct {
 	Config config.Config
 	DB     *sql.DB
 	Server *http.Server
+	// workers run in the background for the lifetime of Run's context.
+	workers []func(ctx context.Context)
+	// shutdownTracing flushes the spans not exported yet.
+	shutdownTracing func(ctx context.Context) error
 }
 
 func New(cfg config.Config) (*App, error) {
@@ -34,39 +51,250 @@ func New(cfg config.Config) (*App, error) {
 	}
 	//defer sqlDB.Close()
 
-	if err := db.RunMigrations(ctx, sqlDB, "migrations/001_init.sql"); err != nil {
+	if err := db.RunMigrations(ctx, sqlDB); err != nil {
 		logger.Error("Migration failed", "error", err)
 		log.Fatal(err)
 	}
 
 	logger.Info("Database initialized")
 
+	auditRepo := repository.NewAuditRepository(sqlDB)
+	sealed, err := auditRepo.Seal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if sealed > 0 {
+		logger.Info("Sealed audit events recorded before hash chaining", "count", sealed)
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Endpoint:    cfg.OTLPEndpoint,
+		ServiceName: cfg.ServiceName,
+		SampleRatio: cfg.TraceSampleRatio,
+
+		TrustParentSampling: cfg.TraceTrustParent,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT: %w", err)
+	}
+
+	linter, err := lint.Load(cfg.LintRulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("LINT_RULES_FILE: %w", err)
+	}
+
 	repo := repository.NewCertificateRepository(sqlDB)
-	certSrv := service.New(repo)
+	findingSrv := service.NewFindingService(repository.NewFindingRepository(sqlDB), repo, linter)
+	certSrv := service.Traced(service.New(repo, service.WithFindings(findingSrv), service.WithAudit(auditRepo), service.WithExpiredLookback(cfg.ExpiredLookback)))
+	endpointSrv := service.NewEndpointService(repository.NewEndpointRepository(sqlDB), auditRepo)
+	alertSrv := service.NewAlertService(repository.NewAlertRepository(sqlDB), auditRepo, cfg.AlertRenotify)
+	notificationSrv := service.NewNotificationService(repository.NewNotificationRepository(sqlDB))
+	auditSrv := service.NewAuditService(auditRepo)
+	apiKeySrv := service.NewAPIKeyService(repository.NewAPIKeyRepository(sqlDB))
 
-	handler := handler.NewCertificateHandler(certSrv, logger)
+	notifiers := []notify.Notifier{}
+	if len(cfg.WebhookURLs) > 0 {
+		if cfg.WebhookSecret == "" {
+			return nil, errors.New("WEBHOOK_SECRET is required when WEBHOOK_URLS is set")
+		}
+		notifiers = append(notifiers, notify.NewWebhookNotifier(notify.WebhookConfig{
+			URLs:        cfg.WebhookURLs,
+			Secret:      cfg.WebhookSecret,
+			Timeout:     cfg.WebhookTimeout,
+			MaxAttempts: cfg.WebhookMaxAttempts,
+			Backoff:     time.Second,
+		}, notificationSrv, logger))
+	}
+	var emailNotifier *notify.EmailNotifier
+	if cfg.SMTPHost != "" {
+		mode := notify.EmailMode(cfg.SMTPMode)
+		if mode != notify.EmailImmediate && mode != notify.EmailDigest {
+			return nil, errors.New("SMTP_MODE must be immediate or digest")
+		}
+		if cfg.SMTPFrom == "" || len(cfg.SMTPTo) == 0 {
+			return nil, errors.New("SMTP_FROM and SMTP_TO are required when SMTP_HOST is set")
+		}
+		emailNotifier = notify.NewEmailNotifier(notify.EmailConfig{
+			Host:           cfg.SMTPHost,
+			Port:           cfg.SMTPPort,
+			Username:       cfg.SMTPUsername,
+			Password:       cfg.SMTPPassword,
+			From:           cfg.SMTPFrom,
+			To:             cfg.SMTPTo,
+			NotifyOwners:   cfg.SMTPNotifyOwners,
+			Mode:           mode,
+			RequireTLS:     cfg.SMTPRequireTLS,
+			DigestInterval: cfg.SMTPDigestInterval,
+			Timeout:        cfg.SMTPTimeout,
+		}, notificationSrv, notificationSrv, logger)
+		notifiers = append(notifiers, emailNotifier)
+	}
+
+	authenticators := []middleware.Authenticator{apiKeySrv}
+	if cfg.OIDCIssuer != "" {
+		if cfg.OIDCAudience == "" {
+			return nil, errors.New("OIDC_AUDIENCE is required when OIDC_ISSUER is set")
+		}
+		roleScopes := auth.DefaultRoleScopes
+		if cfg.OIDCRoleScopes != "" {
+			roleScopes, err = auth.ParseRoleScopes(cfg.OIDCRoleScopes)
+			if err != nil {
+				return nil, fmt.Errorf("OIDC_ROLE_SCOPES: %w", err)
+			}
+		}
+		keys := auth.NewKeySet(cfg.OIDCIssuer, cfg.OIDCJWKSURL, &http.Client{Timeout: 10 * time.Second}, cfg.OIDCJWKSCacheTTL)
+		authenticators = append(authenticators, auth.NewOIDCAuthenticator(auth.OIDCConfig{
+			Issuer:     cfg.OIDCIssuer,
+			Audience:   cfg.OIDCAudience,
+			RolesClaim: cfg.OIDCRolesClaim,
+			TeamClaim:  cfg.OIDCTeamClaim,
+			RoleScopes: roleScopes,
+			Leeway:     cfg.OIDCLeeway,
+		}, keys))
+	}
+
+	var tlsConfig *tls.Config
+	var reloader *tlsserver.Reloader
+	var clientCerts middleware.CertificateAuthenticator
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		}
+		reloader, err = tlsserver.NewReloader(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSReloadInterval, certSrv, logger)
+		if err != nil {
+			return nil, fmt.Errorf("TLS_CERT_FILE: %w", err)
+		}
+		var clientCAs *x509.CertPool
+		clientAuth := tlsserver.ClientAuth(cfg.TLSClientAuth)
+		if cfg.TLSClientCAFile != "" {
+			if clientAuth != tlsserver.ClientAuthOptional && clientAuth != tlsserver.ClientAuthRequire {
+				return nil, errors.New("TLS_CLIENT_AUTH must be optional or require")
+			}
+			clientCAs, err = tlsserver.LoadCertPool(cfg.TLSClientCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("TLS_CLIENT_CA_FILE: %w", err)
+			}
+			identities, err := auth.ParseClientIdentities(cfg.TLSClientIdentities)
+			if err != nil {
+				return nil, fmt.Errorf("TLS_CLIENT_IDENTITIES: %w", err)
+			}
+			clientCerts = auth.NewClientCertAuthenticator(identities)
+		}
+		tlsConfig = tlsserver.Config(reloader, clientCAs, clientAuth)
+	} else if cfg.TLSClientCAFile != "" {
+		return nil, errors.New("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+
+	limits := &middleware.RateLimits{}
+	if cfg.ReadRateLimit > 0 {
+		limits.Reads = middleware.NewTokenBucketLimiter(cfg.ReadRateLimit, cfg.ReadBurst)
+	}
+	if cfg.WriteRateLimit > 0 {
+		limits.Writes = middleware.NewTokenBucketLimiter(cfg.WriteRateLimit, cfg.WriteBurst)
+	}
+	if cfg.AuthFailureRate > 0 {
+		limits.AuthFailures = middleware.NewTokenBucketLimiter(cfg.AuthFailureRate, cfg.AuthFailureBurst)
+	}
+
+	httpMetrics := middleware.NewHTTPMetrics()
+	monitorMetrics := monitor.NewMetrics()
+	registry := metrics.NewRegistry()
+	registry.Register(
+		metrics.NewInventoryCollector(certSrv),
+		httpMetrics.Requests,
+		httpMetrics.Duration,
+		monitorMetrics.RunDuration,
+		monitorMetrics.RunFailures,
+		monitorMetrics.Notifications,
+		limits,
+	)
+
+	router := handler.NewRouter(logger, authenticators, clientCerts, auditRepo, limits, httpMetrics,
+		handler.NewCertificateHandler(certSrv, logger),
+		handler.NewEndpointHandler(endpointSrv, logger),
+		handler.NewAlertHandler(alertSrv, logger),
+		handler.NewNotificationHandler(notificationSrv, logger),
+		handler.NewFindingHandler(findingSrv, logger),
+		handler.NewAuditHandler(auditSrv, logger),
+		handler.NewAPIKeyHandler(apiKeySrv, logger),
+		handler.NewRateLimitHandler(limits, logger),
+		handler.NewMetricsHandler(registry, logger),
+	)
 
 	srv := &http.Server{
 		Addr:              ":" + cfg.HTTPPort,
-		Handler:           handler.Router(),
+		Handler:           router,
 		ReadTimeout:       5 * time.Second,
 		ReadHeaderTimeout: 2 * time.Second,
 		WriteTimeout:      10 * time.Second,
 		IdleTimeout:       60 * time.Second,
 		MaxHeaderBytes:    1 << 20,
+		TLSConfig:         tlsConfig,
+	}
+
+	lintMonitor := monitor.NewLintMonitor(findingSrv, cfg.LintInterval, logger)
+	rules, err := monitor.ParseRules(cfg.ExpiryRules, monitor.NewThresholds(cfg.ExpiryThresholds))
+	if err != nil {
+		return nil, fmt.Errorf("EXPIRY_RULES: %w", err)
+	}
+	monitor := monitor.NewMonitor(certSrv, alertSrv, cfg.ExpiryCheckInterval, rules, notifiers, logger, monitor.WithMetrics(monitorMetrics))
+	workers := []func(ctx context.Context){monitor.Start, lintMonitor.Start}
+	if emailNotifier != nil {
+		workers = append(workers, emailNotifier.Run)
 	}
 
-	monitor := monitor.NewMonitor(certSrv, cfg.ExpiryCheckInterval, cfg.ExpiryWindow, logger)
-	go monitor.Start(ctx)
+	scanner := scanner.NewScanner(endpointSrv, certSrv, cfg.ScanInterval, cfg.ScanTimeout, logger)
+	workers = append(workers, scanner.Start)
+	if reloader != nil {
+		workers = append(workers, reloader.Start)
+	}
+
+	return &App{Config: cfg, DB: sqlDB, Server: srv, workers: workers, shutdownTracing: shutdownTracing}, nil
+}
 
-	return &App{Config: cfg, DB: sqlDB, Server: srv}, nil
+// OpenDatabase opens and migrates the database in cfg, for commands that
+// work on it without starting the server.
+func OpenDatabase(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+	sqlDB, err := db.NewSQLite(ctx, cfg.DBPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.RunMigrations(ctx, sqlDB); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return sqlDB, nil
 }
 
 func (a *App) Run(ctx context.Context) error {
 	defer a.DB.Close()
+	// Spans are flushed last, with those of the workers' final runs.
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		a.shutdownTracing(flushCtx)
+	}()
+
+	var wg sync.WaitGroup
+	for _, worker := range a.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker(ctx)
+		}()
+	}
+	// Workers get to finish, e.g. flush a pending email digest, before the
+	// database is closed.
+	defer wg.Wait()
+
 	go func() {
 		// logger.Info("Server starting", "addr", a.Server.Addr)
-		if err := a.Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		serve := a.Server.ListenAndServe
+		if a.Server.TLSConfig != nil {
+			// The certificate comes from TLSConfig.GetCertificate.
+			serve = func() error { return a.Server.ListenAndServeTLS("", "") }
+		}
+		if err := serve(); err != nil && err != http.ErrServerClosed {
 			// logger.Error("Server failed", "error", err)
 			log.Fatal(err)
 		}
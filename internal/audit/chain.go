@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+// GenesisHash is the previous hash of the first audit event.
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+var (
+	// ErrBrokenLink means an event does not follow the event before it:
+	// an event was removed, inserted or reordered.
+	ErrBrokenLink = errors.New("broken_link")
+	// ErrTampered means an event no longer matches its own hash.
+	ErrTampered = errors.New("tampered")
+	// ErrUnsealed means an event was recorded before events were hashed and
+	// has not been sealed yet, which the server does on startup.
+	ErrUnsealed = errors.New("unsealed")
+)
+
+// Hash returns the hex SHA-256 of the event's PrevHash and its canonical
+// content. Every stored field is covered, so that changing any of them, or
+// the event it follows, changes the hash.
+func Hash(event model.AuditEvent) string {
+	canonical, _ := json.Marshal([]any{
+		event.PrevHash,
+		event.Id,
+		event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		event.Actor,
+		event.RequestId,
+		event.Action,
+		event.EntityType,
+		event.EntityId,
+		rawOrNull(event.Before),
+		rawOrNull(event.After),
+	})
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// rawOrNull hashes the snapshots as the text that was stored, so that
+// re-encoding them cannot change the hash.
+func rawOrNull(snapshot json.RawMessage) any {
+	if snapshot == nil {
+		return nil
+	}
+	return string(snapshot)
+}
+
+// Check reports whether the event directly follows the event with hash
+// prevHash and is unchanged since it was recorded.
+func Check(prevHash string, event model.AuditEvent) error {
+	if event.Hash == "" {
+		return ErrUnsealed
+	}
+	if event.PrevHash != prevHash {
+		return ErrBrokenLink
+	}
+	if event.Hash != Hash(event) {
+		return ErrTampered
+	}
+	return nil
+}
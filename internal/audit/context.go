@@ -0,0 +1,33 @@
+package audit
+
+import "context"
+
+// SystemActor is recorded for changes made outside of a request, e.g. by a
+// background worker that did not name itself.
+const SystemActor = "system"
+
+// AnonymousActor is recorded for changes made through the API by an
+// unauthenticated caller.
+const AnonymousActor = "anonymous"
+
+type actorKey struct{}
+
+// WithActor returns a context carrying who is making the changes done with
+// it. The actor is recorded on every audit event.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFrom(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return SystemActor
+}
+
+// RequestIDFrom returns the id the logging middleware gave the request, or
+// "" outside of a request.
+func RequestIDFrom(ctx context.Context) string {
+	requestID, _ := ctx.Value("request_id").(string)
+	return requestID
+}
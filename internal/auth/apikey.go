@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// An API key token is "cw_<prefix>_<secret>". The prefix is stored in the
+// clear to find the key; only an argon2id hash of the secret is stored.
+const (
+	tokenMarker  = "cw"
+	prefixBytes  = 6
+	secretBytes  = 32
+	saltBytes    = 16
+	argonTime    = 2
+	argonMemory  = 19 * 1024
+	argonThreads = 1
+	argonKeyLen  = 32
+)
+
+var (
+	// ErrInvalidToken means the bearer token is malformed, unknown, revoked
+	// or does not match.
+	ErrInvalidToken = errors.New("invalid_token")
+	errInvalidHash  = errors.New("invalid secret hash")
+)
+
+// NewToken returns a new random token and its prefix.
+func NewToken() (token string, prefix string, err error) {
+	prefixRaw := make([]byte, prefixBytes)
+	secretRaw := make([]byte, secretBytes)
+	if _, err := rand.Read(prefixRaw); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secretRaw); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(prefixRaw)
+	return tokenMarker + "_" + prefix + "_" + base64.RawURLEncoding.EncodeToString(secretRaw), prefix, nil
+}
+
+// ParseToken splits a token into its prefix and secret.
+func ParseToken(token string) (prefix string, secret string, err error) {
+	parts := strings.SplitN(token, "_", 3)
+	if len(parts) != 3 || parts[0] != tokenMarker || len(parts[1]) != prefixBytes*2 || parts[2] == "" {
+		return "", "", ErrInvalidToken
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return "", "", ErrInvalidToken
+	}
+	return parts[1], parts[2], nil
+}
+
+// HashSecret hashes the secret with argon2id and a random salt, in the PHC
+// string format so that the parameters can be raised later.
+func HashSecret(secret string) (string, error) {
+	salt := make([]byte, saltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(secret), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifySecret reports whether secret matches a hash made by HashSecret,
+// with the parameters recorded in the hash.
+func VerifySecret(secret string, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, errInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, errInvalidHash
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, errInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, errInvalidHash
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return false, errInvalidHash
+	}
+	got := argon2.IDKey([]byte(secret), salt, time, memory, threads, uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
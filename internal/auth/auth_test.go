@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseToken(t *testing.T) {
+	token, prefix, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		token    string
+		prefix   string
+		expected error
+	}{
+		{"new token", token, prefix, nil},
+		{"underscore in secret", "cw_" + prefix + "_a_b", prefix, nil},
+		{"empty", "", "", ErrInvalidToken},
+		{"wrong marker", "xx" + token[2:], "", ErrInvalidToken},
+		{"short prefix", "cw_abc_secret", "", ErrInvalidToken},
+		{"prefix not hex", "cw_zzzzzzzzzzzz_secret", "", ErrInvalidToken},
+		{"no secret", "cw_" + prefix + "_", "", ErrInvalidToken},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed, secret, err := ParseToken(test.token)
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("ParseToken() error = %v; want %v", err, test.expected)
+			}
+			if err == nil && (parsed != test.prefix || !strings.HasSuffix(test.token, "_"+secret)) {
+				t.Errorf("ParseToken() = %q, %q; want prefix %q", parsed, secret, test.prefix)
+			}
+		})
+	}
+}
+
+func TestVerifySecret(t *testing.T) {
+	hash, err := HashSecret("correct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$") {
+		t.Errorf("HashSecret() = %q; want argon2id PHC string", hash)
+	}
+	if other, _ := HashSecret("correct"); other == hash {
+		t.Errorf("HashSecret() reused its salt")
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		hash    string
+		ok      bool
+		wantErr bool
+	}{
+		{"match", "correct", hash, true, false},
+		{"mismatch", "wrong", hash, false, false},
+		{"not argon2id", "correct", "$2a$10$abcdefghijklmnopqrstuv", false, true},
+		{"bad parameters", "correct", strings.Replace(hash, "m=", "x=", 1), false, true},
+		{"empty", "correct", "", false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := VerifySecret(test.secret, test.hash)
+			if ok != test.ok || (err != nil) != test.wantErr {
+				t.Errorf("VerifySecret() = %v, %v; want %v, error %v", ok, err, test.ok, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	reader := &Principal{Name: "reader", Scopes: []Scope{ScopeCertsRead}}
+	admin := &Principal{Name: "admin", Scopes: []Scope{ScopeAdmin}}
+
+	if !reader.HasScope(ScopeCertsRead) || reader.HasScope(ScopeCertsWrite) || reader.HasScope(ScopeAdmin) {
+		t.Errorf("HasScope() of %v is wrong", reader.Scopes)
+	}
+	for _, scope := range Scopes {
+		if !admin.HasScope(scope) {
+			t.Errorf("HasScope(%v) of admin = false; want true", scope)
+		}
+	}
+}
+
+func TestParseClientIdentities(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		count int
+		fails bool
+	}{
+		{"empty", "", 0, false},
+		{"roles", "deployer=editor@payments; ops-console=admin;dashboard=viewer", 3, false},
+		{"unknown role", "deployer=root", 0, true},
+		{"editor without team", "deployer=editor", 0, true},
+		{"no role", "deployer", 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			identities, err := ParseClientIdentities(test.input)
+			if (err != nil) != test.fails {
+				t.Fatalf("ParseClientIdentities() error = %v; want failure %v", err, test.fails)
+			}
+			if len(identities) != test.count {
+				t.Errorf("ParseClientIdentities() = %v; want %d identities", identities, test.count)
+			}
+		})
+	}
+}
+
+func TestAuthenticateCertificate(t *testing.T) {
+	authenticator := NewClientCertAuthenticator(map[string]ClientIdentity{
+		"deployer": {Role: RoleEditor, Team: "payments"},
+	})
+	ctx := context.Background()
+
+	principal, err := authenticator.AuthenticateCertificate(ctx, &x509.Certificate{Subject: pkix.Name{CommonName: "deployer"}})
+	if err != nil {
+		t.Fatalf("AuthenticateCertificate(deployer) error = %v", err)
+	}
+	if principal.Actor() != "mtls:deployer" || principal.Role() != RoleEditor || principal.Team != "payments" {
+		t.Errorf("AuthenticateCertificate(deployer) = %+v; want mtls:deployer, an editor of payments", principal)
+	}
+
+	principal, err = authenticator.AuthenticateCertificate(ctx, &x509.Certificate{Subject: pkix.Name{CommonName: "stranger"}})
+	if err != nil || len(principal.Scopes) != 0 {
+		t.Errorf("AuthenticateCertificate(stranger) = %+v, %v; want no scopes", principal, err)
+	}
+
+	if _, err := authenticator.AuthenticateCertificate(ctx, &x509.Certificate{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("AuthenticateCertificate(no name) error = %v; want %v", err, ErrInvalidToken)
+	}
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// KindClientCert is the kind of principals authenticated by a TLS client
+// certificate.
+const KindClientCert = "mtls"
+
+// ClientIdentity is what a client certificate is granted.
+type ClientIdentity struct {
+	Role Role
+	Team string
+}
+
+// ClientCertAuthenticator authenticates callers by a client certificate the
+// TLS handshake has already verified against the client CA bundle. The
+// certificate's common name is the caller's name and selects its identity.
+type ClientCertAuthenticator struct {
+	identities map[string]ClientIdentity
+}
+
+func NewClientCertAuthenticator(identities map[string]ClientIdentity) *ClientCertAuthenticator {
+	return &ClientCertAuthenticator{identities: identities}
+}
+
+// AuthenticateCertificate returns the principal of a verified client
+// certificate. A common name without an identity is authenticated but
+// granted nothing.
+func (ca *ClientCertAuthenticator) AuthenticateCertificate(ctx context.Context, cert *x509.Certificate) (*Principal, error) {
+	name := cert.Subject.CommonName
+	if name == "" {
+		return nil, fmt.Errorf("%w: client certificate has no common name", ErrInvalidToken)
+	}
+	principal := &Principal{Id: cert.Subject.String(), Name: name, Kind: KindClientCert}
+	if identity, ok := ca.identities[name]; ok {
+		principal.Scopes = slices.Clone(DefaultRoleScopes[string(identity.Role)])
+		principal.Team = identity.Team
+	}
+	return principal, nil
+}
+
+// ParseClientIdentities parses the identities of client certificates, e.g.
+// "deployer=editor@payments;ops-console=admin": each common name gets a role
+// and, for editors, the team after the "@".
+func ParseClientIdentities(value string) (map[string]ClientIdentity, error) {
+	identities := map[string]ClientIdentity{}
+	for _, rule := range strings.Split(value, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		name, grant, ok := strings.Cut(rule, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("rule %q is not name=role", rule)
+		}
+		role, team, _ := strings.Cut(grant, "@")
+		identity := ClientIdentity{Role: Role(strings.TrimSpace(role)), Team: strings.TrimSpace(team)}
+		if _, ok := DefaultRoleScopes[string(identity.Role)]; !ok {
+			return nil, fmt.Errorf("unknown role %q for %q", identity.Role, name)
+		}
+		if identity.Role == RoleEditor && identity.Team == "" {
+			return nil, fmt.Errorf("editor %q has no team", name)
+		}
+		identities[name] = identity
+	}
+	return identities, nil
+}
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minRefreshInterval limits how often an unknown key id can make the key
+	// set be fetched again, so that forged tokens cannot hammer the issuer.
+	minRefreshInterval = time.Minute
+	maxJWKSBytes       = 1 << 20
+)
+
+var errUnknownKey = errors.New("unknown signing key")
+
+// KeySet is the JSON Web Key Set of an issuer. Keys are fetched on first use
+// and cached for the TTL. A token signed with a key the cache does not know
+// fetches the set again, which picks up rotated keys. When fetching fails the
+// cached keys are kept.
+type KeySet struct {
+	issuer  string
+	url     string
+	client  *http.Client
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+	// attempted is when the set was last fetched, successfully or not.
+	attempted time.Time
+}
+
+// NewKeySet returns the key set at url. An empty url is discovered from the
+// issuer's OpenID configuration.
+func NewKeySet(issuer string, url string, client *http.Client, ttl time.Duration) *KeySet {
+	return &KeySet{issuer: issuer, url: url, client: client, ttl: ttl, now: time.Now}
+}
+
+// Key returns the verification key with the id kid. An empty kid matches the
+// only key of a set with one key.
+func (ks *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	now := ks.now()
+	key, found := ks.lookup(kid)
+	stale := now.Sub(ks.fetched) >= ks.ttl
+	if (stale || !found) && now.Sub(ks.attempted) >= minRefreshInterval {
+		ks.attempted = now
+		if err := ks.refresh(ctx); err != nil {
+			if !found {
+				return nil, err
+			}
+			return key, nil
+		}
+		ks.fetched = now
+		key, found = ks.lookup(kid)
+	}
+	if !found {
+		return nil, errUnknownKey
+	}
+	return key, nil
+}
+
+func (ks *KeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+func (ks *KeySet) refresh(ctx context.Context) error {
+	if ks.url == "" {
+		url, err := ks.discover(ctx)
+		if err != nil {
+			return err
+		}
+		ks.url = url
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := ks.getJSON(ctx, ks.url, &set); err != nil {
+		return fmt.Errorf("Fetching JWKS: %w", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// One key of an unsupported type must not take down the others.
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	ks.keys = keys
+	return nil
+}
+
+// discover reads the JWKS URL from the issuer's OpenID configuration.
+func (ks *KeySet) discover(ctx context.Context) (string, error) {
+	var config struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	err := ks.getJSON(ctx, strings.TrimSuffix(ks.issuer, "/")+"/.well-known/openid-configuration", &config)
+	if err != nil {
+		return "", fmt.Errorf("Discovering JWKS: %w", err)
+	}
+	if config.Issuer != ks.issuer || config.JWKSURI == "" {
+		return "", fmt.Errorf("Discovering JWKS: configuration is for issuer %q", config.Issuer)
+	}
+	return config.JWKSURI, nil
+}
+
+func (ks *KeySet) getJSON(ctx context.Context, url string, value any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxJWKSBytes)).Decode(value)
+}
+
+// jwk is a JSON Web Key (RFC 7517) of one of the supported types.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, errors.New("RSA key too small")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if x.BitLen() > curve.Params().BitSize || y.BitLen() > curve.Params().BitSize {
+			return nil, errors.New("invalid EC point")
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		x.FillBytes(point[1 : 1+size])
+		y.FillBytes(point[1+size:])
+		// ParseUncompressedPublicKey rejects points that are not on the curve.
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,318 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// OIDCConfig configures the validation of JWTs issued by an OpenID Connect
+// provider.
+type OIDCConfig struct {
+	Issuer   string
+	Audience string
+	// RolesClaim is the claim holding the caller's roles; a dotted path such
+	// as "realm_access.roles" reaches into nested objects.
+	RolesClaim string
+	// TeamClaim is the claim holding the caller's team, a dotted path like
+	// RolesClaim.
+	TeamClaim string
+	// RoleScopes grants each role its scopes. Roles that are not listed
+	// grant nothing.
+	RoleScopes map[string][]Scope
+	// Leeway is the clock skew tolerated on exp, nbf and iat.
+	Leeway time.Duration
+}
+
+// OIDCAuthenticator authenticates callers by a JWT signed with a key of the
+// issuer's key set.
+type OIDCAuthenticator struct {
+	config OIDCConfig
+	keys   *KeySet
+	now    func() time.Time
+}
+
+func NewOIDCAuthenticator(config OIDCConfig, keys *KeySet) *OIDCAuthenticator {
+	return &OIDCAuthenticator{config: config, keys: keys, now: time.Now}
+}
+
+// signingAlgorithms are the accepted JWS algorithms. Symmetric algorithms and
+// "none" are not accepted.
+var signingAlgorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+	"EdDSA": 0,
+}
+
+// ecdsaCurveBits is the curve size each ECDSA algorithm is defined for.
+var ecdsaCurveBits = map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *json.Number    `json:"exp"`
+	NotBefore *json.Number    `json:"nbf"`
+	IssuedAt  *json.Number    `json:"iat"`
+}
+
+// Authenticate validates the token and maps its roles to scopes. Every
+// failure is ErrInvalidToken, wrapped with the reason, unless the key set
+// could not be fetched at all.
+func (oa *OIDCAuthenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a JWT", ErrInvalidToken)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	hash, ok := signingAlgorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: algorithm %q not accepted", ErrInvalidToken, header.Alg)
+	}
+	key, err := oa.keys.Key(ctx, header.Kid)
+	if err != nil {
+		if errors.Is(err, errUnknownKey) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+		}
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature encoding", ErrInvalidToken)
+	}
+	if err := verifySignature(header.Alg, hash, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	if err := oa.validate(claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	var raw map[string]any
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+
+	principal := &Principal{Id: claims.Subject, Name: claims.Subject, Kind: KindOIDC}
+	if team, ok := claimAt(raw, oa.config.TeamClaim).(string); ok {
+		principal.Team = strings.TrimSpace(team)
+	}
+	for _, role := range rolesOf(raw, oa.config.RolesClaim) {
+		for _, scope := range oa.config.RoleScopes[role] {
+			if !slices.Contains(principal.Scopes, scope) {
+				principal.Scopes = append(principal.Scopes, scope)
+			}
+		}
+	}
+	return principal, nil
+}
+
+func (oa *OIDCAuthenticator) validate(claims jwtClaims) error {
+	if claims.Issuer != oa.config.Issuer {
+		return fmt.Errorf("issuer %q", claims.Issuer)
+	}
+	if claims.Subject == "" {
+		return errors.New("no subject")
+	}
+	var audiences []string
+	var single string
+	if err := json.Unmarshal(claims.Audience, &single); err == nil {
+		audiences = []string{single}
+	} else if err := json.Unmarshal(claims.Audience, &audiences); err != nil {
+		return errors.New("no audience")
+	}
+	if !slices.Contains(audiences, oa.config.Audience) {
+		return errors.New("not for this audience")
+	}
+
+	now := oa.now()
+	expiresAt, err := numericDate(claims.ExpiresAt)
+	if err != nil || expiresAt.IsZero() {
+		return errors.New("no expiry")
+	}
+	if !now.Before(expiresAt.Add(oa.config.Leeway)) {
+		return errors.New("expired")
+	}
+	notBefore, err := numericDate(claims.NotBefore)
+	if err != nil {
+		return errors.New("invalid nbf")
+	}
+	if now.Add(oa.config.Leeway).Before(notBefore) {
+		return errors.New("not valid yet")
+	}
+	issuedAt, err := numericDate(claims.IssuedAt)
+	if err != nil {
+		return errors.New("invalid iat")
+	}
+	if now.Add(oa.config.Leeway).Before(issuedAt) {
+		return errors.New("issued in the future")
+	}
+	return nil
+}
+
+func verifySignature(alg string, hash crypto.Hash, key crypto.PublicKey, signed []byte, signature []byte) error {
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+	invalid := errors.New("invalid signature")
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			if rsa.VerifyPKCS1v15(key, hash, digest, signature) != nil {
+				return invalid
+			}
+			return nil
+		case "PS":
+			if rsa.VerifyPSS(key, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) != nil {
+				return invalid
+			}
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		bits := key.Curve.Params().BitSize
+		if ecdsaCurveBits[alg] != bits {
+			break
+		}
+		size := (bits + 7) / 8
+		if len(signature) != 2*size {
+			return invalid
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return invalid
+		}
+		return nil
+	case ed25519.PublicKey:
+		if alg != "EdDSA" {
+			break
+		}
+		if !ed25519.Verify(key, signed, signature) {
+			return invalid
+		}
+		return nil
+	}
+	return fmt.Errorf("algorithm %s does not match the key", alg)
+}
+
+func decodeSegment(segment string, value any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(value)
+}
+
+// maxNumericDate is in the year 9999, far beyond any sensible token lifetime.
+const maxNumericDate = 253402300799
+
+// numericDate converts a JWT NumericDate; a missing claim is the zero time.
+func numericDate(value *json.Number) (time.Time, error) {
+	if value == nil {
+		return time.Time{}, nil
+	}
+	seconds, err := value.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if seconds < 0 || seconds > maxNumericDate {
+		return time.Time{}, errors.New("out of range")
+	}
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*float64(time.Second))), nil
+}
+
+// rolesOf reads the roles claim at the dotted path. A string claim is split
+// on spaces, like the OAuth scope claim.
+func rolesOf(claims map[string]any, path string) []string {
+	switch value := claimAt(claims, path).(type) {
+	case string:
+		return strings.Fields(value)
+	case []any:
+		roles := []string{}
+		for _, role := range value {
+			if role, ok := role.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+		return roles
+	}
+	return nil
+}
+
+// claimAt returns the claim at the dotted path, or nil if there is none.
+func claimAt(claims map[string]any, path string) any {
+	var value any = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// ParseRoleScopes parses a role mapping such as
+// "certwatch-admins=admin;certwatch-ops=certs:read,certs:write".
+func ParseRoleScopes(value string) (map[string][]Scope, error) {
+	roleScopes := map[string][]Scope{}
+	for _, rule := range strings.Split(value, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		role, scopes, ok := strings.Cut(rule, "=")
+		role = strings.TrimSpace(role)
+		if !ok || role == "" {
+			return nil, fmt.Errorf("rule %q is not role=scopes", rule)
+		}
+		for _, scope := range strings.Split(scopes, ",") {
+			scope := Scope(strings.TrimSpace(scope))
+			if !ValidScope(scope) {
+				return nil, fmt.Errorf("unknown scope %q for role %q", scope, role)
+			}
+			roleScopes[role] = append(roleScopes[role], scope)
+		}
+	}
+	return roleScopes, nil
+}
@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testAudience = "certwatch"
+
+// testIdP is a local OpenID provider serving its configuration and key set.
+type testIdP struct {
+	server  *httptest.Server
+	mu      sync.Mutex
+	keys    map[string]crypto.Signer
+	fetches int
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	idp := &testIdP{keys: map[string]crypto.Signer{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": idp.server.URL, "jwks_uri": idp.server.URL + "/jwks"})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		idp.fetches++
+		keys := []map[string]string{}
+		for kid, signer := range idp.keys {
+			keys = append(keys, publicJWK(kid, signer.Public()))
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *testIdP) addKey(t *testing.T, kid string, signer crypto.Signer) {
+	t.Helper()
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.keys[kid] = signer
+}
+
+func publicJWK(kid string, public crypto.PublicKey) map[string]string {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "n": encode(public.N.Bytes()), "e": encode(big.NewInt(int64(public.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		point, _ := public.Bytes()
+		size := (len(point) - 1) / 2
+		return map[string]string{"kty": "EC", "kid": kid, "crv": public.Curve.Params().Name, "x": encode(point[1 : 1+size]), "y": encode(point[1+size:])}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": kid, "crv": "Ed25519", "x": encode(public)}
+	}
+	return nil
+}
+
+// sign makes a JWT. The algorithm follows the key type.
+func sign(t *testing.T, signer crypto.Signer, kid string, claims map[string]any) string {
+	t.Helper()
+	alg := "RS256"
+	switch signer.(type) {
+	case *ecdsa.PrivateKey:
+		alg = "ES256"
+	case ed25519.PrivateKey:
+		alg = "EdDSA"
+	}
+	return signWith(t, signer, alg, kid, claims)
+}
+
+func signWith(t *testing.T, signer crypto.Signer, alg string, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	var err error
+	switch signer := signer.(type) {
+	case *rsa.PrivateKey:
+		digest := crypto.SHA256.New()
+		digest.Write([]byte(signed))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, signer, crypto.SHA256, digest.Sum(nil))
+	case *ecdsa.PrivateKey:
+		digest := crypto.SHA256.New()
+		digest.Write([]byte(signed))
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, signer, digest.Sum(nil))
+		if err == nil {
+			signature = make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+		}
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(signer, []byte(signed))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newTestAuthenticator(idp *testIdP) *OIDCAuthenticator {
+	keys := NewKeySet(idp.server.URL, "", idp.server.Client(), time.Hour)
+	return NewOIDCAuthenticator(OIDCConfig{
+		Issuer:     idp.server.URL,
+		Audience:   testAudience,
+		RolesClaim: "realm_access.roles",
+		TeamClaim:  "team",
+		RoleScopes: map[string][]Scope{
+			"certwatch-admins": {ScopeAdmin},
+			"certwatch-ops":    {ScopeCertsRead, ScopeCertsWrite},
+			"certwatch-viewer": {ScopeCertsRead},
+		},
+		Leeway: time.Minute,
+	}, keys)
+}
+
+func TestOIDCAuthenticate(t *testing.T) {
+	idp := newTestIdP(t)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	idp.addKey(t, "rsa", rsaKey)
+	idp.addKey(t, "ec", ecKey)
+	idp.addKey(t, "ed", edKey)
+	authenticator := newTestAuthenticator(idp)
+
+	now := time.Now()
+	claims := func(modify func(claims map[string]any)) map[string]any {
+		claims := map[string]any{
+			"iss":          idp.server.URL,
+			"sub":          "alice",
+			"aud":          testAudience,
+			"exp":          now.Add(5 * time.Minute).Unix(),
+			"iat":          now.Unix(),
+			"realm_access": map[string]any{"roles": []string{"certwatch-ops", "unrelated"}},
+			"team":         "payments",
+		}
+		modify(claims)
+		return claims
+	}
+	valid := func(claims map[string]any) {}
+	rsaToken := sign(t, rsaKey, "rsa", claims(valid))
+	// The claims of another token with the signature of rsaToken.
+	forged := sign(t, rsaKey, "rsa", claims(func(c map[string]any) { c["sub"] = "mallory" }))
+	tampered := forged[:strings.LastIndex(forged, ".")] + rsaToken[strings.LastIndex(rsaToken, "."):]
+
+	tests := []struct {
+		name     string
+		token    string
+		scopes   []Scope
+		expected error
+	}{
+		{"rs256", rsaToken, []Scope{ScopeCertsRead, ScopeCertsWrite}, nil},
+		{"es256", sign(t, ecKey, "ec", claims(valid)), []Scope{ScopeCertsRead, ScopeCertsWrite}, nil},
+		{"eddsa", sign(t, edKey, "ed", claims(valid)), []Scope{ScopeCertsRead, ScopeCertsWrite}, nil},
+		{"audience list", sign(t, rsaKey, "rsa", claims(func(c map[string]any) { c["aud"] = []string{"other", testAudience} })), []Scope{ScopeCertsRead, ScopeCertsWrite}, nil},
+		{"admin role", sign(t, rsaKey, "rsa", claims(func(c map[string]any) {
+			c["realm_access"] = map[string]any{"roles": []string{"certwatch-admins"}}
+		})), []Scope{ScopeAdmin}, nil},
+		{"no known role", sign(t, rsaKey, "rsa", claims(func(c map[string]any) { delete(c, "realm_access") })), nil, nil},
+		{"within leeway", sign(t, rsaKey, "rsa", claims(func(c map[string]any) { c["exp"] = now.Add(-30 * time.Second).Unix() })), []Scope{ScopeCertsRead, ScopeCertsWrite}, nil},
+		{"expired", sign(t, rsaKey, "rsa", claims(func(c map[string]any) { c["exp"] = now.Add(-2 * time.Minute).Unix() })), nil, ErrInvalidToken},
+		{"no expiry", sign(t, rsaKey, "rsa", claims(func(c map[string]any) { delete(c, "exp") })), nil, ErrInvalidToken},
+		{"not yet valid", sign(t, rsaKey, "rsa", claims(func(c map[string]any) { c["nbf"] = now.Add(10 * time.Minute).Unix() })), nil, ErrInvalidToken},
+		{"wrong audience", sign(t, rsaKey, "rsa", claims(func(c map[string]any) { c["aud"] = "other" })), nil, ErrInvalidToken},
+		{"no audience", sign(t, rsaKey, "rsa", claims(func(c map[string]any) { delete(c, "aud") })), nil, ErrInvalidToken},
+		{"wrong issuer", sign(t, rsaKey, "rsa", claims(func(c map[string]any) { c["iss"] = "https://evil.example.com" })), nil, ErrInvalidToken},
+		{"no subject", sign(t, rsaKey, "rsa", claims(func(c map[string]any) { delete(c, "sub") })), nil, ErrInvalidToken},
+		{"signed by other key", sign(t, otherKey, "rsa", claims(valid)), nil, ErrInvalidToken},
+		{"algorithm mismatch", signWith(t, rsaKey, "ES256", "rsa", claims(valid)), nil, ErrInvalidToken},
+		{"alg none", signWith(t, edKey, "none", "ed", claims(valid)), nil, ErrInvalidToken},
+		{"tampered claims", tampered, nil, ErrInvalidToken},
+		{"not a jwt", "cw_0123456789ab_secret", nil, ErrInvalidToken},
+	}
+	ctx := context.Background()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(ctx, test.token)
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("Authenticate() error = %v; want %v", err, test.expected)
+			}
+			if err != nil {
+				return
+			}
+			if principal.Actor() != "oidc:alice" || principal.Team != "payments" || !slices.Equal(principal.Scopes, test.scopes) {
+				t.Errorf("Authenticate() = %+v; want oidc:alice of payments with %v", principal, test.scopes)
+			}
+		})
+	}
+	if idp.fetches != 1 {
+		t.Errorf("JWKS fetched %d times; want 1", idp.fetches)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	idp := newTestIdP(t)
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	idp.addKey(t, "old", oldKey)
+	authenticator := newTestAuthenticator(idp)
+	now := time.Now()
+	authenticator.keys.now = func() time.Time { return now }
+	claims := map[string]any{"iss": idp.server.URL, "sub": "bob", "aud": testAudience, "exp": now.Add(time.Hour).Unix()}
+	ctx := context.Background()
+
+	if _, err := authenticator.Authenticate(ctx, sign(t, oldKey, "old", claims)); err != nil {
+		t.Fatalf("Authenticate(old key) error = %v", err)
+	}
+
+	// The issuer rotates to a new key; the first token signed with it
+	// fetches the key set again.
+	idp.addKey(t, "new", newKey)
+	now = now.Add(2 * minRefreshInterval)
+	if _, err := authenticator.Authenticate(ctx, sign(t, newKey, "new", claims)); err != nil {
+		t.Fatalf("Authenticate(new key) error = %v", err)
+	}
+	if idp.fetches != 2 {
+		t.Errorf("JWKS fetched %d times; want 2", idp.fetches)
+	}
+
+	// Unknown key ids do not fetch the set again within minRefreshInterval.
+	forged, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	for range 3 {
+		if _, err := authenticator.Authenticate(ctx, sign(t, forged, "forged", claims)); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("Authenticate(unknown key) error = %v; want %v", err, ErrInvalidToken)
+		}
+	}
+	if idp.fetches != 2 {
+		t.Errorf("JWKS fetched %d times; want 2", idp.fetches)
+	}
+
+	// Cached keys outlive an unreachable issuer, even once they are stale.
+	idp.server.Close()
+	now = now.Add(2 * time.Hour)
+	if _, err := authenticator.keys.Key(ctx, "new"); err != nil {
+		t.Errorf("Key(new) with issuer down error = %v; want cached key", err)
+	}
+}
+
+func TestParseRoleScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string][]Scope
+		wantErr bool
+	}{
+		{"empty", "", map[string][]Scope{}, false},
+		{"rules", "admins=admin; ops=certs:read,certs:write", map[string][]Scope{"admins": {ScopeAdmin}, "ops": {ScopeCertsRead, ScopeCertsWrite}}, false},
+		{"unknown scope", "ops=certs:delete", nil, true},
+		{"no role", "=admin", nil, true},
+		{"no separator", "admins", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseRoleScopes(test.input)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseRoleScopes() error = %v; want error %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("ParseRoleScopes() = %v; want %v", got, test.want)
+			}
+			for role, scopes := range test.want {
+				if !slices.Equal(got[role], scopes) {
+					t.Errorf("ParseRoleScopes()[%s] = %v; want %v", role, got[role], scopes)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Scope is a permission granted to an API key.
+type Scope string
+
+const (
+	ScopeCertsRead  Scope = "certs:read"
+	ScopeCertsWrite Scope = "certs:write"
+	// ScopeAdmin grants every other scope, as well as access to the audit
+	// trail and to API key management.
+	ScopeAdmin Scope = "admin"
+)
+
+// Scopes are all scopes, in the order they are usually listed.
+var Scopes = []Scope{ScopeCertsRead, ScopeCertsWrite, ScopeAdmin}
+
+func ValidScope(scope Scope) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// Role is what a principal may do with certificates. Roles are not granted
+// on their own but follow from the scopes.
+type Role string
+
+const (
+	// RoleViewer reads the inventory.
+	RoleViewer Role = "viewer"
+	// RoleEditor also changes the certificates owned by its team.
+	RoleEditor Role = "editor"
+	// RoleAdmin changes every certificate.
+	RoleAdmin Role = "admin"
+)
+
+// DefaultRoleScopes grants each role the scopes it follows from. It maps
+// the roles of OIDC tokens when no other mapping is configured.
+var DefaultRoleScopes = map[string][]Scope{
+	string(RoleViewer): {ScopeCertsRead},
+	string(RoleEditor): {ScopeCertsRead, ScopeCertsWrite},
+	string(RoleAdmin):  {ScopeAdmin},
+}
+
+// Kinds of principals, after how they authenticated.
+const (
+	KindAPIKey = "apikey"
+	KindOIDC   = "oidc"
+	// KindSystem is the server's own work, e.g. the TLS scanner or a
+	// command run from the shell.
+	KindSystem = "system"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Id identifies the credential, e.g. the API key id or the token subject.
+	Id     string
+	Name   string
+	Kind   string
+	Scopes []Scope
+	// Team is the owner team of the certificates an editor may change.
+	Team string
+}
+
+// System returns the principal of the server's own work, named e.g.
+// "scanner", which is recorded as "system:scanner". It is granted every
+// scope.
+func System(name string) *Principal {
+	return &Principal{Id: name, Name: name, Kind: KindSystem, Scopes: []Scope{ScopeAdmin}}
+}
+
+// Actor is how the principal is recorded in logs and the audit trail, e.g.
+// "apikey:ci-deployer".
+func (p *Principal) Actor() string {
+	return p.Kind + ":" + p.Name
+}
+
+// HasScope reports whether the principal was granted the scope, directly or
+// through the admin scope.
+func (p *Principal) HasScope(scope Scope) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// Role is the role the principal's scopes amount to.
+func (p *Principal) Role() Role {
+	switch {
+	case p.HasScope(ScopeAdmin):
+		return RoleAdmin
+	case p.HasScope(ScopeCertsWrite):
+		return RoleEditor
+	}
+	return RoleViewer
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom returns the authenticated caller, or nil for an
+// unauthenticated request.
+func PrincipalFrom(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
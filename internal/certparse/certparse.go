@@ -0,0 +1,229 @@
+package certparse
+
+import (
+	"bytes"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"unicode/utf8"
+
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+// maxNameLength is the longest common name and issuer certwatch stores.
+const maxNameLength = 255
+
+var (
+	ErrNoCertificates  = errors.New("no certificates found")
+	ErrUnexpectedBlock = errors.New("unexpected pem block")
+)
+
+// ParsePEM decodes every CERTIFICATE block in data. Any other block type
+// (private keys in particular) is rejected, certwatch never stores key material.
+func ParsePEM(data []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, ErrUnexpectedBlock
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, ErrNoCertificates
+	}
+	return certs, nil
+}
+
+// Fingerprint returns the lowercase hex SHA-256 of the DER encoded certificate.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// ToCreateInput fills the create input from the parsed certificate, so the
+// stored metadata cannot disagree with the certificate itself.
+func ToCreateInput(cert *x509.Certificate) dto.CreateCertificateInput {
+	return dto.CreateCertificateInput{
+		CommonName:        nameOf(cert.Subject.CommonName, cert.Subject.String()),
+		SerialNumber:      cert.SerialNumber.Text(16),
+		Issuer:            nameOf(cert.Issuer.CommonName, cert.Issuer.String()),
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+		FingerprintSHA256: Fingerprint(cert),
+		SubjectKeyId:      hex.EncodeToString(cert.SubjectKeyId),
+		AuthorityKeyId:    hex.EncodeToString(cert.AuthorityKeyId),
+		DNSNames:          cert.DNSNames,
+		IPAddresses:       ipStrings(cert.IPAddresses),
+		URIs:              uriStrings(cert.URIs),
+		EmailAddresses:    cert.EmailAddresses,
+		KeyDetails:        KeyDetailsOf(cert),
+		PEM:               string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+	}
+}
+
+// keyUsageNames are the RFC 5280 names of the key usage bits.
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digitalSignature"},
+	{x509.KeyUsageContentCommitment, "contentCommitment"},
+	{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+	{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+	{x509.KeyUsageKeyAgreement, "keyAgreement"},
+	{x509.KeyUsageCertSign, "keyCertSign"},
+	{x509.KeyUsageCRLSign, "cRLSign"},
+	{x509.KeyUsageEncipherOnly, "encipherOnly"},
+	{x509.KeyUsageDecipherOnly, "decipherOnly"},
+}
+
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "any",
+	x509.ExtKeyUsageServerAuth:                     "serverAuth",
+	x509.ExtKeyUsageClientAuth:                     "clientAuth",
+	x509.ExtKeyUsageCodeSigning:                    "codeSigning",
+	x509.ExtKeyUsageEmailProtection:                "emailProtection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "ipsecEndSystem",
+	x509.ExtKeyUsageIPSECTunnel:                    "ipsecTunnel",
+	x509.ExtKeyUsageIPSECUser:                      "ipsecUser",
+	x509.ExtKeyUsageTimeStamping:                   "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSPSigning",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "msSGC",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "nsSGC",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "msCodeCom",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "msKernelCode",
+}
+
+// KeyDetailsOf describes the public key and signature of the certificate.
+// Algorithms crypto/x509 does not know, post-quantum ones for instance, are
+// reported by their OID so that they still show up in the inventory.
+func KeyDetailsOf(cert *x509.Certificate) dto.KeyDetails {
+	details := dto.KeyDetails{
+		KeyAlgorithm:       cert.PublicKeyAlgorithm.String(),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		KeyUsage:           []string{},
+		ExtKeyUsage:        []string{},
+		IsCA:               cert.BasicConstraintsValid && cert.IsCA,
+		SelfSigned:         selfSigned(cert),
+	}
+	if cert.PublicKeyAlgorithm == x509.UnknownPublicKeyAlgorithm {
+		details.KeyAlgorithm = publicKeyOID(cert)
+	}
+	if cert.SignatureAlgorithm == x509.UnknownSignatureAlgorithm {
+		details.SignatureAlgorithm = signatureOID(cert)
+	}
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		details.KeySize = key.N.BitLen()
+	case *ecdsa.PublicKey:
+		details.KeySize = key.Curve.Params().BitSize
+		details.KeyCurve = key.Curve.Params().Name
+	case ed25519.PublicKey:
+		details.KeySize = 256
+	case *dsa.PublicKey:
+		details.KeySize = key.P.BitLen()
+	}
+	for _, usage := range keyUsageNames {
+		if cert.KeyUsage&usage.usage != 0 {
+			details.KeyUsage = append(details.KeyUsage, usage.name)
+		}
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		name, ok := extKeyUsageNames[usage]
+		if !ok {
+			continue
+		}
+		details.ExtKeyUsage = append(details.ExtKeyUsage, name)
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		details.ExtKeyUsage = append(details.ExtKeyUsage, oid.String())
+	}
+	return details
+}
+
+// selfSigned reports whether the certificate is signed by its own key.
+// CheckSignatureFrom is not used, as it requires the signer to be a CA.
+func selfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+func publicKeyOID(cert *x509.Certificate) string {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return "unknown"
+	}
+	return spki.Algorithm.Algorithm.String()
+}
+
+func signatureOID(cert *x509.Certificate) string {
+	var outer struct {
+		TBSCertificate     asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		Signature          asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.Raw, &outer); err != nil {
+		return "unknown"
+	}
+	return outer.SignatureAlgorithm.Algorithm.String()
+}
+
+func ipStrings(ips []net.IP) []string {
+	values := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		values = append(values, ip.String())
+	}
+	return values
+}
+
+func uriStrings(uris []*url.URL) []string {
+	values := make([]string, 0, len(uris))
+	for _, uri := range uris {
+		values = append(values, uri.String())
+	}
+	return values
+}
+
+// nameOf names a subject or issuer by its common name, or failing that by
+// its distinguished name. The name is cut to maxNameLength, which a
+// distinguished name in particular can easily exceed.
+func nameOf(commonName string, distinguishedName string) string {
+	name := commonName
+	if name == "" {
+		name = distinguishedName
+	}
+	if len(name) <= maxNameLength {
+		return name
+	}
+	end := maxNameLength
+	for end > 0 && !utf8.RuneStart(name[end]) {
+		end--
+	}
+	return name[:end]
+}
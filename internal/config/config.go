@@ -2,14 +2,68 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
+const day = 24 * time.Hour
+
+// defaultExpiryThresholds are the staged reminders: 30, 14, 7 and 1 day
+// before expiry, and once expired.
+var defaultExpiryThresholds = []time.Duration{30 * day, 14 * day, 7 * day, day, 0}
+
 type Config struct {
 	DBPath              string
 	HTTPPort            string
 	ExpiryCheckInterval time.Duration
-	ExpiryWindow        time.Duration
+	ExpiryThresholds    []time.Duration
+	ExpiryRules         string
+	ExpiredLookback     time.Duration
+	AlertRenotify       time.Duration
+	WebhookURLs         []string
+	WebhookSecret       string
+	WebhookTimeout      time.Duration
+	WebhookMaxAttempts  int
+	SMTPHost            string
+	SMTPPort            int
+	SMTPUsername        string
+	SMTPPassword        string
+	SMTPFrom            string
+	SMTPTo              []string
+	SMTPNotifyOwners    bool
+	SMTPMode            string
+	SMTPRequireTLS      bool
+	SMTPDigestInterval  time.Duration
+	SMTPTimeout         time.Duration
+	ScanInterval        time.Duration
+	ScanTimeout         time.Duration
+	LintRulesFile       string
+	LintInterval        time.Duration
+	OIDCIssuer          string
+	OIDCAudience        string
+	OIDCJWKSURL         string
+	OIDCJWKSCacheTTL    time.Duration
+	OIDCRolesClaim      string
+	OIDCTeamClaim       string
+	OIDCRoleScopes      string
+	OIDCLeeway          time.Duration
+	TLSCertFile         string
+	TLSKeyFile          string
+	TLSReloadInterval   time.Duration
+	TLSClientCAFile     string
+	TLSClientAuth       string
+	TLSClientIdentities string
+	ReadRateLimit       float64
+	ReadBurst           int
+	WriteRateLimit      float64
+	WriteBurst          int
+	AuthFailureRate     float64
+	AuthFailureBurst    int
+	OTLPEndpoint        string
+	ServiceName         string
+	TraceSampleRatio    float64
+	TraceTrustParent    bool
 }
 
 func New() Config {
@@ -19,7 +73,53 @@ func New() Config {
 		DBPath:              dbPath,
 		HTTPPort:            "8080",
 		ExpiryCheckInterval: time.Minute,
-		ExpiryWindow:        time.Hour * 72,
+		ExpiryThresholds:    getEnvDurations("EXPIRY_THRESHOLDS", defaultExpiryThresholds),
+		ExpiryRules:         getEnv("EXPIRY_RULES", ""),
+		ExpiredLookback:     getEnvDuration("EXPIRED_ALERT_LOOKBACK", 7*day),
+		AlertRenotify:       getEnvDuration("ALERT_RENOTIFY_INTERVAL", 0),
+		WebhookURLs:         getEnvList("WEBHOOK_URLS"),
+		WebhookSecret:       getEnv("WEBHOOK_SECRET", ""),
+		WebhookTimeout:      getEnvDuration("WEBHOOK_TIMEOUT", 5*time.Second),
+		WebhookMaxAttempts:  getEnvInt("WEBHOOK_MAX_ATTEMPTS", 3),
+		SMTPHost:            getEnv("SMTP_HOST", ""),
+		SMTPPort:            getEnvInt("SMTP_PORT", 587),
+		SMTPUsername:        getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:        getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:            getEnv("SMTP_FROM", ""),
+		SMTPTo:              getEnvList("SMTP_TO"),
+		SMTPNotifyOwners:    getEnvBool("SMTP_NOTIFY_OWNERS", true),
+		SMTPMode:            getEnv("SMTP_MODE", "immediate"),
+		SMTPRequireTLS:      getEnvBool("SMTP_REQUIRE_TLS", true),
+		SMTPDigestInterval:  getEnvDuration("SMTP_DIGEST_INTERVAL", 24*time.Hour),
+		SMTPTimeout:         getEnvDuration("SMTP_TIMEOUT", 10*time.Second),
+		ScanInterval:        getEnvDuration("SCAN_INTERVAL", time.Hour),
+		ScanTimeout:         getEnvDuration("SCAN_TIMEOUT", 10*time.Second),
+		LintRulesFile:       getEnv("LINT_RULES_FILE", ""),
+		LintInterval:        getEnvDuration("LINT_INTERVAL", time.Hour),
+		OIDCIssuer:          getEnv("OIDC_ISSUER", ""),
+		OIDCAudience:        getEnv("OIDC_AUDIENCE", ""),
+		OIDCJWKSURL:         getEnv("OIDC_JWKS_URL", ""),
+		OIDCJWKSCacheTTL:    getEnvDuration("OIDC_JWKS_CACHE_TTL", time.Hour),
+		OIDCRolesClaim:      getEnv("OIDC_ROLES_CLAIM", "roles"),
+		OIDCTeamClaim:       getEnv("OIDC_TEAM_CLAIM", "team"),
+		OIDCRoleScopes:      getEnv("OIDC_ROLE_SCOPES", ""),
+		OIDCLeeway:          getEnvDuration("OIDC_LEEWAY", time.Minute),
+		TLSCertFile:         getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:          getEnv("TLS_KEY_FILE", ""),
+		TLSReloadInterval:   getEnvDuration("TLS_RELOAD_INTERVAL", time.Minute),
+		TLSClientCAFile:     getEnv("TLS_CLIENT_CA_FILE", ""),
+		TLSClientAuth:       getEnv("TLS_CLIENT_AUTH", "optional"),
+		TLSClientIdentities: getEnv("TLS_CLIENT_IDENTITIES", ""),
+		ReadRateLimit:       getEnvFloat("RATE_LIMIT_READ_RATE", 10),
+		ReadBurst:           getEnvInt("RATE_LIMIT_READ_BURST", 50),
+		WriteRateLimit:      getEnvFloat("RATE_LIMIT_WRITE_RATE", 1),
+		WriteBurst:          getEnvInt("RATE_LIMIT_WRITE_BURST", 20),
+		AuthFailureRate:     getEnvFloat("RATE_LIMIT_AUTH_FAILURE_RATE", 0.1),
+		AuthFailureBurst:    getEnvInt("RATE_LIMIT_AUTH_FAILURE_BURST", 10),
+		OTLPEndpoint:        getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		ServiceName:         getEnv("OTEL_SERVICE_NAME", "certwatch"),
+		TraceSampleRatio:    getEnvFloat("TRACE_SAMPLE_RATIO", 1),
+		TraceTrustParent:    getEnvBool("TRACE_TRUST_PARENT", false),
 	}
 }
 
@@ -29,3 +129,81 @@ func getEnv(key string, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if val, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			return d
+		}
+	}
+	return fallback
+}
+
+// getEnvDurations parses a comma separated list such as "30d,14d,12h,0".
+// Days are accepted in addition to the time.ParseDuration units.
+func getEnvDurations(key string, fallback []time.Duration) []time.Duration {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	durations := []time.Duration{}
+	for _, part := range strings.Split(val, ",") {
+		d, err := parseDuration(strings.TrimSpace(part))
+		if err != nil || d < 0 {
+			return fallback
+		}
+		durations = append(durations, d)
+	}
+	return durations
+}
+
+func parseDuration(val string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(val, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * day, nil
+	}
+	return time.ParseDuration(val)
+}
+
+func getEnvInt(key string, fallback int) int {
+	if val, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// getEnvFloat accepts zero, unlike getEnvInt, so that e.g. a rate can be
+// switched off.
+func getEnvFloat(key string, fallback float64) float64 {
+	if val, ok := os.LookupEnv(key); ok {
+		if f, err := strconv.ParseFloat(val, 64); err == nil && f >= 0 {
+			return f
+		}
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if val, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
+
+// getEnvList splits a comma separated value, dropping empty entries.
+func getEnvList(key string) []string {
+	list := []string{}
+	for _, part := range strings.Split(getEnv(key, ""), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
@@ -4,23 +4,71 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io/fs"
+	"sort"
 	"time"
 
 	"github.com/hytonhan/certwatch/migrations"
 )
 
-func RunMigrations(ctx context.Context, db *sql.DB, path string) error {
-	data, err := migrations.MigrationFiles.ReadFile("001_init.sql")
+// RunMigrations applies every embedded migration that has not been applied
+// yet, in file name order. Each migration runs in its own transaction together
+// with its bookkeeping row in schema_migrations.
+func RunMigrations(ctx context.Context, db *sql.DB) error {
+	names, err := fs.Glob(migrations.MigrationFiles, "*.sql")
 	if err != nil {
-		return fmt.Errorf("Read migration: %w", err)
+		return fmt.Errorf("List migrations: %w", err)
 	}
+	sort.Strings(names)
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	if _, err := db.ExecContext(ctx, string(data)); err != nil {
-		return fmt.Errorf("Execute migration: %w", err)
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		name TEXT PRIMARY KEY,
+		applied_at DATETIME NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("Create migration table: %w", err)
 	}
 
+	for _, name := range names {
+		if err := applyMigration(ctx, db, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, name string) error {
+	var applied int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE name = ?", name).Scan(&applied); err != nil {
+		return fmt.Errorf("Check migration %s: %w", name, err)
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	data, err := migrations.MigrationFiles.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("Read migration %s: %w", name, err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Begin migration %s: %w", name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, string(data)); err != nil {
+		return fmt.Errorf("Execute migration %s: %w", name, err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (name, applied_at) VALUES (?, ?)", name, time.Now().UTC()); err != nil {
+		return fmt.Errorf("Record migration %s: %w", name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Commit migration %s: %w", name, err)
+	}
 	return nil
 }
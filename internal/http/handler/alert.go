@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/hytonhan/certwatch/internal/repository"
+	"github.com/hytonhan/certwatch/internal/service"
+)
+
+type AlertHandler struct {
+	service service.AlertService
+	logger  *slog.Logger
+}
+
+func NewAlertHandler(s service.AlertService, log *slog.Logger) *AlertHandler {
+	return &AlertHandler{service: s, logger: log}
+}
+
+func (h *AlertHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received alert list request",
+		"request_id", requestID)
+
+	var acknowledged *bool
+	if raw := r.URL.Query().Get("acknowledged"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		acknowledged = &parsed
+	}
+
+	alerts, err := h.service.List(r.Context(), acknowledged)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Alert list failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Fetched "+strconv.Itoa(len(alerts))+" alerts",
+		"request_id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(alerts)
+}
+
+func (h *AlertHandler) HandleAcknowledge(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received alert acknowledge request",
+		"request_id", requestID)
+
+	id := r.PathValue("id")
+
+	err := h.service.Acknowledge(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			h.logger.InfoContext(r.Context(), "Alert acknowledge failed: not found",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			h.logger.InfoContext(r.Context(), "Alert acknowledge failed: forbidden",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, repository.ErrStaleVersion) {
+			h.logger.InfoContext(r.Context(), "Alert acknowledge failed: owner changed meanwhile",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, "conflict", http.StatusConflict)
+			return
+		}
+		h.logger.WarnContext(r.Context(), "Alert acknowledge failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Acknowledged alert",
+		"id", id,
+		"request_id", requestID)
+	w.WriteHeader(http.StatusNoContent)
+}
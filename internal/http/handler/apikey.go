@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+	"github.com/hytonhan/certwatch/internal/service"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+type APIKeyHandler struct {
+	service service.APIKeyService
+	logger  *slog.Logger
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Team   string   `json:"team"`
+}
+
+type APIKeyResponse struct {
+	Id         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	Team       string     `json:"team"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// CreatedAPIKeyResponse is the only response that carries the token.
+type CreatedAPIKeyResponse struct {
+	APIKeyResponse
+	Token string `json:"token"`
+}
+
+func NewAPIKeyResponse(key model.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		Id:         key.Id,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		Team:       key.Team,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
+
+func NewAPIKeyHandler(s service.APIKeyService, log *slog.Logger) *APIKeyHandler {
+	return &APIKeyHandler{service: s, logger: log}
+}
+
+func (h *APIKeyHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received api key create request",
+		"request_id", requestID)
+	var req CreateAPIKeyRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<16) // 64KB
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.service.Create(r.Context(), dto.CreateAPIKeyInput{Name: req.Name, Scopes: req.Scopes, Team: req.Team})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) {
+			h.logger.InfoContext(r.Context(), "API key create failed: invalid input",
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, repository.ErrConflict) {
+			h.logger.InfoContext(r.Context(), "API key create failed: conflict",
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		h.logger.WarnContext(r.Context(), "API key create failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Created api key",
+		"id", created.Key.Id,
+		"request_id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreatedAPIKeyResponse{APIKeyResponse: NewAPIKeyResponse(created.Key), Token: created.Token})
+}
+
+func (h *APIKeyHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received api key list request",
+		"request_id", requestID)
+
+	keys, err := h.service.List(r.Context())
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "API key list failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, NewAPIKeyResponse(key))
+	}
+
+	h.logger.InfoContext(r.Context(), "Fetched "+strconv.Itoa(len(keys))+" api keys",
+		"request_id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *APIKeyHandler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received api key revoke request",
+		"request_id", requestID)
+
+	id := r.PathValue("id")
+
+	if err := h.service.Revoke(r.Context(), id); err != nil {
+		if errors.Is(err, service.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			h.logger.InfoContext(r.Context(), "API key revoke failed: not found",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		h.logger.WarnContext(r.Context(), "API key revoke failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Revoked api key",
+		"id", id,
+		"request_id", requestID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/service"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+type AuditHandler struct {
+	service service.AuditService
+	logger  *slog.Logger
+}
+
+type AuditEventResponse struct {
+	Id         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Actor      string          `json:"actor"`
+	RequestId  string          `json:"request_id"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityId   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+type AuditListResponse struct {
+	Items      []AuditEventResponse `json:"items"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+type AuditVerificationResponse struct {
+	Valid    bool   `json:"valid"`
+	Checked  int    `json:"checked"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	LastId   int64  `json:"last_id"`
+	LastHash string `json:"last_hash"`
+}
+
+// NewAuditVerificationResponse is shared with the audit verify command, so
+// that both report the same document.
+func NewAuditVerificationResponse(result *model.AuditVerification) AuditVerificationResponse {
+	return AuditVerificationResponse{
+		Valid:    result.Valid,
+		Checked:  result.Checked,
+		BrokenAt: result.BrokenAt,
+		Reason:   result.Reason,
+		LastId:   result.LastId,
+		LastHash: result.LastHash,
+	}
+}
+
+func NewAuditHandler(s service.AuditService, log *slog.Logger) *AuditHandler {
+	return &AuditHandler{service: s, logger: log}
+}
+
+func (h *AuditHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received audit list request",
+		"request_id", requestID)
+
+	input, err := parseAuditQuery(r)
+	if err != nil {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.service.List(r.Context(), input)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) || errors.Is(err, service.ErrInvalidDateRange) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.logger.WarnContext(r.Context(), "Audit list failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := AuditListResponse{Items: make([]AuditEventResponse, 0, len(page.Items)), NextCursor: page.NextCursor}
+	for _, event := range page.Items {
+		response.Items = append(response.Items, AuditEventResponse{
+			Id:         event.Id,
+			OccurredAt: event.OccurredAt,
+			Actor:      event.Actor,
+			RequestId:  event.RequestId,
+			Action:     event.Action,
+			EntityType: event.EntityType,
+			EntityId:   event.EntityId,
+			Before:     event.Before,
+			After:      event.After,
+			PrevHash:   event.PrevHash,
+			Hash:       event.Hash,
+		})
+	}
+
+	h.logger.InfoContext(r.Context(), "Fetched "+strconv.Itoa(len(page.Items))+" audit events",
+		"request_id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleVerify walks the whole hash chain. A broken chain is still a
+// successful verification, reported with valid set to false.
+func (h *AuditHandler) HandleVerify(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received audit verify request",
+		"request_id", requestID)
+
+	result, err := h.service.Verify(r.Context())
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Audit verify failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	if !result.Valid {
+		h.logger.WarnContext(r.Context(), "Audit chain is broken",
+			"broken_at", result.BrokenAt,
+			"reason", result.Reason,
+			"request_id", requestID)
+	} else {
+		h.logger.InfoContext(r.Context(), "Verified "+strconv.Itoa(result.Checked)+" audit events",
+			"request_id", requestID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(NewAuditVerificationResponse(result))
+}
+
+func parseAuditQuery(r *http.Request) (dto.ListAuditEventsInput, error) {
+	query := r.URL.Query()
+	input := dto.ListAuditEventsInput{
+		Actor:      query.Get("actor"),
+		Action:     query.Get("action"),
+		EntityType: query.Get("entity_type"),
+		EntityId:   query.Get("entity_id"),
+		RequestId:  query.Get("request_id"),
+		Cursor:     query.Get("cursor"),
+	}
+	var err error
+	if value := query.Get("from"); value != "" {
+		if input.From, err = time.Parse(time.RFC3339, value); err != nil {
+			return input, err
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if input.To, err = time.Parse(time.RFC3339, value); err != nil {
+			return input, err
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if input.Limit, err = strconv.Atoi(value); err != nil {
+			return input, err
+		}
+	}
+	return input, nil
+}
@@ -3,9 +3,13 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hytonhan/certwatch/internal/model"
@@ -20,12 +24,71 @@ type CertificateHandler struct {
 }
 
 type CreateRequest struct {
-	CommonName        string    `json:"common_name"`
-	SerialNumber      string    `json:"serial_number"`
-	Issuer            string    `json:"issuer"`
-	NotBefore         time.Time `json:"not_before"`
-	NotAfter          time.Time `json:"not_after"`
-	FingerprintSHA256 string    `json:"fingerprintsha256"`
+	CommonName        string            `json:"common_name"`
+	SerialNumber      string            `json:"serial_number"`
+	Issuer            string            `json:"issuer"`
+	NotBefore         time.Time         `json:"not_before"`
+	NotAfter          time.Time         `json:"not_after"`
+	FingerprintSHA256 string            `json:"fingerprintsha256"`
+	OwnerTeam         string            `json:"owner_team"`
+	ContactEmail      string            `json:"contact_email"`
+	Description       string            `json:"description"`
+	Labels            map[string]string `json:"labels"`
+	DNSNames          []string          `json:"dns_names"`
+	IPAddresses       []string          `json:"ip_addresses"`
+	URIs              []string          `json:"uris"`
+	EmailAddresses    []string          `json:"email_addresses"`
+}
+
+type UpdateRequest struct {
+	CommonName        *string           `json:"common_name"`
+	SerialNumber      *string           `json:"serial_number"`
+	Issuer            *string           `json:"issuer"`
+	NotBefore         *time.Time        `json:"not_before"`
+	NotAfter          *time.Time        `json:"not_after"`
+	OwnerTeam         *string           `json:"owner_team"`
+	ContactEmail      *string           `json:"contact_email"`
+	Description       *string           `json:"description"`
+	Notes             *string           `json:"notes"`
+	Labels            map[string]string `json:"labels"`
+	FingerprintSHA256 *string           `json:"fingerprint_sha256"`
+	SubjectKeyId      *string           `json:"subject_key_id"`
+	AuthorityKeyId    *string           `json:"authority_key_id"`
+}
+
+type ListResponse struct {
+	Items      []model.Certificate `json:"items"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+type SearchResponse struct {
+	Certificate model.Certificate `json:"certificate"`
+	Rank        float64           `json:"rank"`
+	Snippet     string            `json:"snippet"`
+}
+
+type SummaryResponse struct {
+	Total               int              `json:"total"`
+	Keys                []KeyCount       `json:"keys"`
+	SignatureAlgorithms []SignatureCount `json:"signature_algorithms"`
+}
+
+type KeyCount struct {
+	KeyAlgorithm string `json:"key_algorithm"`
+	KeySize      int    `json:"key_size"`
+	KeyCurve     string `json:"key_curve,omitempty"`
+	Count        int    `json:"count"`
+}
+
+type SignatureCount struct {
+	SignatureAlgorithm string `json:"signature_algorithm"`
+	Count              int    `json:"count"`
+}
+
+type ImportResponse struct {
+	Id       string `json:"id"`
+	IssuerId string `json:"issuer_id,omitempty"`
+	Created  bool   `json:"created"`
 }
 
 func NewCertificateHandler(s service.CertificateService, log *slog.Logger) *CertificateHandler {
@@ -55,6 +118,16 @@ func (h *CertificateHandler) HandleCreate(w http.ResponseWriter, r *http.Request
 		NotBefore:         req.NotBefore,
 		NotAfter:          req.NotAfter,
 		FingerprintSHA256: req.FingerprintSHA256,
+		Ownership: dto.Ownership{
+			OwnerTeam:    req.OwnerTeam,
+			ContactEmail: req.ContactEmail,
+			Description:  req.Description,
+		},
+		Labels:         req.Labels,
+		DNSNames:       req.DNSNames,
+		IPAddresses:    req.IPAddresses,
+		URIs:           req.URIs,
+		EmailAddresses: req.EmailAddresses,
 	}
 
 	cert, err := h.service.Create(r.Context(), input)
@@ -71,6 +144,12 @@ func (h *CertificateHandler) HandleCreate(w http.ResponseWriter, r *http.Request
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if errors.Is(err, service.ErrForbidden) {
+			h.logger.InfoContext(r.Context(), "Create failed: forbidden",
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
 		if errors.Is(err, repository.ErrConflict) {
 			h.logger.InfoContext(r.Context(), "Create failed: conflict",
 				"request_id", requestID)
@@ -91,6 +170,82 @@ func (h *CertificateHandler) HandleCreate(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(cert.Id)
 }
 
+func (h *CertificateHandler) HandleCreateFromPEM(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+
+	h.logger.InfoContext(r.Context(), "Received PEM create request",
+		"request_id", requestID)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB
+
+	if !isPEMContentType(r.Header.Get("Content-Type")) {
+		http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	ownership := dto.Ownership{
+		OwnerTeam:    query.Get("owner_team"),
+		ContactEmail: query.Get("contact_email"),
+		Description:  query.Get("description"),
+	}
+
+	results, err := h.service.CreateFromPEM(r.Context(), body, ownership)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) {
+			h.logger.InfoContext(r.Context(), "PEM create failed: invalid input",
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidDateRange) {
+			h.logger.InfoContext(r.Context(), "PEM create failed: invalid date range",
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			h.logger.InfoContext(r.Context(), "PEM create failed: forbidden",
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, repository.ErrConflict) || errors.Is(err, repository.ErrStaleVersion) {
+			h.logger.InfoContext(r.Context(), "PEM create failed: chain changed meanwhile",
+				"request_id", requestID)
+			http.Error(w, "conflict", http.StatusConflict)
+			return
+		}
+		h.logger.WarnContext(r.Context(), "PEM create failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]ImportResponse, 0, len(results))
+	for _, result := range results {
+		h.logger.InfoContext(r.Context(), "Imported certificate from PEM",
+			"id", result.Certificate.Id,
+			"created", result.Created,
+			"request_id", requestID)
+		response = append(response, ImportResponse{Id: result.Certificate.Id, IssuerId: result.Certificate.IssuerId, Created: result.Created})
+	}
+
+	status := http.StatusOK
+	if results[0].Created {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
 func (h *CertificateHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
 
 	requestID := r.Context().Value("request_id").(string)
@@ -124,11 +279,79 @@ func (h *CertificateHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
 	h.logger.InfoContext(r.Context(), "Found cert",
 		"id", id,
 		"request_id", requestID)
+	w.Header().Set("ETag", etag(cert.Version))
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Contect-Type", "application/json")
 	json.NewEncoder(w).Encode(cert)
 }
 
+func (h *CertificateHandler) HandleGetChain(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received get chain request",
+		"request_id", requestID)
+
+	id := r.PathValue("id")
+
+	chain, err := h.service.GetChain(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			h.logger.InfoContext(r.Context(), "Get chain failed: not found",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		h.logger.WarnContext(r.Context(), "Get chain failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(chain)
+}
+
+func (h *CertificateHandler) HandleListIssued(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received list issued request",
+		"request_id", requestID)
+
+	id := r.PathValue("id")
+
+	certs, err := h.service.ListIssuedBy(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			h.logger.InfoContext(r.Context(), "List issued failed: not found",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		h.logger.WarnContext(r.Context(), "List issued failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Fetched "+strconv.Itoa(len(certs))+" issued certs",
+		"id", id,
+		"request_id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(certs)
+}
+
 func (h *CertificateHandler) HandleList(w http.ResponseWriter, r *http.Request) {
 
 	requestID := r.Context().Value("request_id").(string)
@@ -136,58 +359,205 @@ func (h *CertificateHandler) HandleList(w http.ResponseWriter, r *http.Request)
 		"request_id", requestID)
 	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB
 
-	var certs []model.Certificate
-	var err error
+	input, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
 
-	within := r.URL.Query().Get("expiring_within")
-	if within == "" {
-		certs, err = h.service.List(r.Context())
-		if err != nil {
-			h.logger.WarnContext(r.Context(), "List failed for unknown reason",
-				"request_id", requestID)
-			http.Error(w, "internal error", http.StatusInternalServerError)
+	page, err := h.service.List(r.Context(), input)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) || errors.Is(err, service.ErrInvalidDateRange) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-	} else {
-		d, err := time.ParseDuration(within)
+		h.logger.WarnContext(r.Context(), "List failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Fetched "+strconv.Itoa(len(page.Items))+" certs",
+		"request_id", requestID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ListResponse{Items: page.Items, NextCursor: page.NextCursor})
+}
+
+func (h *CertificateHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received search request",
+		"request_id", requestID)
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
 		if err != nil {
 			http.Error(w, "invalid input", http.StatusBadRequest)
 			return
 		}
-		expired := r.URL.Query().Get("expired")
-		var option service.ExpiryOption
-		if expired == "" {
-			option = service.IncludeExpired
-		} else {
-			parsed, berr := strconv.ParseBool(expired)
-			if berr != nil {
-				http.Error(w, "invalid input", http.StatusBadRequest)
-				return
-			}
-			if parsed == true {
-				option = service.IncludeExpired
-			} else {
-				option = service.ExcludeExpired
-			}
-		}
-		h.logger.InfoContext(r.Context(), "Params",
-			"window", within,
-			"expired", expired,
+		limit = parsed
+	}
+
+	results, err := h.service.Search(r.Context(), r.URL.Query().Get("q"), limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.logger.WarnContext(r.Context(), "Search failed for unknown reason",
 			"request_id", requestID)
-		certs, err = h.service.ListExpiring(r.Context(), d, option)
-		if err != nil {
-			h.logger.WarnContext(r.Context(), "List failed for unknown reason",
-				"request_id", requestID)
-			http.Error(w, "internal error", http.StatusInternalServerError)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]SearchResponse, 0, len(results))
+	for _, result := range results {
+		response = append(response, SearchResponse{Certificate: result.Certificate, Rank: result.Rank, Snippet: result.Snippet})
+	}
+
+	h.logger.InfoContext(r.Context(), "Found "+strconv.Itoa(len(results))+" certs",
+		"request_id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *CertificateHandler) HandleSummary(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received summary request",
+		"request_id", requestID)
+
+	input, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	summary, err := h.service.Summarize(r.Context(), input)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) || errors.Is(err, service.ErrInvalidDateRange) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		h.logger.WarnContext(r.Context(), "Summary failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
 	}
 
-	h.logger.InfoContext(r.Context(), "Fetched "+strconv.Itoa(len(certs))+" certs")
+	response := SummaryResponse{
+		Total:               summary.Total,
+		Keys:                make([]KeyCount, 0, len(summary.Keys)),
+		SignatureAlgorithms: make([]SignatureCount, 0, len(summary.SignatureAlgorithms)),
+	}
+	for _, key := range summary.Keys {
+		response.Keys = append(response.Keys, KeyCount{KeyAlgorithm: key.KeyAlgorithm, KeySize: key.KeySize, KeyCurve: key.KeyCurve, Count: key.Count})
+	}
+	for _, signature := range summary.SignatureAlgorithms {
+		response.SignatureAlgorithms = append(response.SignatureAlgorithms, SignatureCount{SignatureAlgorithm: signature.SignatureAlgorithm, Count: signature.Count})
+	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Contect-Type", "application/json")
-	json.NewEncoder(w).Encode(certs)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *CertificateHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received update request",
+		"request_id", requestID)
+	var req UpdateRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB
+
+	if r.Header.Get("If-Match") == "" {
+		http.Error(w, "If-Match header required", http.StatusPreconditionRequired)
+		return
+	}
+	version, ok := parseETag(r.Header.Get("If-Match"))
+	if !ok {
+		http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	input := dto.UpdateCertificateInput{
+		Version:           version,
+		CommonName:        req.CommonName,
+		SerialNumber:      req.SerialNumber,
+		Issuer:            req.Issuer,
+		NotBefore:         req.NotBefore,
+		NotAfter:          req.NotAfter,
+		OwnerTeam:         req.OwnerTeam,
+		ContactEmail:      req.ContactEmail,
+		Description:       req.Description,
+		Notes:             req.Notes,
+		Labels:            req.Labels,
+		FingerprintSHA256: req.FingerprintSHA256,
+		SubjectKeyId:      req.SubjectKeyId,
+		AuthorityKeyId:    req.AuthorityKeyId,
+	}
+
+	cert, err := h.service.Update(r.Context(), id, input)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) || errors.Is(err, service.ErrInvalidDateRange) {
+			h.logger.InfoContext(r.Context(), "Update failed: invalid input",
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrImmutableField) {
+			h.logger.InfoContext(r.Context(), "Update failed: immutable field",
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			h.logger.InfoContext(r.Context(), "Update failed: forbidden",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, repository.ErrStaleVersion) {
+			h.logger.InfoContext(r.Context(), "Update failed: stale version",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			return
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			h.logger.InfoContext(r.Context(), "Update failed: not found",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		h.logger.WarnContext(r.Context(), "Update failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Updated cert",
+		"id", id,
+		"version", cert.Version,
+		"request_id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(cert.Version))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(cert)
 }
 
 func (h *CertificateHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
@@ -208,6 +578,20 @@ func (h *CertificateHandler) HandleDelete(w http.ResponseWriter, r *http.Request
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, service.ErrForbidden) {
+			h.logger.InfoContext(r.Context(), "Delete failed: forbidden",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, repository.ErrStaleVersion) {
+			h.logger.InfoContext(r.Context(), "Delete failed: changed meanwhile",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, "conflict", http.StatusConflict)
+			return
+		}
 		h.logger.WarnContext(r.Context(), "Delete failed for unknown reason",
 			"request_id", requestID)
 		http.Error(w, "internal error", http.StatusInternalServerError)
@@ -219,3 +603,98 @@ func (h *CertificateHandler) HandleDelete(w http.ResponseWriter, r *http.Request
 		"request_id", requestID)
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func isPEMContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/x-pem-file" || mediaType == "application/pem-certificate-chain"
+}
+
+// etag is the strong entity tag of a certificate version.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseETag reads the version from an If-Match header. Only a single strong
+// tag can match, since the update must be based on one exact version.
+func parseETag(header string) (int64, bool) {
+	unquoted, found := strings.CutPrefix(strings.TrimSpace(header), `"`)
+	if !found {
+		return 0, false
+	}
+	unquoted, found = strings.CutSuffix(unquoted, `"`)
+	if !found {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+// parseListQuery reads the filters, sort and page of GET /certificates. The
+// summary takes the same filters.
+func parseListQuery(query url.Values) (dto.ListCertificatesInput, error) {
+	input := dto.ListCertificatesInput{
+		OwnerTeam:          query.Get("owner"),
+		Selector:           query.Get("selector"),
+		Issuer:             query.Get("issuer"),
+		CommonNamePrefix:   query.Get("cn_prefix"),
+		DNSName:            query.Get("dns"),
+		KeyAlgorithm:       query.Get("key_algorithm"),
+		SignatureAlgorithm: query.Get("signature_algorithm"),
+		KeyUsage:           query.Get("key_usage"),
+		ExtKeyUsage:        query.Get("ext_key_usage"),
+		Sort:               query.Get("sort"),
+		Cursor:             query.Get("cursor"),
+	}
+	var err error
+	if value := query.Get("not_after_from"); value != "" {
+		if input.NotAfterFrom, err = time.Parse(time.RFC3339, value); err != nil {
+			return input, err
+		}
+	}
+	if value := query.Get("not_after_to"); value != "" {
+		if input.NotAfterTo, err = time.Parse(time.RFC3339, value); err != nil {
+			return input, err
+		}
+	}
+	if value := query.Get("expiring_within"); value != "" {
+		if input.ExpiringWithin, err = time.ParseDuration(value); err != nil {
+			return input, err
+		}
+	}
+	if value := query.Get("expired"); value != "" {
+		expired, err := strconv.ParseBool(value)
+		if err != nil {
+			return input, err
+		}
+		input.ExcludeExpired = !expired
+	}
+	if value := query.Get("key_size"); value != "" {
+		if input.KeySize, err = strconv.Atoi(value); err != nil {
+			return input, err
+		}
+	}
+	if value := query.Get("key_size_below"); value != "" {
+		if input.KeySizeBelow, err = strconv.Atoi(value); err != nil {
+			return input, err
+		}
+	}
+	if value := query.Get("ca"); value != "" {
+		ca, err := strconv.ParseBool(value)
+		if err != nil {
+			return input, err
+		}
+		input.IsCA = &ca
+	}
+	if value := query.Get("limit"); value != "" {
+		if input.Limit, err = strconv.Atoi(value); err != nil {
+			return input, err
+		}
+	}
+	return input, nil
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/hytonhan/certwatch/internal/repository"
+	"github.com/hytonhan/certwatch/internal/service"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+type EndpointHandler struct {
+	service service.EndpointService
+	logger  *slog.Logger
+}
+
+type CreateEndpointRequest struct {
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	ServerName string `json:"server_name"`
+	OwnerTeam  string `json:"owner_team"`
+}
+
+func NewEndpointHandler(s service.EndpointService, log *slog.Logger) *EndpointHandler {
+	return &EndpointHandler{service: s, logger: log}
+}
+
+func (h *EndpointHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+
+	h.logger.InfoContext(r.Context(), "Received endpoint create request",
+		"request_id", requestID)
+	var req CreateEndpointRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	input := dto.CreateEndpointInput{
+		Host:       req.Host,
+		Port:       req.Port,
+		ServerName: req.ServerName,
+		OwnerTeam:  req.OwnerTeam,
+	}
+
+	endpoint, err := h.service.Create(r.Context(), input)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) {
+			h.logger.InfoContext(r.Context(), "Endpoint create failed: invalid input",
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			h.logger.InfoContext(r.Context(), "Endpoint create failed: forbidden",
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, repository.ErrConflict) {
+			h.logger.InfoContext(r.Context(), "Endpoint create failed: conflict",
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		h.logger.WarnContext(r.Context(), "Endpoint create failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Created endpoint",
+		"id", endpoint.Id,
+		"request_id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(endpoint.Id)
+}
+
+func (h *EndpointHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received endpoint list request",
+		"request_id", requestID)
+
+	endpoints, err := h.service.List(r.Context())
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Endpoint list failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Fetched "+strconv.Itoa(len(endpoints))+" endpoints",
+		"request_id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(endpoints)
+}
+
+func (h *EndpointHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received endpoint delete request",
+		"request_id", requestID)
+
+	id := r.PathValue("id")
+
+	err := h.service.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			h.logger.InfoContext(r.Context(), "Endpoint delete failed: not found",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			h.logger.InfoContext(r.Context(), "Endpoint delete failed: forbidden",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, repository.ErrStaleVersion) {
+			h.logger.InfoContext(r.Context(), "Endpoint delete failed: owner changed meanwhile",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, "conflict", http.StatusConflict)
+			return
+		}
+		h.logger.WarnContext(r.Context(), "Endpoint delete failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Deleted endpoint",
+		"id", id,
+		"request_id", requestID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+	"github.com/hytonhan/certwatch/internal/service"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+type FindingHandler struct {
+	service service.FindingService
+	logger  *slog.Logger
+}
+
+type FindingReportResponse struct {
+	Counts []model.FindingCount `json:"counts"`
+	Items  []model.Finding      `json:"items"`
+}
+
+func NewFindingHandler(s service.FindingService, log *slog.Logger) *FindingHandler {
+	return &FindingHandler{service: s, logger: log}
+}
+
+func (h *FindingHandler) HandleListForCertificate(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received certificate findings request",
+		"request_id", requestID)
+
+	id := r.PathValue("id")
+
+	findings, err := h.service.ListForCertificate(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			h.logger.InfoContext(r.Context(), "Certificate findings failed: not found",
+				"id", id,
+				"request_id", requestID)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		h.logger.WarnContext(r.Context(), "Certificate findings failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Fetched "+strconv.Itoa(len(findings))+" findings",
+		"id", id,
+		"request_id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(findings)
+}
+
+func (h *FindingHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received finding list request",
+		"request_id", requestID)
+
+	query := r.URL.Query()
+	input := dto.ListFindingsInput{
+		RuleId:   query.Get("rule"),
+		Severity: query.Get("severity"),
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		input.Limit = limit
+	}
+
+	report, err := h.service.List(r.Context(), input)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.logger.WarnContext(r.Context(), "Finding list failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Fetched "+strconv.Itoa(len(report.Items))+" findings",
+		"request_id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(FindingReportResponse{Counts: report.Counts, Items: report.Items})
+}
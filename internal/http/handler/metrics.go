@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/hytonhan/certwatch/internal/metrics"
+)
+
+type MetricsHandler struct {
+	registry *metrics.Registry
+	logger   *slog.Logger
+}
+
+func NewMetricsHandler(registry *metrics.Registry, log *slog.Logger) *MetricsHandler {
+	return &MetricsHandler{registry: registry, logger: log}
+}
+
+// HandleMetrics serves the metrics in the Prometheus text format. If some
+// cannot be collected, the others are still served, so that a scrape is not
+// lost to e.g. a busy database.
+func (h *MetricsHandler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+
+	families, err := h.registry.Gather(r.Context())
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Collecting metrics failed",
+			"reason", err.Error(),
+			"request_id", requestID)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	metrics.Write(w, families)
+}
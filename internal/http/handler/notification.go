@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/hytonhan/certwatch/internal/service"
+)
+
+type NotificationHandler struct {
+	service service.NotificationService
+	logger  *slog.Logger
+}
+
+func NewNotificationHandler(s service.NotificationService, log *slog.Logger) *NotificationHandler {
+	return &NotificationHandler{service: s, logger: log}
+}
+
+func (h *NotificationHandler) HandleListDeliveries(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received delivery list request",
+		"request_id", requestID)
+
+	var success *bool
+	if raw := r.URL.Query().Get("success"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		success = &parsed
+	}
+
+	deliveries, err := h.service.ListDeliveries(r.Context(), success)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Delivery list failed for unknown reason",
+			"request_id", requestID)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Fetched "+strconv.Itoa(len(deliveries))+" deliveries",
+		"request_id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(deliveries)
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/hytonhan/certwatch/internal/middleware"
+)
+
+type RateLimitHandler struct {
+	limits *middleware.RateLimits
+	logger *slog.Logger
+}
+
+type RateLimitCountsResponse struct {
+	ReadsAllowed  int64 `json:"reads_allowed"`
+	ReadsLimited  int64 `json:"reads_limited"`
+	WritesAllowed int64 `json:"writes_allowed"`
+	WritesLimited int64 `json:"writes_limited"`
+
+	AuthFailuresLimited int64 `json:"auth_failures_limited"`
+}
+
+func NewRateLimitHandler(limits *middleware.RateLimits, log *slog.Logger) *RateLimitHandler {
+	return &RateLimitHandler{limits: limits, logger: log}
+}
+
+// HandleCounts reports how many requests the rate limits let through and
+// turned away since the server started.
+func (h *RateLimitHandler) HandleCounts(w http.ResponseWriter, r *http.Request) {
+
+	requestID := r.Context().Value("request_id").(string)
+	h.logger.InfoContext(r.Context(), "Received rate limit counts request",
+		"request_id", requestID)
+
+	counts := h.limits.Counts()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(RateLimitCountsResponse{
+		ReadsAllowed:  counts.ReadsAllowed,
+		ReadsLimited:  counts.ReadsLimited,
+		WritesAllowed: counts.WritesAllowed,
+		WritesLimited: counts.WritesLimited,
+
+		AuthFailuresLimited: counts.AuthFailuresLimited,
+	})
+}
@@ -1,23 +1,110 @@
 package handler
 
 import (
+	"log/slog"
 	"net/http"
 
+	"github.com/hytonhan/certwatch/internal/auth"
 	"github.com/hytonhan/certwatch/internal/middleware"
 )
 
-func (h *CertificateHandler) Router() http.Handler {
+// RouteRegistrar is implemented by every handler that serves part of the API.
+type RouteRegistrar interface {
+	RegisterRoutes(mux *http.ServeMux)
+}
+
+// NewRouter serves the handlers' routes. Every route but /health requires a
+// caller authenticated by one of the authenticators or, unless clientCerts is
+// nil, by a verified TLS client certificate. Unless denials is nil, callers
+// refused a route for a missing scope are recorded there. Unless limits is
+// nil, callers are held to its rate limits, addresses that fail to
+// authenticate included, and unless httpMetrics is nil,
+// requests are counted. Every request is traced.
+func NewRouter(logger *slog.Logger, authenticators []middleware.Authenticator, clientCerts middleware.CertificateAuthenticator, denials middleware.DenialRecorder, limits *middleware.RateLimits, httpMetrics *middleware.HTTPMetrics, handlers ...RouteRegistrar) http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ok"))
 	})
-	mux.HandleFunc("POST /certificates", h.HandleCreate)
-	mux.HandleFunc("GET /certificates", h.HandleList)
-	mux.HandleFunc("GET /certificates/{id}", h.HandleGet)
-	mux.HandleFunc("DELETE /certificates/{id}", h.HandleDelete)
+	for _, h := range handlers {
+		h.RegisterRoutes(mux)
+	}
+
+	var auditedMux http.Handler = mux
+	if denials != nil {
+		auditedMux = middleware.AuditDenials(logger, denials)(mux)
+	}
+	limitedMux := auditedMux
+	if limits != nil {
+		limitedMux = middleware.RateLimit(logger, limits)(auditedMux)
+	}
+	authenticatedMux := middleware.Authenticate(logger, authenticators...)(limitedMux)
+	if clientCerts != nil {
+		authenticatedMux = middleware.ClientCertificate(logger, clientCerts)(authenticatedMux)
+	}
+	if limits != nil {
+		authenticatedMux = middleware.LimitAuthFailures(logger, limits)(authenticatedMux)
+	}
+	routedMux := middleware.Route(mux)(authenticatedMux)
+	loggedMux := middleware.LoggingMiddlewarefunc(logger, httpMetrics)(routedMux)
+	tracedMux := middleware.Trace()(loggedMux)
+
+	return tracedMux
+}
+
+// handle registers a route that only callers granted scope may use.
+func handle(mux *http.ServeMux, pattern string, scope auth.Scope, handler http.HandlerFunc) {
+	mux.Handle(pattern, middleware.RequireScope(scope)(handler))
+}
+
+func (h *CertificateHandler) RegisterRoutes(mux *http.ServeMux) {
+	handle(mux, "POST /certificates", auth.ScopeCertsWrite, h.HandleCreate)
+	handle(mux, "POST /certificates/pem", auth.ScopeCertsWrite, h.HandleCreateFromPEM)
+	handle(mux, "GET /certificates", auth.ScopeCertsRead, h.HandleList)
+	handle(mux, "GET /certificates/search", auth.ScopeCertsRead, h.HandleSearch)
+	handle(mux, "GET /certificates/summary", auth.ScopeCertsRead, h.HandleSummary)
+	handle(mux, "GET /certificates/{id}", auth.ScopeCertsRead, h.HandleGet)
+	handle(mux, "GET /certificates/{id}/chain", auth.ScopeCertsRead, h.HandleGetChain)
+	handle(mux, "GET /certificates/{id}/issued", auth.ScopeCertsRead, h.HandleListIssued)
+	handle(mux, "PATCH /certificates/{id}", auth.ScopeCertsWrite, h.HandleUpdate)
+	handle(mux, "DELETE /certificates/{id}", auth.ScopeCertsWrite, h.HandleDelete)
+}
+
+func (h *EndpointHandler) RegisterRoutes(mux *http.ServeMux) {
+	handle(mux, "POST /endpoints", auth.ScopeCertsWrite, h.HandleCreate)
+	handle(mux, "GET /endpoints", auth.ScopeCertsRead, h.HandleList)
+	handle(mux, "DELETE /endpoints/{id}", auth.ScopeCertsWrite, h.HandleDelete)
+}
 
-	loggedMux := middleware.LoggingMiddlewarefunc(h.logger)(mux)
+func (h *AlertHandler) RegisterRoutes(mux *http.ServeMux) {
+	handle(mux, "GET /alerts", auth.ScopeCertsRead, h.HandleList)
+	handle(mux, "POST /alerts/{id}/ack", auth.ScopeCertsWrite, h.HandleAcknowledge)
+}
+
+func (h *NotificationHandler) RegisterRoutes(mux *http.ServeMux) {
+	handle(mux, "GET /notifications/deliveries", auth.ScopeCertsRead, h.HandleListDeliveries)
+}
+
+func (h *FindingHandler) RegisterRoutes(mux *http.ServeMux) {
+	handle(mux, "GET /certificates/{id}/findings", auth.ScopeCertsRead, h.HandleListForCertificate)
+	handle(mux, "GET /findings", auth.ScopeCertsRead, h.HandleList)
+}
+
+func (h *AuditHandler) RegisterRoutes(mux *http.ServeMux) {
+	handle(mux, "GET /audit", auth.ScopeAdmin, h.HandleList)
+	handle(mux, "GET /audit/verify", auth.ScopeAdmin, h.HandleVerify)
+}
+
+func (h *RateLimitHandler) RegisterRoutes(mux *http.ServeMux) {
+	handle(mux, "GET /rate-limits", auth.ScopeAdmin, h.HandleCounts)
+}
+
+func (h *MetricsHandler) RegisterRoutes(mux *http.ServeMux) {
+	handle(mux, "GET /metrics", auth.ScopeCertsRead, h.HandleMetrics)
+}
 
-	return loggedMux
+func (h *APIKeyHandler) RegisterRoutes(mux *http.ServeMux) {
+	handle(mux, "POST /api-keys", auth.ScopeAdmin, h.HandleCreate)
+	handle(mux, "GET /api-keys", auth.ScopeAdmin, h.HandleList)
+	handle(mux, "DELETE /api-keys/{id}", auth.ScopeAdmin, h.HandleRevoke)
 }
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/db"
+	"github.com/hytonhan/certwatch/internal/middleware"
+	"github.com/hytonhan/certwatch/internal/repository"
+	"github.com/hytonhan/certwatch/internal/service"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+// newTestRouter serves the certificate and API key routes over a fresh
+// database, and returns it with a token per role.
+func newTestRouter(t *testing.T) (http.Handler, map[string]string) {
+	t.Helper()
+	ctx := context.Background()
+	sqlDB, err := db.NewSQLite(ctx, t.TempDir()+"/certwatch.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.RunMigrations(ctx, sqlDB); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auditRepo := repository.NewAuditRepository(sqlDB)
+	certSrv := service.New(repository.NewCertificateRepository(sqlDB), service.WithAudit(auditRepo))
+	apiKeySrv := service.NewAPIKeyService(repository.NewAPIKeyRepository(sqlDB))
+
+	tokens := map[string]string{}
+	for _, input := range []dto.CreateAPIKeyInput{
+		{Name: "viewer", Scopes: []string{"certs:read"}, Team: "payments"},
+		{Name: "editor", Scopes: []string{"certs:read", "certs:write"}, Team: "payments"},
+		{Name: "admin", Scopes: []string{"admin"}},
+	} {
+		created, err := apiKeySrv.Create(ctx, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[input.Name] = created.Token
+	}
+
+	router := NewRouter(logger, []middleware.Authenticator{apiKeySrv}, nil, auditRepo, nil, nil,
+		NewCertificateHandler(certSrv, logger),
+		NewAPIKeyHandler(apiKeySrv, logger))
+	return router, tokens
+}
+
+func TestRouterRoles(t *testing.T) {
+	router, tokens := newTestRouter(t)
+	created := 0
+	createBody := func(owner string) string {
+		created++
+		now := time.Now().UTC()
+		return fmt.Sprintf(`{"common_name": "api.example.com", "serial_number": "%d", "issuer": "Test CA",
+			"not_before": %q, "not_after": %q, "fingerprintsha256": "%064x", "owner_team": %q}`,
+			created, now.Add(-time.Hour).Format(time.RFC3339), now.Add(24*time.Hour).Format(time.RFC3339), created, owner)
+	}
+
+	tests := []struct {
+		name   string
+		caller string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"anonymous health", "", http.MethodGet, "/health", "", http.StatusOK},
+		{"anonymous write", "", http.MethodPost, "/certificates", createBody("payments"), http.StatusUnauthorized},
+		{"anonymous admin route", "", http.MethodGet, "/api-keys", "", http.StatusUnauthorized},
+		{"invalid token", "invalid", http.MethodGet, "/api-keys", "", http.StatusUnauthorized},
+		{"viewer health", "viewer", http.MethodGet, "/health", "", http.StatusOK},
+		{"viewer write", "viewer", http.MethodPost, "/certificates", createBody("payments"), http.StatusForbidden},
+		{"viewer admin route", "viewer", http.MethodGet, "/api-keys", "", http.StatusForbidden},
+		{"editor health", "editor", http.MethodGet, "/health", "", http.StatusOK},
+		{"editor write for its team", "editor", http.MethodPost, "/certificates", createBody("payments"), http.StatusCreated},
+		{"editor write for other team", "editor", http.MethodPost, "/certificates", createBody("billing"), http.StatusForbidden},
+		{"editor admin route", "editor", http.MethodGet, "/api-keys", "", http.StatusForbidden},
+		{"admin health", "admin", http.MethodGet, "/health", "", http.StatusOK},
+		{"admin write", "admin", http.MethodPost, "/certificates", createBody("billing"), http.StatusCreated},
+		{"admin admin route", "admin", http.MethodGet, "/api-keys", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			switch test.caller {
+			case "":
+			case "invalid":
+				r.Header.Set("Authorization", "Bearer cw_invalid")
+			default:
+				r.Header.Set("Authorization", "Bearer "+tokens[test.caller])
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != test.want {
+				t.Errorf("%s %s as %q = %d; want %d: %s", test.method, test.path, test.caller, w.Code, test.want, w.Body)
+			}
+		})
+	}
+}
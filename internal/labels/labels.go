@@ -0,0 +1,263 @@
+package labels
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	maxKeyLength    = 63
+	maxPrefixLength = 253
+	maxValueLength  = 63
+	// MaxLabels caps how many labels a single certificate may carry.
+	MaxLabels = 32
+	// maxRequirements caps the size of a selector, since every requirement
+	// becomes a subquery.
+	maxRequirements = 16
+)
+
+var (
+	ErrInvalidLabel    = errors.New("invalid label")
+	ErrInvalidSelector = errors.New("invalid selector")
+)
+
+var (
+	namePattern   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	prefixPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+)
+
+type Operator string
+
+const (
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+)
+
+// Requirement is one term of a selector, e.g. env=prod or region in (eu,us).
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+// Selector is a list of requirements that must all hold. The empty selector
+// matches everything.
+type Selector []Requirement
+
+// ValidKey reports whether key is a valid label key: a name of at most 63
+// characters, optionally preceded by a DNS subdomain prefix and a slash,
+// as in example.com/team.
+func ValidKey(key string) bool {
+	prefix, name, found := strings.Cut(key, "/")
+	if !found {
+		name = prefix
+	} else if len(prefix) > maxPrefixLength || !prefixPattern.MatchString(prefix) {
+		return false
+	}
+	return len(name) <= maxKeyLength && namePattern.MatchString(name)
+}
+
+// ValidValue reports whether value is a valid label value. The empty value
+// is allowed.
+func ValidValue(value string) bool {
+	return value == "" || (len(value) <= maxValueLength && namePattern.MatchString(value))
+}
+
+// Validate checks every key and value of the label set.
+func Validate(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return ErrInvalidLabel
+	}
+	for key, value := range labels {
+		if !ValidKey(key) || !ValidValue(value) {
+			return ErrInvalidLabel
+		}
+	}
+	return nil
+}
+
+// Parse reads a label set written as "env=prod,app=payments".
+func Parse(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	if strings.TrimSpace(s) == "" {
+		return labels, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		key, value, found := strings.Cut(part, "=")
+		key = strings.TrimSpace(key)
+		if !found {
+			return nil, ErrInvalidLabel
+		}
+		if _, dup := labels[key]; dup {
+			return nil, ErrInvalidLabel
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+	if err := Validate(labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// ParseSelector parses a comma separated label selector. Supported terms are
+// key=value (or key==value), key!=value, key in (a,b), key notin (a,b), key
+// and !key. As with Kubernetes, != and notin also match certificates that do
+// not carry the key at all.
+func ParseSelector(s string) (Selector, error) {
+	selector := Selector{}
+	if strings.TrimSpace(s) == "" {
+		return selector, nil
+	}
+	terms, err := splitTerms(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) > maxRequirements {
+		return nil, ErrInvalidSelector
+	}
+	for _, term := range terms {
+		req, err := parseRequirement(strings.TrimSpace(term))
+		if err != nil {
+			return nil, err
+		}
+		selector = append(selector, req)
+	}
+	return selector, nil
+}
+
+// splitTerms splits on the commas that are not inside a value list.
+func splitTerms(s string) ([]string, error) {
+	terms := []string{}
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+			if depth > 1 {
+				return nil, ErrInvalidSelector
+			}
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, ErrInvalidSelector
+			}
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, ErrInvalidSelector
+	}
+	return append(terms, s[start:]), nil
+}
+
+func parseRequirement(term string) (Requirement, error) {
+	if key, found := strings.CutPrefix(term, "!"); found {
+		return newRequirement(strings.TrimSpace(key), DoesNotExist, nil)
+	}
+	if key, value, found := strings.Cut(term, "!="); found {
+		return newRequirement(strings.TrimSpace(key), NotEquals, []string{strings.TrimSpace(value)})
+	}
+	if key, value, found := strings.Cut(term, "=="); found {
+		return newRequirement(strings.TrimSpace(key), Equals, []string{strings.TrimSpace(value)})
+	}
+	if key, value, found := strings.Cut(term, "="); found {
+		return newRequirement(strings.TrimSpace(key), Equals, []string{strings.TrimSpace(value)})
+	}
+
+	fields := strings.Fields(term)
+	if len(fields) == 1 && !strings.ContainsAny(term, "()") {
+		return newRequirement(fields[0], Exists, nil)
+	}
+	if len(fields) < 2 {
+		return Requirement{}, ErrInvalidSelector
+	}
+	key := fields[0]
+	rest := strings.TrimSpace(strings.TrimPrefix(term, key))
+	var op Operator
+	switch {
+	case strings.HasPrefix(rest, "notin"):
+		op, rest = NotIn, strings.TrimPrefix(rest, "notin")
+	case strings.HasPrefix(rest, "in"):
+		op, rest = In, strings.TrimPrefix(rest, "in")
+	default:
+		return Requirement{}, ErrInvalidSelector
+	}
+	rest = strings.TrimSpace(rest)
+	if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+		return Requirement{}, ErrInvalidSelector
+	}
+	values := []string{}
+	for _, value := range strings.Split(rest[1:len(rest)-1], ",") {
+		values = append(values, strings.TrimSpace(value))
+	}
+	return newRequirement(key, op, values)
+}
+
+func newRequirement(key string, op Operator, values []string) (Requirement, error) {
+	if !ValidKey(key) {
+		return Requirement{}, ErrInvalidSelector
+	}
+	if len(values) > MaxLabels {
+		return Requirement{}, ErrInvalidSelector
+	}
+	for _, value := range values {
+		if !ValidValue(value) {
+			return Requirement{}, ErrInvalidSelector
+		}
+	}
+	sort.Strings(values)
+	return Requirement{Key: key, Operator: op, Values: values}, nil
+}
+
+// Matches reports whether the label set satisfies every requirement.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	case Equals, In:
+		return ok && slices.Contains(r.Values, value)
+	case NotEquals, NotIn:
+		return !ok || !slices.Contains(r.Values, value)
+	}
+	return false
+}
+
+func (s Selector) String() string {
+	terms := make([]string, 0, len(s))
+	for _, req := range s {
+		switch req.Operator {
+		case Exists:
+			terms = append(terms, req.Key)
+		case DoesNotExist:
+			terms = append(terms, "!"+req.Key)
+		case Equals, NotEquals:
+			terms = append(terms, req.Key+string(req.Operator)+req.Values[0])
+		default:
+			terms = append(terms, req.Key+" "+string(req.Operator)+" ("+strings.Join(req.Values, ",")+")")
+		}
+	}
+	return strings.Join(terms, ",")
+}
@@ -0,0 +1,105 @@
+package labels
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		err      error
+	}{
+		{"empty", "", "", nil},
+		{"equals", "env=prod", "env=prod", nil},
+		{"double equals", "env==prod", "env=prod", nil},
+		{"not equals", "env=prod,app!=legacy", "env=prod,app!=legacy", nil},
+		{"in", "region in (us, eu)", "region in (eu,us)", nil},
+		{"notin", "region notin (eu)", "region notin (eu)", nil},
+		{"exists", "app", "app", nil},
+		{"does not exist", "!app", "!app", nil},
+		{"prefixed key", "example.com/team=payments", "example.com/team=payments", nil},
+		{"empty value", "env=", "env=", nil},
+		{"mixed", "env=prod, region in (eu,us), !legacy", "env=prod,region in (eu,us),!legacy", nil},
+		{"invalid key", "env prod=x", "", ErrInvalidSelector},
+		{"invalid value", "env=pr'od", "", ErrInvalidSelector},
+		{"sql in value", "env=x' OR 1=1", "", ErrInvalidSelector},
+		{"unbalanced parens", "region in (eu", "", ErrInvalidSelector},
+		{"nested parens", "region in ((eu))", "", ErrInvalidSelector},
+		{"unknown operator", "region within (eu)", "", ErrInvalidSelector},
+		{"empty term", "env=prod,", "", ErrInvalidSelector},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selector, err := ParseSelector(test.input)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("ParseSelector(%q) error = %v; want %v", test.input, err, test.err)
+			}
+			if err == nil && selector.String() != test.expected {
+				t.Errorf("ParseSelector(%q) = %q; want %q", test.input, selector.String(), test.expected)
+			}
+		})
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"env": "prod", "app": "payments", "region": "eu"}
+	tests := []struct {
+		selector string
+		expected bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env=dev", false},
+		{"env=prod,app!=legacy", true},
+		{"app!=payments", false},
+		{"tier!=gold", true},
+		{"region in (eu,us)", true},
+		{"region notin (eu)", false},
+		{"tier notin (gold)", true},
+		{"app", true},
+		{"tier", false},
+		{"!tier", true},
+		{"!app", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.selector, func(t *testing.T) {
+			selector, err := ParseSelector(test.selector)
+			if err != nil {
+				t.Fatalf("ParseSelector(%q) error = %v", test.selector, err)
+			}
+			if got := selector.Matches(labels); got != test.expected {
+				t.Errorf("Matches(%q) = %v; want %v", test.selector, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{"empty", "", nil},
+		{"single", "env=prod", nil},
+		{"several", "env=prod, app=payments", nil},
+		{"missing value separator", "env", ErrInvalidLabel},
+		{"duplicate key", "env=prod,env=dev", ErrInvalidLabel},
+		{"invalid value", "env=prod dev", ErrInvalidLabel},
+		{"key too long", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa=x", ErrInvalidLabel},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.input)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Parse(%q) error = %v; want %v", test.input, err, test.err)
+			}
+		})
+	}
+}
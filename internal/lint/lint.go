@@ -0,0 +1,224 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/labels"
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+var ErrInvalidConfig = errors.New("invalid lint config")
+
+var rulePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9_.]{0,62}[a-z0-9])?$`)
+
+// Check is the kind of test a rule performs.
+type Check string
+
+const (
+	// CheckMinKeySize flags keys of KeyAlgorithm smaller than MinBits.
+	CheckMinKeySize Check = "min_key_size"
+	// CheckSignatureAlgorithm flags certificates signed with one of Algorithms.
+	CheckSignatureAlgorithm Check = "signature_algorithm"
+	// CheckMaxValidity flags leaves valid for longer than MaxDays.
+	CheckMaxValidity Check = "max_validity"
+	// CheckWildcard flags leaves with a wildcard DNS name.
+	CheckWildcard Check = "wildcard"
+	// CheckMissingSANs flags leaves without subject alternative names. Only
+	// certificates imported from their PEM are checked.
+	CheckMissingSANs Check = "missing_sans"
+	// CheckSelfSignedLeaf flags self-signed certificates that are not CAs.
+	CheckSelfSignedLeaf Check = "self_signed_leaf"
+)
+
+// Rule is one policy rule of the config file. Selector limits the rule to
+// certificates whose labels match; the other parameters depend on Check.
+type Rule struct {
+	Id           string         `json:"id"`
+	Check        Check          `json:"check"`
+	Severity     model.Severity `json:"severity"`
+	Selector     string         `json:"selector,omitempty"`
+	KeyAlgorithm string         `json:"key_algorithm,omitempty"`
+	MinBits      int            `json:"min_bits,omitempty"`
+	Algorithms   []string       `json:"algorithms,omitempty"`
+	MaxDays      int            `json:"max_days,omitempty"`
+
+	selector labels.Selector
+}
+
+type config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// DefaultRules are used when no config file is given.
+func DefaultRules() []Rule {
+	return []Rule{
+		{Id: "rsa-key-too-small", Check: CheckMinKeySize, Severity: model.SeverityHigh, KeyAlgorithm: "RSA", MinBits: 2048},
+		{Id: "sha1-signature", Check: CheckSignatureAlgorithm, Severity: model.SeverityHigh, Algorithms: []string{"SHA1-RSA", "DSA-SHA1", "ECDSA-SHA1", "MD5-RSA", "MD2-RSA"}},
+		{Id: "validity-too-long", Check: CheckMaxValidity, Severity: model.SeverityWarning, MaxDays: 398},
+		{Id: "wildcard-in-prod", Check: CheckWildcard, Severity: model.SeverityWarning, Selector: "env=prod"},
+		{Id: "missing-sans", Check: CheckMissingSANs, Severity: model.SeverityWarning},
+		{Id: "self-signed-leaf", Check: CheckSelfSignedLeaf, Severity: model.SeverityHigh},
+	}
+}
+
+// Linter evaluates certificates against a fixed set of rules.
+type Linter struct {
+	rules []Rule
+}
+
+// New validates the rules. Rule ids must be unique, since findings are
+// stored per certificate and rule.
+func New(rules []Rule) (*Linter, error) {
+	seen := map[string]bool{}
+	compiled := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		if err := validate(&rule); err != nil {
+			return nil, err
+		}
+		if seen[rule.Id] {
+			return nil, fmt.Errorf("%w: duplicate rule %q", ErrInvalidConfig, rule.Id)
+		}
+		seen[rule.Id] = true
+		compiled = append(compiled, rule)
+	}
+	return &Linter{rules: compiled}, nil
+}
+
+// Parse reads a JSON config of the form {"rules": [...]}. Unknown fields are
+// rejected, so a misspelt parameter cannot silently disable a check.
+func Parse(data []byte) (*Linter, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var cfg config
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return New(cfg.Rules)
+}
+
+// Load reads the config file at path, or uses the default rules when path is
+// empty.
+func Load(path string) (*Linter, error) {
+	if path == "" {
+		return New(DefaultRules())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func (l *Linter) Rules() []Rule {
+	return slices.Clone(l.rules)
+}
+
+// Lint returns a finding for every rule the certificate violates, in rule
+// order.
+func (l *Linter) Lint(cert model.Certificate) []model.Finding {
+	findings := []model.Finding{}
+	for _, rule := range l.rules {
+		if !rule.selector.Matches(cert.Labels) {
+			continue
+		}
+		message, violated := rule.evaluate(cert)
+		if !violated {
+			continue
+		}
+		findings = append(findings, model.Finding{
+			CertificateId: cert.Id,
+			CommonName:    cert.CommonName,
+			RuleId:        rule.Id,
+			Severity:      rule.Severity,
+			Message:       message,
+		})
+	}
+	return findings
+}
+
+func (r Rule) evaluate(cert model.Certificate) (string, bool) {
+	switch r.Check {
+	case CheckMinKeySize:
+		if strings.EqualFold(cert.KeyAlgorithm, r.KeyAlgorithm) && cert.KeySize > 0 && cert.KeySize < r.MinBits {
+			return fmt.Sprintf("%s key of %d bits, minimum is %d", cert.KeyAlgorithm, cert.KeySize, r.MinBits), true
+		}
+	case CheckSignatureAlgorithm:
+		for _, algorithm := range r.Algorithms {
+			if strings.EqualFold(cert.SignatureAlgorithm, algorithm) {
+				return "signed with " + cert.SignatureAlgorithm, true
+			}
+		}
+	case CheckMaxValidity:
+		validity := cert.NotAfter.Sub(cert.NotBefore)
+		if !cert.IsCA && validity > time.Duration(r.MaxDays)*24*time.Hour {
+			return fmt.Sprintf("valid for %d days, maximum is %d", int(validity.Hours()/24), r.MaxDays), true
+		}
+	case CheckWildcard:
+		if cert.IsCA {
+			return "", false
+		}
+		for _, name := range append([]string{cert.CommonName}, cert.DNSNames...) {
+			if strings.HasPrefix(name, "*.") {
+				return "wildcard name " + name, true
+			}
+		}
+	case CheckMissingSANs:
+		// Only the PEM tells for sure: a certificate registered by hand, or
+		// before SANs were recorded, may have SANs certwatch does not know.
+		if cert.PEM == "" {
+			return "", false
+		}
+		total := len(cert.DNSNames) + len(cert.IPAddresses) + len(cert.URIs) + len(cert.EmailAddresses)
+		if !cert.IsCA && total == 0 {
+			return "no subject alternative names", true
+		}
+	case CheckSelfSignedLeaf:
+		if !cert.IsCA && cert.SelfSigned {
+			return "self-signed end-entity certificate", true
+		}
+	}
+	return "", false
+}
+
+func validate(rule *Rule) error {
+	if !rulePattern.MatchString(rule.Id) {
+		return fmt.Errorf("%w: invalid rule id %q", ErrInvalidConfig, rule.Id)
+	}
+	switch rule.Severity {
+	case model.SeverityInfo, model.SeverityWarning, model.SeverityHigh, model.SeverityCritical:
+	default:
+		return fmt.Errorf("%w: rule %s: invalid severity %q", ErrInvalidConfig, rule.Id, rule.Severity)
+	}
+	selector, err := labels.ParseSelector(rule.Selector)
+	if err != nil {
+		return fmt.Errorf("%w: rule %s: %v", ErrInvalidConfig, rule.Id, err)
+	}
+	rule.selector = selector
+
+	switch rule.Check {
+	case CheckMinKeySize:
+		if rule.KeyAlgorithm == "" || rule.MinBits <= 0 {
+			return fmt.Errorf("%w: rule %s: key_algorithm and min_bits are required", ErrInvalidConfig, rule.Id)
+		}
+	case CheckSignatureAlgorithm:
+		if len(rule.Algorithms) == 0 {
+			return fmt.Errorf("%w: rule %s: algorithms are required", ErrInvalidConfig, rule.Id)
+		}
+	case CheckMaxValidity:
+		if rule.MaxDays <= 0 {
+			return fmt.Errorf("%w: rule %s: max_days is required", ErrInvalidConfig, rule.Id)
+		}
+	case CheckWildcard, CheckMissingSANs, CheckSelfSignedLeaf:
+	default:
+		return fmt.Errorf("%w: rule %s: unknown check %q", ErrInvalidConfig, rule.Id, rule.Check)
+	}
+	return nil
+}
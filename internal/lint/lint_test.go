@@ -0,0 +1,100 @@
+package lint
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{"empty", `{"rules": []}`, nil},
+		{"valid", `{"rules": [{"id": "rsa", "check": "min_key_size", "severity": "high", "key_algorithm": "RSA", "min_bits": 2048}]}`, nil},
+		{"with selector", `{"rules": [{"id": "wild", "check": "wildcard", "severity": "info", "selector": "env in (prod,staging)"}]}`, nil},
+		{"not json", `rules: []`, ErrInvalidConfig},
+		{"unknown field", `{"rules": [{"id": "rsa", "check": "min_key_size", "severity": "high", "key_algorithm": "RSA", "min_bit": 2048}]}`, ErrInvalidConfig},
+		{"unknown check", `{"rules": [{"id": "x", "check": "magic", "severity": "high"}]}`, ErrInvalidConfig},
+		{"unknown severity", `{"rules": [{"id": "x", "check": "wildcard", "severity": "urgent"}]}`, ErrInvalidConfig},
+		{"missing parameter", `{"rules": [{"id": "x", "check": "max_validity", "severity": "info"}]}`, ErrInvalidConfig},
+		{"invalid id", `{"rules": [{"id": "Bad Id", "check": "wildcard", "severity": "info"}]}`, ErrInvalidConfig},
+		{"duplicate id", `{"rules": [{"id": "x", "check": "wildcard", "severity": "info"}, {"id": "x", "check": "missing_sans", "severity": "info"}]}`, ErrInvalidConfig},
+		{"invalid selector", `{"rules": [{"id": "x", "check": "wildcard", "severity": "info", "selector": "env=pr'od"}]}`, ErrInvalidConfig},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.input))
+			if !errors.Is(err, test.err) {
+				t.Errorf("Parse() error = %v; want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestLint(t *testing.T) {
+	linter, err := New(DefaultRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	good := model.Certificate{
+		Id:                 "id1",
+		CommonName:         "api.example.com",
+		NotBefore:          now,
+		NotAfter:           now.Add(90 * 24 * time.Hour),
+		KeyAlgorithm:       "ECDSA",
+		KeySize:            256,
+		SignatureAlgorithm: "ECDSA-SHA256",
+		DNSNames:           []string{"api.example.com"},
+		Labels:             map[string]string{"env": "prod"},
+		PEM:                "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n",
+	}
+	tests := []struct {
+		name     string
+		modify   func(cert *model.Certificate)
+		expected []string
+	}{
+		{"compliant", func(cert *model.Certificate) {}, []string{}},
+		{"small rsa key", func(cert *model.Certificate) {
+			cert.KeyAlgorithm, cert.KeySize, cert.SignatureAlgorithm = "RSA", 1024, "SHA256-RSA"
+		}, []string{"rsa-key-too-small"}},
+		{"unknown key size", func(cert *model.Certificate) { cert.KeyAlgorithm, cert.KeySize = "", 0 }, []string{}},
+		{"sha1", func(cert *model.Certificate) { cert.SignatureAlgorithm = "SHA1-RSA" }, []string{"sha1-signature"}},
+		{"long validity", func(cert *model.Certificate) { cert.NotAfter = now.Add(825 * 24 * time.Hour) }, []string{"validity-too-long"}},
+		{"long validity ca", func(cert *model.Certificate) {
+			cert.NotAfter, cert.IsCA, cert.DNSNames = now.Add(3650*24*time.Hour), true, []string{}
+		}, []string{}},
+		{"wildcard in prod", func(cert *model.Certificate) { cert.DNSNames = []string{"*.example.com"} }, []string{"wildcard-in-prod"}},
+		{"wildcard in dev", func(cert *model.Certificate) {
+			cert.DNSNames, cert.Labels = []string{"*.example.com"}, map[string]string{"env": "dev"}
+		}, []string{}},
+		{"missing sans", func(cert *model.Certificate) { cert.DNSNames = []string{} }, []string{"missing-sans"}},
+		{"unknown sans", func(cert *model.Certificate) { cert.DNSNames, cert.PEM = []string{}, "" }, []string{}},
+		{"self-signed leaf", func(cert *model.Certificate) { cert.SelfSigned = true }, []string{"self-signed-leaf"}},
+		{"self-signed root", func(cert *model.Certificate) { cert.SelfSigned, cert.IsCA = true, true }, []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cert := good
+			test.modify(&cert)
+			ids := []string{}
+			for _, finding := range linter.Lint(cert) {
+				ids = append(ids, finding.RuleId)
+				if finding.CertificateId != cert.Id || finding.Message == "" {
+					t.Errorf("Lint() finding = %+v; want certificate id and message", finding)
+				}
+			}
+			if !slices.Equal(ids, test.expected) {
+				t.Errorf("Lint() = %v; want %v", ids, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Counter is a counter with a value per combination of label values. The
+// methods of a nil Counter do nothing, so that code can be measured or not.
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*counterSeries
+	order  []string
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+func NewCounter(name string, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, series: map[string]*counterSeries{}}
+	// A counter without labels is reported as zero before its first increment.
+	if len(labels) == 0 {
+		c.Add(0)
+	}
+	return c
+}
+
+// Inc adds one to the counter of the label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative, to the counter of the label values.
+func (c *Counter) Add(v float64, values ...string) {
+	if c == nil {
+		return
+	}
+	checkValues(c.name, c.labels, values)
+	key := seriesKey(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: slices.Clone(values)}
+		c.series[key] = s
+		c.order = append(c.order, key)
+	}
+	s.value += v
+}
+
+func (c *Counter) Collect(ctx context.Context) ([]Family, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	family := Family{Name: c.name, Help: c.help, Type: TypeCounter}
+	for _, key := range c.order {
+		s := c.series[key]
+		family.Samples = append(family.Samples, Sample{Labels: labelsOf(c.labels, s.values), Value: s.value})
+	}
+	return []Family{family}, nil
+}
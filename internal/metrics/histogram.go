@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+)
+
+// DurationBuckets suit durations in seconds of up to ten seconds, such as
+// request latencies.
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram counts observations in buckets, per combination of label values.
+// The methods of a nil Histogram do nothing.
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+	labels  []string
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+	order  []string
+}
+
+type histogramSeries struct {
+	values []string
+	// counts[i] counts the observations in buckets[i] but not the buckets
+	// below; the last count is for observations above every bucket.
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram returns a histogram with the upper bounds of buckets, which
+// must be sorted.
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{name: name, help: help, buckets: buckets, labels: labels, series: map[string]*histogramSeries{}}
+	// Like counters, a histogram without labels is reported before the first
+	// observation.
+	if len(labels) == 0 {
+		h.newSeries(nil)
+	}
+	return h
+}
+
+// Observe records v in the histogram of the label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	if h == nil {
+		return
+	}
+	checkValues(h.name, h.labels, values)
+	key := seriesKey(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = h.newSeries(values)
+	}
+	i, _ := slices.BinarySearch(h.buckets, v)
+	s.counts[i]++
+	s.sum += v
+	s.count++
+}
+
+func (h *Histogram) newSeries(values []string) *histogramSeries {
+	key := seriesKey(values)
+	s := &histogramSeries{values: slices.Clone(values), counts: make([]uint64, len(h.buckets)+1)}
+	h.series[key] = s
+	h.order = append(h.order, key)
+	return s
+}
+
+func (h *Histogram) Collect(ctx context.Context) ([]Family, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	family := Family{Name: h.name, Help: h.help, Type: TypeHistogram}
+	for _, key := range h.order {
+		s := h.series[key]
+		labels := labelsOf(h.labels, s.values)
+		var cumulative uint64
+		for i, bound := range append(slices.Clone(h.buckets), math.Inf(1)) {
+			cumulative += s.counts[i]
+			le := append(slices.Clone(labels), Label{Name: "le", Value: formatValue(bound)})
+			family.Samples = append(family.Samples, Sample{Suffix: "_bucket", Labels: le, Value: float64(cumulative)})
+		}
+		family.Samples = append(family.Samples,
+			Sample{Suffix: "_sum", Labels: labels, Value: s.sum},
+			Sample{Suffix: "_count", Labels: labels, Value: float64(s.count)})
+	}
+	return []Family{family}, nil
+}
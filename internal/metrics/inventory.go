@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+const day = 24 * time.Hour
+
+// ExpiryWindows are the expiry buckets besides "expired".
+var ExpiryWindows = []time.Duration{7 * day, 30 * day, 90 * day}
+
+// ExpirySummarizer is implemented by the certificate service.
+type ExpirySummarizer interface {
+	SummarizeExpiry(ctx context.Context, windows []time.Duration) (*model.ExpirySummary, error)
+}
+
+// InventoryCollector reports the inventory by expiry and issuer, read from
+// the database on every scrape.
+type InventoryCollector struct {
+	certs ExpirySummarizer
+	now   func() time.Time
+}
+
+func NewInventoryCollector(certs ExpirySummarizer) *InventoryCollector {
+	return &InventoryCollector{certs: certs, now: time.Now}
+}
+
+func (ic *InventoryCollector) Collect(ctx context.Context) ([]Family, error) {
+	summary, err := ic.certs.SummarizeExpiry(ctx, ExpiryWindows)
+	if err != nil {
+		return nil, fmt.Errorf("Collecting inventory metrics: %w", err)
+	}
+
+	byExpiry := Family{
+		Name: "certwatch_certificates_by_expiry",
+		Help: "Certificates expired, and not expired but expiring within the bucket.",
+		Type: TypeGauge,
+		Samples: []Sample{{
+			Labels: []Label{{Name: "bucket", Value: "expired"}},
+			Value:  float64(summary.Expired),
+		}},
+	}
+	for _, window := range ExpiryWindows {
+		byExpiry.Samples = append(byExpiry.Samples, Sample{
+			Labels: []Label{{Name: "bucket", Value: strconv.Itoa(int(window/day)) + "d"}},
+			Value:  float64(summary.ExpiringWithin[window]),
+		})
+	}
+
+	byIssuer := Family{
+		Name: "certwatch_certificates_by_issuer",
+		Help: "Certificates by issuer distinguished name.",
+		Type: TypeGauge,
+	}
+	for _, issuer := range summary.Issuers {
+		byIssuer.Samples = append(byIssuer.Samples, Sample{
+			Labels: []Label{{Name: "issuer", Value: issuer.Issuer}},
+			Value:  float64(issuer.Count),
+		})
+	}
+
+	// Without a certificate left to expire, the gauge has no value rather
+	// than a misleading one.
+	soonest := Family{
+		Name: "certwatch_certificate_soonest_expiry_seconds",
+		Help: "Seconds until the next certificate expires.",
+		Type: TypeGauge,
+	}
+	if !summary.SoonestExpiry.IsZero() {
+		soonest.Samples = []Sample{{Value: summary.SoonestExpiry.Sub(ic.now()).Seconds()}}
+	}
+
+	return []Family{byExpiry, byIssuer, soonest}, nil
+}
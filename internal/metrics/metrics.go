@@ -0,0 +1,172 @@
+// Package metrics exposes metrics in the Prometheus text format, without the
+// Prometheus client library. Counters and histograms are kept by the code
+// they measure; values that are cheaper to read on demand, such as counts
+// from the database, come from collectors run on every scrape.
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types of the text format.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// Family is a metric with all its samples.
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Sample is one value of a family. Suffix is appended to the family name,
+// e.g. "_bucket" for histograms.
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Collector returns families on every scrape.
+type Collector interface {
+	Collect(ctx context.Context) ([]Family, error)
+}
+
+// CollectorFunc adapts a function to a Collector.
+type CollectorFunc func(ctx context.Context) ([]Family, error)
+
+func (f CollectorFunc) Collect(ctx context.Context) ([]Family, error) {
+	return f(ctx)
+}
+
+// Registry is the set of collectors /metrics serves.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds collectors; nil ones are skipped, so that optional metrics
+// need no checks.
+func (r *Registry) Register(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range collectors {
+		if c != nil {
+			r.collectors = append(r.collectors, c)
+		}
+	}
+}
+
+// Gather runs every collector. A failing collector does not keep the others
+// from being reported; the first error is returned with what was gathered.
+func (r *Registry) Gather(ctx context.Context) ([]Family, error) {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+
+	var families []Family
+	var firstErr error
+	for _, c := range collectors {
+		collected, err := c.Collect(ctx)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		families = append(families, collected...)
+	}
+	return families, firstErr
+}
+
+// Write writes the families in the text exposition format.
+func Write(w io.Writer, families []Family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.Name, f.Type)
+		for _, s := range f.Samples {
+			bw.WriteString(f.Name + s.Suffix)
+			if len(s.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(l.Name + `="` + escapeLabelValue(l.Value) + `"`)
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteString(" " + formatValue(s.Value) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// labelsOf pairs label names with values.
+func labelsOf(names []string, values []string) []Label {
+	labels := make([]Label, len(names))
+	for i, name := range names {
+		labels[i] = Label{Name: name, Value: values[i]}
+	}
+	return labels
+}
+
+// seriesKey identifies a combination of label values.
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// checkValues panics on a mismatched number of label values, a programming
+// error that would otherwise produce a broken exposition.
+func checkValues(name string, names []string, values []string) {
+	if len(names) != len(values) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", name, len(names), len(values)))
+	}
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+func gather(t *testing.T, collectors ...Collector) string {
+	t.Helper()
+	registry := NewRegistry()
+	registry.Register(collectors...)
+	families, err := registry.Gather(context.Background())
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	var b strings.Builder
+	if err := Write(&b, families); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestCounter(t *testing.T) {
+	requests := NewCounter("requests_total", "Requests.", "route", "status")
+	requests.Inc("GET /a", "200")
+	requests.Inc("GET /a", "200")
+	requests.Add(3, "GET /b", "500")
+	var unused *Counter
+	unused.Inc()
+
+	want := `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{route="GET /a",status="200"} 2
+requests_total{route="GET /b",status="500"} 3
+`
+	if got := gather(t, requests, nil); got != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	latency := NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1})
+	latency.Observe(0.05)
+	latency.Observe(0.1)
+	latency.Observe(0.5)
+	latency.Observe(2)
+
+	want := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="1"} 3
+latency_seconds_bucket{le="+Inf"} 4
+latency_seconds_sum 2.65
+latency_seconds_count 4
+`
+	if got := gather(t, latency); got != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteEscapes(t *testing.T) {
+	families := []Family{{
+		Name:    "issuers",
+		Help:    "Back\\slash and\nnewline.",
+		Type:    TypeGauge,
+		Samples: []Sample{{Labels: []Label{{Name: "issuer", Value: "CN=\"Evil\"\\\nCA"}}, Value: 1}},
+	}}
+	var b strings.Builder
+	Write(&b, families)
+
+	want := `# HELP issuers Back\\slash and\nnewline.
+# TYPE issuers gauge
+issuers{issuer="CN=\"Evil\"\\\nCA"} 1
+`
+	if b.String() != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestGatherKeepsOthersOnError(t *testing.T) {
+	registry := NewRegistry()
+	failing := CollectorFunc(func(ctx context.Context) ([]Family, error) {
+		return nil, errors.New("database is locked")
+	})
+	registry.Register(failing, NewCounter("ok_total", "OK."))
+
+	families, err := registry.Gather(context.Background())
+	if err == nil {
+		t.Error("Gather() error = nil; want the collector's error")
+	}
+	if len(families) != 1 || families[0].Name != "ok_total" {
+		t.Errorf("Gather() = %+v; want ok_total", families)
+	}
+}
+
+type FakeSummarizer struct {
+	summary *model.ExpirySummary
+}
+
+func (fs FakeSummarizer) SummarizeExpiry(ctx context.Context, windows []time.Duration) (*model.ExpirySummary, error) {
+	return fs.summary, nil
+}
+
+func TestInventoryCollector(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	collector := NewInventoryCollector(FakeSummarizer{summary: &model.ExpirySummary{
+		Expired:        1,
+		ExpiringWithin: map[time.Duration]int{7 * day: 2, 30 * day: 3, 90 * day: 5},
+		Issuers:        []model.IssuerSummary{{Issuer: "CN=Root", Count: 4}, {Issuer: "CN=Intermediate", Count: 4}},
+		SoonestExpiry:  now.Add(36 * time.Hour),
+	}})
+	collector.now = func() time.Time { return now }
+
+	want := `# HELP certwatch_certificates_by_expiry Certificates expired, and not expired but expiring within the bucket.
+# TYPE certwatch_certificates_by_expiry gauge
+certwatch_certificates_by_expiry{bucket="expired"} 1
+certwatch_certificates_by_expiry{bucket="7d"} 2
+certwatch_certificates_by_expiry{bucket="30d"} 3
+certwatch_certificates_by_expiry{bucket="90d"} 5
+# HELP certwatch_certificates_by_issuer Certificates by issuer distinguished name.
+# TYPE certwatch_certificates_by_issuer gauge
+certwatch_certificates_by_issuer{issuer="CN=Root"} 4
+certwatch_certificates_by_issuer{issuer="CN=Intermediate"} 4
+# HELP certwatch_certificate_soonest_expiry_seconds Seconds until the next certificate expires.
+# TYPE certwatch_certificate_soonest_expiry_seconds gauge
+certwatch_certificate_soonest_expiry_seconds 129600
+`
+	if got := gather(t, collector); got != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/hytonhan/certwatch/internal/audit"
+	"github.com/hytonhan/certwatch/internal/auth"
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+// Authenticator resolves a bearer token to the caller it belongs to.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*auth.Principal, error)
+}
+
+// CertificateAuthenticator resolves a verified TLS client certificate to the
+// caller it identifies.
+type CertificateAuthenticator interface {
+	AuthenticateCertificate(ctx context.Context, cert *x509.Certificate) (*auth.Principal, error)
+}
+
+// DenialRecorder records refused requests in the audit trail.
+type DenialRecorder interface {
+	RecordDenied(ctx context.Context, entityType string, entityID string, denial model.AccessDenial) error
+}
+
+type deniedScopeKey struct{}
+
+// setDeniedScope records the scope a caller was refused for AuditDenials.
+func setDeniedScope(ctx context.Context, scope auth.Scope) {
+	if denied, ok := ctx.Value(deniedScopeKey{}).(*auth.Scope); ok {
+		*denied = scope
+	}
+}
+
+// AuditDenials records the requests RequireScope, further down the chain,
+// refuses an authenticated caller as access.denied of their route.
+// Unauthenticated requests are not recorded, so that anyone cannot fill the
+// audit trail.
+func AuditDenials(logger *slog.Logger, recorder DenialRecorder) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var denied auth.Scope
+			ctx := context.WithValue(r.Context(), deniedScopeKey{}, &denied)
+			next.ServeHTTP(w, r.WithContext(ctx))
+
+			principal := auth.PrincipalFrom(ctx)
+			if denied == "" || principal == nil {
+				return
+			}
+			route := unmatchedRoute
+			if pattern, ok := ctx.Value(routeKey{}).(*string); ok {
+				route = *pattern
+			}
+			denial := model.AccessDenial{
+				Action: route,
+				Role:   string(principal.Role()),
+				Team:   principal.Team,
+				Reason: "missing scope " + string(denied),
+			}
+			if err := recorder.RecordDenied(ctx, model.AuditEntityRoute, route, denial); err != nil {
+				requestID, _ := ctx.Value("request_id").(string)
+				logger.WarnContext(ctx, "Recording access denial failed",
+					"route", route,
+					"request_id", requestID)
+			}
+		})
+	}
+}
+
+// ClientCertificate attaches the caller of a request made with a verified
+// TLS client certificate to its context. Authenticate runs after it, so a
+// bearer token sent as well takes precedence.
+func ClientCertificate(logger *slog.Logger, authenticator CertificateAuthenticator) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			requestID, _ := r.Context().Value("request_id").(string)
+			principal, err := authenticator.AuthenticateCertificate(r.Context(), r.TLS.VerifiedChains[0][0])
+			if err != nil {
+				logger.WarnContext(r.Context(), "Authentication failed: invalid client certificate",
+					"reason", err.Error(),
+					"request_id", requestID)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := auth.WithPrincipal(r.Context(), principal)
+			ctx = audit.WithActor(ctx, principal.Actor())
+			setCaller(ctx, principal.Actor())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Authenticate attaches the caller of a request with a bearer token to its
+// context. The authenticators are tried in order and the first that accepts
+// the token wins. Requests without credentials pass through unauthenticated
+// and are turned away by RequireScope; invalid credentials are rejected here.
+func Authenticate(logger *slog.Logger, authenticators ...Authenticator) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			requestID, _ := r.Context().Value("request_id").(string)
+			scheme, token, ok := strings.Cut(header, " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") {
+				logger.WarnContext(r.Context(), "Authentication failed: not a bearer token",
+					"request_id", requestID)
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_request"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			principal, err := authenticate(r.Context(), authenticators, strings.TrimSpace(token))
+			if err != nil {
+				if errors.Is(err, auth.ErrInvalidToken) {
+					logger.WarnContext(r.Context(), "Authentication failed: invalid token",
+						"reason", err.Error(),
+						"request_id", requestID)
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return
+				}
+				logger.WarnContext(r.Context(), "Authentication failed for unknown reason",
+					"request_id", requestID)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+
+			ctx := auth.WithPrincipal(r.Context(), principal)
+			ctx = audit.WithActor(ctx, principal.Actor())
+			setCaller(ctx, principal.Actor())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// authenticate returns the principal of the first authenticator accepting
+// the token. If none does, the error is ErrInvalidToken, with each
+// authenticator's reason, only if every authenticator rejected the token
+// rather than failed to check it.
+func authenticate(ctx context.Context, authenticators []Authenticator, token string) (*auth.Principal, error) {
+	reasons := []string{}
+	for _, authenticator := range authenticators {
+		principal, err := authenticator.Authenticate(ctx, token)
+		if err == nil {
+			return principal, nil
+		}
+		if !errors.Is(err, auth.ErrInvalidToken) {
+			return nil, err
+		}
+		reasons = append(reasons, err.Error())
+	}
+	return nil, fmt.Errorf("%w (%s)", auth.ErrInvalidToken, strings.Join(reasons, "; "))
+}
+
+// RequireScope lets only callers granted the scope through: 401 for
+// unauthenticated requests, 403 for callers without the scope.
+func RequireScope(scope auth.Scope) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.PrincipalFrom(r.Context())
+			if principal == nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if !principal.HasScope(scope) {
+				setDeniedScope(r.Context(), scope)
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+string(scope)+`"`)
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
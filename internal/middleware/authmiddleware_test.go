@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hytonhan/certwatch/internal/auth"
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+type fakeDenialRecorder struct {
+	entityIDs []string
+	denials   []model.AccessDenial
+}
+
+func (r *fakeDenialRecorder) RecordDenied(ctx context.Context, entityType string, entityID string, denial model.AccessDenial) error {
+	if entityType != model.AuditEntityRoute {
+		return nil
+	}
+	r.entityIDs = append(r.entityIDs, entityID)
+	r.denials = append(r.denials, denial)
+	return nil
+}
+
+func TestAuditDenials(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	recorder := &fakeDenialRecorder{}
+	mux := http.NewServeMux()
+	mux.Handle("DELETE /certificates/{id}", RequireScope(auth.ScopeCertsWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	handler := LoggingMiddlewarefunc(logger, nil)(Route(mux)(AuditDenials(logger, recorder)(mux)))
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		expected  int
+	}{
+		{"unauthenticated", nil, http.StatusUnauthorized},
+		{"viewer", &auth.Principal{Kind: auth.KindAPIKey, Name: "reader", Scopes: []auth.Scope{auth.ScopeCertsRead}}, http.StatusForbidden},
+		{"editor", &auth.Principal{Kind: auth.KindAPIKey, Name: "deployer", Team: "payments", Scopes: []auth.Scope{auth.ScopeCertsWrite}}, http.StatusNoContent},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/certificates/id1", nil)
+			if test.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), test.principal))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != test.expected {
+				t.Errorf("status = %d; want %d", rec.Code, test.expected)
+			}
+		})
+	}
+
+	if len(recorder.denials) != 1 {
+		t.Fatalf("recorded %d denials; want only the viewer's", len(recorder.denials))
+	}
+	denial := recorder.denials[0]
+	if recorder.entityIDs[0] != "DELETE /certificates/{id}" || denial.Role != string(auth.RoleViewer) || denial.Reason != "missing scope certs:write" {
+		t.Errorf("recorded %q %+v; want the viewer refused the route for certs:write", recorder.entityIDs[0], denial)
+	}
+}
@@ -4,33 +4,144 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hytonhan/certwatch/internal/audit"
+	"github.com/hytonhan/certwatch/internal/metrics"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func LoggingMiddlewarefunc(logger *slog.Logger) func(next http.Handler) http.Handler {
+type callerKey struct{}
+
+type routeKey struct{}
+
+// unmatchedRoute labels requests no route matches, so that arbitrary paths
+// do not each become a series.
+const unmatchedRoute = "unmatched"
+
+// setCaller records the authenticated caller for the access log.
+func setCaller(ctx context.Context, actor string) {
+	if caller, ok := ctx.Value(callerKey{}).(*string); ok {
+		*caller = actor
+	}
+}
+
+// HTTPMetrics count the requests LoggingMiddlewarefunc serves.
+type HTTPMetrics struct {
+	Requests *metrics.Counter
+	Duration *metrics.Histogram
+}
+
+func NewHTTPMetrics() *HTTPMetrics {
+	return &HTTPMetrics{
+		Requests: metrics.NewCounter("certwatch_http_requests_total",
+			"HTTP requests by route pattern, method and status code.",
+			"route", "method", "status"),
+		Duration: metrics.NewHistogram("certwatch_http_request_duration_seconds",
+			"HTTP request latency by route pattern.",
+			metrics.DurationBuckets, "route"),
+	}
+}
+
+// Route records the pattern of the mux route matching the request for the
+// access log, before anything down the chain can turn the request away.
+func Route(mux *http.ServeMux) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+				if _, pattern := mux.Handler(r); pattern != "" {
+					*route = pattern
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// methodLabel keeps clients from making a series of any method name.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "other"
+}
+
+// maxRequestIDLength matches what the audit trail stores.
+const maxRequestIDLength = 64
+
+// requestIDFrom returns the inbound X-Request-ID if it is well-formed, so
+// that ids propagate across services, and a new id otherwise. Only letters,
+// digits and "-_.:" are accepted, which covers UUIDs and trace ids and keeps
+// anything odd out of the logs.
+func requestIDFrom(r *http.Request) string {
+	id := r.Header.Get("X-Request-ID")
+	if id == "" || len(id) > maxRequestIDLength {
+		return uuid.NewString()
+	}
+	for _, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return uuid.NewString()
+		}
+	}
+	return id
+}
+
+// traceID returns the id of the request's trace, or "" if it has none.
+func traceID(ctx context.Context) string {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		return spanContext.TraceID().String()
+	}
+	return ""
+}
+
+// LoggingMiddlewarefunc writes the access log and, unless httpMetrics is nil,
+// counts the requests. The request id is sent back as X-Request-ID.
+func LoggingMiddlewarefunc(logger *slog.Logger, httpMetrics *HTTPMetrics) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
-			requestID := uuid.NewString()
+			requestID := requestIDFrom(r)
+			w.Header().Set("X-Request-ID", requestID)
 
 			ctx := context.WithValue(r.Context(), "request_id", requestID)
+			// Requests are anonymous until Authenticate, further down the chain,
+			// fills in the caller for the access log.
+			ctx = audit.WithActor(ctx, audit.AnonymousActor)
+			caller := audit.AnonymousActor
+			ctx = context.WithValue(ctx, callerKey{}, &caller)
+			route := unmatchedRoute
+			ctx = context.WithValue(ctx, routeKey{}, &route)
 			r = r.WithContext(ctx)
 
-			// TODO: statuscode needs a wrapper
-			// rw := NewResponseWriter(w)
+			rw, wrapped := wrapResponseWriter(w, start)
+			next.ServeHTTP(wrapped, r)
+			// A handler that writes nothing still sends 200.
+			rw.started()
 
-			next.ServeHTTP(w, r)
+			latency := time.Since(start)
+			annotateSpan(ctx, route, rw.status, caller)
+			if httpMetrics != nil {
+				httpMetrics.Requests.Inc(route, methodLabel(r.Method), strconv.Itoa(rw.status))
+				httpMetrics.Duration.Observe(latency.Seconds(), route)
+			}
 
 			logger.Info("http_request",
 				"event_type", "http_access",
 				"request_id", requestID,
+				"actor", caller,
 				"remote_ip", r.RemoteAddr,
+				"user_agent", r.UserAgent(),
 				"method", r.Method,
-				"path", r.URL.Path,
-				// "status_code", w.Header().s,
-				"latency_ms", time.Since(start).Milliseconds(),
+				"route", route,
+				"status_code", rw.status,
+				"response_bytes", rw.bytes,
+				"ttfb_ms", rw.firstByte.Milliseconds(),
+				"latency_ms", latency.Milliseconds(),
+				"trace_id", traceID(ctx),
 			)
 		})
 	}
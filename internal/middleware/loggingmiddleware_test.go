@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveLogged serves the request through the logging middleware and returns
+// the response and the access log entry.
+func serveLogged(t *testing.T, handler http.HandlerFunc, r *http.Request) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, nil))
+	mux := http.NewServeMux()
+	mux.Handle("GET /certificates/{id}", handler)
+	chain := LoggingMiddlewarefunc(logger, nil)(Route(mux)(mux))
+
+	w := httptest.NewRecorder()
+	chain.ServeHTTP(w, r)
+	entry := map[string]any{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("access log %q is not JSON: %v", out.String(), err)
+	}
+	return w, entry
+}
+
+func TestAccessLog(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/certificates/42", nil)
+	r.Header.Set("User-Agent", "deploy-bot/1.0")
+	_, entry := serveLogged(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}, r)
+
+	want := map[string]any{
+		"status_code":    float64(http.StatusNotFound),
+		"response_bytes": float64(len("not found\n")),
+		"route":          "GET /certificates/{id}",
+		"user_agent":     "deploy-bot/1.0",
+		"actor":          "anonymous",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v; want %v", key, entry[key], value)
+		}
+	}
+	if _, ok := entry["path"]; ok {
+		t.Errorf("access log has the raw path %v", entry["path"])
+	}
+	if _, ok := entry["ttfb_ms"]; !ok {
+		t.Error("access log has no ttfb_ms")
+	}
+}
+
+func TestAccessLogImplicitStatus(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/certificates/42", nil)
+	_, entry := serveLogged(t, func(w http.ResponseWriter, r *http.Request) {}, r)
+	if entry["status_code"] != float64(http.StatusOK) || entry["response_bytes"] != float64(0) {
+		t.Errorf("status_code, response_bytes = %v, %v; want 200, 0", entry["status_code"], entry["response_bytes"])
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/unknown", nil)
+	_, entry = serveLogged(t, func(w http.ResponseWriter, r *http.Request) {}, r)
+	if entry["route"] != unmatchedRoute {
+		t.Errorf("route = %v; want %s", entry["route"], unmatchedRoute)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name    string
+		inbound string
+		kept    bool
+	}{
+		{"uuid", "6f1c2b9e-8a4d-4f3a-9c1e-2d7b5a0e4c11", true},
+		{"trace id", "svc-a:00f067aa0ba902b7.1", true},
+		{"missing", "", false},
+		{"log injection", "abc\ninjected", false},
+		{"spaces", "abc def", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/certificates/42", nil)
+			r.Header.Set("X-Request-ID", tt.inbound)
+			var seen string
+			w, entry := serveLogged(t, func(w http.ResponseWriter, r *http.Request) {
+				seen = r.Context().Value("request_id").(string)
+			}, r)
+
+			if kept := seen == tt.inbound; kept != tt.kept {
+				t.Errorf("request id = %q for inbound %q; kept = %v, want %v", seen, tt.inbound, kept, tt.kept)
+			}
+			if seen == "" || entry["request_id"] != seen || w.Header().Get("X-Request-ID") != seen {
+				t.Errorf("request id %q, logged %v, sent back %q; want all equal", seen, entry["request_id"], w.Header().Get("X-Request-ID"))
+			}
+		})
+	}
+}
+
+// plainWriter is neither a Flusher nor a Hijacker.
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+func TestWrapResponseWriterInterfaces(t *testing.T) {
+	_, wrapped := wrapResponseWriter(httptest.NewRecorder(), time.Now())
+	if _, ok := wrapped.(http.Flusher); !ok {
+		t.Error("wrapped recorder is not a Flusher")
+	}
+	if _, ok := wrapped.(http.Hijacker); ok {
+		t.Error("wrapped recorder is a Hijacker; the recorder is not")
+	}
+
+	rw, wrapped := wrapResponseWriter(plainWriter{httptest.NewRecorder()}, time.Now())
+	if _, ok := wrapped.(http.Flusher); ok {
+		t.Error("wrapped plain writer is a Flusher")
+	}
+	if rw.Unwrap() == nil {
+		t.Error("Unwrap() = nil")
+	}
+
+	recorder := httptest.NewRecorder()
+	rw, wrapped = wrapResponseWriter(recorder, time.Now())
+	wrapped.(http.Flusher).Flush()
+	if !recorder.Flushed || rw.status != http.StatusOK {
+		t.Errorf("Flush() flushed = %v, status = %d; want true, 200", recorder.Flushed, rw.status)
+	}
+}
+
+func TestLoggingMiddlewareKeepsHijacker(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	var flusher, hijacker bool
+	server := httptest.NewServer(LoggingMiddlewarefunc(logger, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, flusher = w.(http.Flusher)
+		_, hijacker = w.(http.Hijacker)
+	})))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !flusher || !hijacker {
+		t.Errorf("Flusher, Hijacker = %v, %v; want both kept from the server's writer", flusher, hijacker)
+	}
+}
@@ -0,0 +1,280 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/auth"
+	"github.com/hytonhan/certwatch/internal/metrics"
+)
+
+// RateLimiter decides whether the client with the key may make another
+// request, and if not, how long it should wait. The limiter here keeps its
+// state in memory; one backed by a shared store would let several instances
+// enforce a common limit.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) (bool, time.Duration, error)
+}
+
+// TokenBucketLimiter gives every key a bucket of burst tokens, refilled at
+// rate tokens per second. Each request takes a token.
+type TokenBucketLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// sweepInterval is how often buckets that have refilled are dropped, so that
+// clients that went away take no memory.
+const sweepInterval = time.Minute
+
+func NewTokenBucketLimiter(rate float64, burst int) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}
+}
+
+func (tb *TokenBucketLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	now := tb.now()
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	if now.Sub(tb.lastSweep) >= sweepInterval {
+		tb.sweep(now)
+	}
+	b, ok := tb.buckets[key]
+	if !ok {
+		b = &bucket{tokens: tb.burst, updated: now}
+		tb.buckets[key] = b
+	}
+	b.tokens = tb.refill(b, now)
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	wait := time.Duration((1 - b.tokens) / tb.rate * float64(time.Second))
+	return false, wait, nil
+}
+
+func (tb *TokenBucketLimiter) refill(b *bucket, now time.Time) float64 {
+	return math.Min(tb.burst, b.tokens+now.Sub(b.updated).Seconds()*tb.rate)
+}
+
+// sweep drops the full buckets; a new bucket starts out full anyway.
+func (tb *TokenBucketLimiter) sweep(now time.Time) {
+	for key, b := range tb.buckets {
+		if tb.refill(b, now) >= tb.burst {
+			delete(tb.buckets, key)
+		}
+	}
+	tb.lastSweep = now
+}
+
+// RateLimits are the limits RateLimit enforces, one for reads and one for
+// writes, the limit LimitAuthFailures holds failed authentications to, and the
+// counts of their decisions. A nil limiter does not limit.
+type RateLimits struct {
+	Reads  RateLimiter
+	Writes RateLimiter
+	// AuthFailures is charged, by IP address, for every request answered
+	// with 401.
+	AuthFailures RateLimiter
+
+	readsAllowed        atomic.Int64
+	readsLimited        atomic.Int64
+	writesAllowed       atomic.Int64
+	writesLimited       atomic.Int64
+	authFailuresLimited atomic.Int64
+}
+
+// RateLimitCounts are the requests RateLimit let through and turned away
+// since the server started.
+type RateLimitCounts struct {
+	ReadsAllowed  int64
+	ReadsLimited  int64
+	WritesAllowed int64
+	WritesLimited int64
+	// AuthFailuresLimited are the requests turned away because their
+	// address failed to authenticate too often.
+	AuthFailuresLimited int64
+}
+
+func (rl *RateLimits) Counts() RateLimitCounts {
+	return RateLimitCounts{
+		ReadsAllowed:  rl.readsAllowed.Load(),
+		ReadsLimited:  rl.readsLimited.Load(),
+		WritesAllowed: rl.writesAllowed.Load(),
+		WritesLimited: rl.writesLimited.Load(),
+
+		AuthFailuresLimited: rl.authFailuresLimited.Load(),
+	}
+}
+
+// Collect reports the counts as certwatch_rate_limit_requests_total.
+func (rl *RateLimits) Collect(ctx context.Context) ([]metrics.Family, error) {
+	counts := rl.Counts()
+	sample := func(class string, decision string, value int64) metrics.Sample {
+		return metrics.Sample{
+			Labels: []metrics.Label{{Name: "class", Value: class}, {Name: "decision", Value: decision}},
+			Value:  float64(value),
+		}
+	}
+	return []metrics.Family{{
+		Name: "certwatch_rate_limit_requests_total",
+		Help: "Requests the rate limits let through and turned away, by read, write or auth_failure.",
+		Type: metrics.TypeCounter,
+		Samples: []metrics.Sample{
+			sample("read", "allowed", counts.ReadsAllowed),
+			sample("read", "limited", counts.ReadsLimited),
+			sample("write", "allowed", counts.WritesAllowed),
+			sample("write", "limited", counts.WritesLimited),
+			sample("auth_failure", "limited", counts.AuthFailuresLimited),
+		},
+	}}, nil
+}
+
+// RateLimit turns away clients that exceed their limit with 429. GET and
+// HEAD requests count as reads, all others as writes. Clients are told apart
+// by their principal, so it must run after authentication, and otherwise by
+// their IP address. /health is not limited.
+func RateLimit(logger *slog.Logger, limits *RateLimits) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/health" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limiter, allowed, limited := limits.Writes, &limits.writesAllowed, &limits.writesLimited
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				limiter, allowed, limited = limits.Reads, &limits.readsAllowed, &limits.readsLimited
+			}
+			if limiter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			requestID, _ := r.Context().Value("request_id").(string)
+			key := rateLimitKey(r)
+			ok, wait, err := limiter.Allow(r.Context(), key)
+			if err != nil {
+				// A limiter that cannot decide must not take the API down.
+				logger.WarnContext(r.Context(), "Rate limiting failed, allowing request",
+					"request_id", requestID)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !ok {
+				limited.Add(1)
+				logger.WarnContext(r.Context(), "Rate limit exceeded",
+					"client", key,
+					"request_id", requestID)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(max(wait.Seconds(), 1)))))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+			allowed.Add(1)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// LimitAuthFailures turns away, with 429, clients whose IP address has failed
+// to authenticate more often than limits.AuthFailures allows, until their
+// bucket has a token again. It must run before authentication, so that
+// guessing credentials is limited even though RateLimit, which runs after it,
+// never sees the requests that fail. Requests that authenticate cost nothing,
+// so callers sharing a proxy's address are only held back by failures.
+func LimitAuthFailures(logger *slog.Logger, limits *RateLimits) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limits.AuthFailures == nil {
+			return next
+		}
+		var mu sync.Mutex
+		var lastSweep time.Time
+		blocked := map[string]time.Time{}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID, _ := r.Context().Value("request_id").(string)
+			key := ipKey(r)
+			now := time.Now()
+			mu.Lock()
+			until, ok := blocked[key]
+			mu.Unlock()
+			if ok && now.Before(until) {
+				limits.authFailuresLimited.Add(1)
+				logger.WarnContext(r.Context(), "Authentication failure limit exceeded",
+					"client", key,
+					"request_id", requestID)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(max(until.Sub(now).Seconds(), 1)))))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			rw, wrapped := wrapResponseWriter(w, now)
+			next.ServeHTTP(wrapped, r)
+			if rw.status != http.StatusUnauthorized {
+				return
+			}
+			allowed, wait, err := limits.AuthFailures.Allow(r.Context(), key)
+			if err != nil {
+				logger.WarnContext(r.Context(), "Rate limiting failed, not counting authentication failure",
+					"request_id", requestID)
+				return
+			}
+			if allowed {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			// Addresses whose block has run out are dropped, so that
+			// clients that went away take no memory.
+			if now.Sub(lastSweep) >= sweepInterval {
+				for other, otherUntil := range blocked {
+					if !now.Before(otherUntil) {
+						delete(blocked, other)
+					}
+				}
+				lastSweep = now
+			}
+			blocked[key] = now.Add(wait)
+		})
+	}
+}
+
+// rateLimitKey identifies the client: its principal, or for unauthenticated
+// requests its IP address. Forwarding headers are not trusted, so behind a
+// proxy every unauthenticated client shares the proxy's limit.
+func rateLimitKey(r *http.Request) string {
+	if principal := auth.PrincipalFrom(r.Context()); principal != nil {
+		return principal.Actor()
+	}
+	return ipKey(r)
+}
+
+// ipKey identifies the client by the IP address the request came from.
+func ipKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/auth"
+)
+
+func TestTokenBucketLimiter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewTokenBucketLimiter(2, 3)
+	limiter.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := range 3 {
+		if ok, _, _ := limiter.Allow(ctx, "a"); !ok {
+			t.Fatalf("request %d within the burst was limited", i+1)
+		}
+	}
+	ok, wait, err := limiter.Allow(ctx, "a")
+	if ok || err != nil {
+		t.Fatalf("Allow() past the burst = %v, %v; want false, nil", ok, err)
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("wait = %v; want 500ms", wait)
+	}
+	if ok, _, _ := limiter.Allow(ctx, "b"); !ok {
+		t.Errorf("another key was limited")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _, _ := limiter.Allow(ctx, "a"); !ok {
+		t.Errorf("request after a refill was limited")
+	}
+	if ok, _, _ := limiter.Allow(ctx, "a"); ok {
+		t.Errorf("second request after a single refill was allowed")
+	}
+
+	// Idle buckets refill to the burst and are swept.
+	now = now.Add(sweepInterval)
+	limiter.Allow(ctx, "c")
+	if len(limiter.buckets) != 1 {
+		t.Errorf("%d buckets after the sweep; want 1", len(limiter.buckets))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	limits := &RateLimits{
+		Reads:  NewTokenBucketLimiter(1, 2),
+		Writes: NewTokenBucketLimiter(1, 1),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := RateLimit(logger, limits)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(method string, path string, principal *auth.Principal) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		ctx := context.WithValue(r.Context(), "request_id", "test")
+		if principal != nil {
+			ctx = auth.WithPrincipal(ctx, principal)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r.WithContext(ctx))
+		return w
+	}
+	alice := &auth.Principal{Id: "1", Name: "alice", Kind: auth.KindAPIKey}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		principal *auth.Principal
+		want      int
+	}{
+		{"first read", http.MethodGet, "/certificates", alice, http.StatusOK},
+		{"second read", http.MethodGet, "/certificates", alice, http.StatusOK},
+		{"read past the burst", http.MethodGet, "/certificates", alice, http.StatusTooManyRequests},
+		{"write has its own limit", http.MethodPost, "/certificates", alice, http.StatusOK},
+		{"write past the burst", http.MethodPost, "/certificates", alice, http.StatusTooManyRequests},
+		{"unauthenticated client by address", http.MethodGet, "/certificates", nil, http.StatusOK},
+		{"health is not limited", http.MethodGet, "/health", alice, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.method, tt.path, tt.principal)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d; want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "1" {
+				t.Errorf("Retry-After = %q; want 1", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+
+	want := RateLimitCounts{ReadsAllowed: 3, ReadsLimited: 1, WritesAllowed: 1, WritesLimited: 1}
+	if counts := limits.Counts(); counts != want {
+		t.Errorf("Counts() = %+v; want %+v", counts, want)
+	}
+}
+
+// tokenAuthenticator accepts the token "good" only.
+type tokenAuthenticator struct{}
+
+func (tokenAuthenticator) Authenticate(ctx context.Context, token string) (*auth.Principal, error) {
+	if token != "good" {
+		return nil, auth.ErrInvalidToken
+	}
+	return &auth.Principal{Id: "1", Name: "alice", Kind: auth.KindAPIKey}, nil
+}
+
+func TestLimitAuthFailures(t *testing.T) {
+	limits := &RateLimits{AuthFailures: NewTokenBucketLimiter(0.01, 2)}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := LimitAuthFailures(logger, limits)(Authenticate(logger, tokenAuthenticator{})(ok))
+	serve := func(token string, remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/certificates", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "request_id", "test")))
+		return w
+	}
+
+	for i := range 3 {
+		if w := serve("bad", "192.0.2.1:1234"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status = %d; want %d", i+1, w.Code, http.StatusUnauthorized)
+		}
+	}
+	w := serve("bad", "192.0.2.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status after repeated failures = %d; want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After is missing")
+	}
+	if w := serve("good", "192.0.2.1:1234"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("valid token from the blocked address: status = %d; want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w := serve("good", "192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("other address: status = %d; want %d", w.Code, http.StatusOK)
+	}
+	for i := range 5 {
+		if w := serve("good", "192.0.2.3:1234"); w.Code != http.StatusOK {
+			t.Fatalf("success %d: status = %d; want %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+	if counts := limits.Counts(); counts.AuthFailuresLimited != 2 {
+		t.Errorf("AuthFailuresLimited = %d; want 2", counts.AuthFailuresLimited)
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+)
+
+// responseWriter records what is written for the access log: the status
+// code, the size of the body and when the response started.
+type responseWriter struct {
+	http.ResponseWriter
+	start time.Time
+	// status is 0 until the header is written.
+	status    int
+	bytes     int64
+	firstByte time.Duration
+}
+
+// wrapResponseWriter returns the recording writer, and the writer to hand
+// down the chain, which is a Flusher or a Hijacker only if w is, so that
+// handlers checking for either are not misled.
+func wrapResponseWriter(w http.ResponseWriter, start time.Time) (*responseWriter, http.ResponseWriter) {
+	rw := &responseWriter{ResponseWriter: w, start: start}
+	_, flusher := w.(http.Flusher)
+	_, hijacker := w.(http.Hijacker)
+	switch {
+	case flusher && hijacker:
+		return rw, &flushHijackWriter{rw}
+	case flusher:
+		return rw, &flushWriter{rw}
+	case hijacker:
+		return rw, &hijackWriter{rw}
+	}
+	return rw, rw
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	// Informational responses precede the final one.
+	if rw.status == 0 && status >= http.StatusOK {
+		rw.status = status
+		rw.firstByte = time.Since(rw.start)
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.started()
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// started records an implicit 200, as net/http sends on the first write.
+func (rw *responseWriter) started() {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+		rw.firstByte = time.Since(rw.start)
+	}
+}
+
+func (rw *responseWriter) flush() {
+	rw.started()
+	rw.ResponseWriter.(http.Flusher).Flush()
+}
+
+// hijack hands the connection to the handler. What it writes there is not
+// counted; an upgrade is recorded as 101 Switching Protocols.
+func (rw *responseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := rw.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil && rw.status == 0 {
+		rw.status = http.StatusSwitchingProtocols
+		rw.firstByte = time.Since(rw.start)
+	}
+	return conn, buf, err
+}
+
+type flushWriter struct{ *responseWriter }
+
+func (w *flushWriter) Flush() { w.flush() }
+
+type hijackWriter struct{ *responseWriter }
+
+func (w *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+type flushHijackWriter struct{ *responseWriter }
+
+func (w *flushHijackWriter) Flush() { w.flush() }
+
+func (w *flushHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
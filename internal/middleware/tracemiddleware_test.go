@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hytonhan/certwatch/internal/tracing/tracingtest"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTrace(t *testing.T) {
+	spans := tracingtest.Record(t)
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /certificates/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "broken" {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+	})
+	chain := Trace()(LoggingMiddlewarefunc(logger, nil)(Route(mux)(mux)))
+
+	r := httptest.NewRequest(http.MethodGet, "/certificates/42", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	chain.ServeHTTP(httptest.NewRecorder(), r)
+	chain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/certificates/broken", nil))
+
+	got := tracingtest.Named(spans, "GET /certificates/{id}")
+	if len(got) != 2 {
+		t.Fatalf("recorded %d spans named after the route; want 2", len(got))
+	}
+	continued := got[0]
+	if continued.SpanKind != trace.SpanKindServer {
+		t.Errorf("span kind = %v; want server", continued.SpanKind)
+	}
+	if continued.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || continued.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("span is in trace %s under %s; want the inbound traceparent's", continued.SpanContext.TraceID(), continued.Parent.SpanID())
+	}
+	if continued.Status.Code != codes.Unset {
+		t.Errorf("status = %v; want unset", continued.Status.Code)
+	}
+
+	failed := got[1]
+	if failed.Parent.IsValid() {
+		t.Errorf("span without a traceparent has parent %s", failed.Parent.SpanID())
+	}
+	if failed.Status.Code != codes.Error {
+		t.Errorf("status of a 500 = %v; want error", failed.Status.Code)
+	}
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+type AlertStateId = string
+
+// AlertState records that a certificate has been alerted on for a threshold,
+// so that alerting survives restarts and is shared between replicas.
+type AlertState struct {
+	Id             AlertStateId
+	CertificateId  CertificateId
+	Threshold      string
+	FirstAlertedAt time.Time
+	LastAlertedAt  time.Time
+	Acknowledged   bool
+	AcknowledgedAt *time.Time
+	// Delivered is false while the alert is being sent, and after sending it
+	// failed until it is sent again.
+	Delivered bool
+	// OwnerTeam is the owner of the certificate, whose editors may
+	// acknowledge the alert.
+	OwnerTeam string
+}
+
+// ExpiredThreshold is the name of the stage a certificate enters once it has expired.
+const ExpiredThreshold = "expired"
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// ExpiryAlert is emitted when a certificate crosses into an expiry stage.
+type ExpiryAlert struct {
+	Certificate Certificate
+	Threshold   string
+	Severity    Severity
+	ExpiresIn   time.Duration
+}
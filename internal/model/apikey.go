@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+type APIKeyId = string
+
+// APIKey is a credential for the HTTP API. Only a hash of its secret is
+// stored; the token itself is shown once, when the key is created.
+type APIKey struct {
+	Id     APIKeyId
+	Name   string
+	Prefix string
+	// SecretHash is never serialized, not even into audit snapshots.
+	SecretHash string `json:"-"`
+	Scopes     []string
+	// Team restricts an editor key to the certificates the team owns.
+	Team       string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
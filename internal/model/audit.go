@@ -0,0 +1,74 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditEventId = int64
+
+// AuditEvent records one change to the inventory: who made it, as part of
+// which request, and the entity before and after. Before is null for
+// creations and After for deletions. Events form a hash chain: Hash covers
+// the event and PrevHash, the Hash of the event before it.
+type AuditEvent struct {
+	Id         AuditEventId
+	OccurredAt time.Time
+	Actor      string
+	RequestId  string
+	Action     string
+	EntityType string
+	EntityId   string
+	Before     json.RawMessage
+	After      json.RawMessage
+	PrevHash   string
+	Hash       string
+}
+
+// AuditVerification is the outcome of walking the audit hash chain. When the
+// chain is broken, BrokenAt is the first event that does not verify. LastId
+// and LastHash are the head of the verified chain; kept elsewhere, they also
+// reveal events removed from the end.
+type AuditVerification struct {
+	Checked  int
+	Valid    bool
+	BrokenAt AuditEventId
+	Reason   string
+	LastId   AuditEventId
+	LastHash string
+}
+
+const (
+	AuditCertificateCreate = "certificate.create"
+	AuditCertificateUpdate = "certificate.update"
+	AuditCertificateDelete = "certificate.delete"
+	AuditAlertAcknowledge  = "alert.acknowledge"
+	AuditEndpointCreate    = "endpoint.create"
+	AuditEndpointDelete    = "endpoint.delete"
+	AuditAPIKeyCreate      = "apikey.create"
+	AuditAPIKeyRevoke      = "apikey.revoke"
+	// AuditAccessDenied records a change the caller's role did not allow.
+	AuditAccessDenied = "access.denied"
+)
+
+// Entity types of audit events.
+const (
+	AuditEntityCertificate = "certificate"
+	AuditEntityAlert       = "alert"
+	AuditEntityEndpoint    = "endpoint"
+	AuditEntityAPIKey      = "apikey"
+	// AuditEntityRoute is an API route, e.g. "DELETE /certificates/{id}",
+	// refused for a missing scope.
+	AuditEntityRoute = "route"
+)
+
+// AccessDenial is the After snapshot of an AuditAccessDenied event: the
+// change that was attempted, by which role and team, on a certificate owned
+// by which team.
+type AccessDenial struct {
+	Action    string
+	Role      string
+	Team      string
+	OwnerTeam string
+	Reason    string
+}
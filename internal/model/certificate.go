@@ -5,12 +5,77 @@ import "time"
 type CertificateId = string
 
 type Certificate struct {
-	Id                CertificateId
-	CommonName        string
-	SerialNumber      string
-	Issuer            string
-	NotBefore         time.Time
-	NotAfter          time.Time
-	FingerprintSHA256 string
-	CreatedAt         time.Time
+	Id                 CertificateId
+	CommonName         string
+	SerialNumber       string
+	Issuer             string
+	NotBefore          time.Time
+	NotAfter           time.Time
+	FingerprintSHA256  string
+	CreatedAt          time.Time
+	SubjectKeyId       string
+	AuthorityKeyId     string
+	IssuerId           CertificateId
+	OwnerTeam          string
+	ContactEmail       string
+	Description        string
+	Labels             map[string]string
+	Notes              string
+	Version            int64
+	DNSNames           []string
+	IPAddresses        []string
+	URIs               []string
+	EmailAddresses     []string
+	KeyAlgorithm       string
+	KeySize            int
+	KeyCurve           string
+	SignatureAlgorithm string
+	KeyUsage           []string
+	ExtKeyUsage        []string
+	IsCA               bool
+	SelfSigned         bool
+	// PEM is the certificate itself, empty unless it was imported from it.
+	PEM string
+}
+
+type SearchResult struct {
+	Certificate Certificate
+	Rank        float64
+	Snippet     string
+}
+
+// KeySummary counts certificates sharing a public key algorithm and size.
+type KeySummary struct {
+	KeyAlgorithm string
+	KeySize      int
+	KeyCurve     string
+	Count        int
+}
+
+type SignatureSummary struct {
+	SignatureAlgorithm string
+	Count              int
+}
+
+type CryptoSummary struct {
+	Total               int
+	Keys                []KeySummary
+	SignatureAlgorithms []SignatureSummary
+}
+
+type IssuerSummary struct {
+	Issuer string
+	Count  int
+}
+
+// ExpirySummary counts the inventory by how soon it expires.
+type ExpirySummary struct {
+	Expired int
+	// ExpiringWithin counts the certificates not expired yet that expire
+	// within each window. A certificate counts in every window it is in.
+	ExpiringWithin map[time.Duration]int
+	Issuers        []IssuerSummary
+	// SoonestExpiry is the expiry of the next certificate to expire, zero if
+	// there is none.
+	SoonestExpiry time.Time
 }
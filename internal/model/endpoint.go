@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+type EndpointId = string
+
+// Endpoint is a TLS host:port target that the scanner connects to.
+type Endpoint struct {
+	Id         EndpointId
+	Host       string
+	Port       int
+	ServerName string
+	// OwnerTeam is the team whose editors may delete the endpoint.
+	OwnerTeam       string
+	CreatedAt       time.Time
+	LastScannedAt   *time.Time
+	HandshakeFailed bool
+	LastError       string
+	LastFingerprint string
+}
+
+// ScanResult is the outcome of a single scan of an endpoint.
+type ScanResult struct {
+	ScannedAt       time.Time
+	HandshakeFailed bool
+	Error           string
+	Fingerprint     string
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// Finding is a policy rule a certificate currently violates. FirstSeenAt is
+// kept while the finding persists across evaluations.
+type Finding struct {
+	CertificateId CertificateId
+	CommonName    string
+	RuleId        string
+	Severity      Severity
+	Message       string
+	FirstSeenAt   time.Time
+	LastSeenAt    time.Time
+}
+
+// FindingCount is the number of certificates violating a rule.
+type FindingCount struct {
+	RuleId   string
+	Severity Severity
+	Count    int
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+type NotificationDeliveryId = string
+
+// NotificationDelivery is one attempt to deliver an alert through a channel.
+type NotificationDelivery struct {
+	Id            NotificationDeliveryId
+	CertificateId CertificateId
+	Channel       string
+	Target        string
+	Threshold     string
+	Attempt       int
+	Success       bool
+	StatusCode    int
+	Error         string
+	CreatedAt     time.Time
+}
+
+type DigestItemId = string
+
+// DigestItem is an alert queued for a recipient's next email digest.
+type DigestItem struct {
+	Id        DigestItemId
+	Recipient string
+	Alert     ExpiryAlert
+	CreatedAt time.Time
+}
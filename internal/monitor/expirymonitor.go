@@ -6,55 +6,211 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/hytonhan/certwatch/internal/metrics"
 	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/notify"
 	"github.com/hytonhan/certwatch/internal/service"
+	"github.com/hytonhan/certwatch/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ExpiryMonitor struct {
-	service  service.CertificateService
-	interval time.Duration
-	window   time.Duration
-	logger   *slog.Logger
+	service   service.CertificateService
+	alerts    service.AlertService
+	interval  time.Duration
+	rules     []Rule
+	notifiers []notify.Notifier
+	logger    *slog.Logger
+	clock     service.Clock
+	metrics   *Metrics
 }
 
-func NewMonitor(service service.CertificateService, interval time.Duration, window time.Duration, logger *slog.Logger) *ExpiryMonitor {
-	return &ExpiryMonitor{service: service, interval: interval, window: window, logger: logger}
+// Metrics measure the monitor's runs and the notifications it sends.
+type Metrics struct {
+	RunDuration   *metrics.Histogram
+	RunFailures   *metrics.Counter
+	Notifications *metrics.Counter
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		RunDuration: metrics.NewHistogram("certwatch_monitor_run_duration_seconds",
+			"Duration of expiry monitor runs.",
+			[]float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60}),
+		RunFailures: metrics.NewCounter("certwatch_monitor_run_failures_total",
+			"Expiry monitor runs that could not list the expiring certificates."),
+		Notifications: metrics.NewCounter("certwatch_notifications_total",
+			"Expiry alert notifications by notifier and result, sent (or queued for a digest) or failed.",
+			"notifier", "result"),
+	}
+}
+
+// Option configures optional behaviour of the expiry monitor.
+type Option func(*ExpiryMonitor)
+
+// WithMetrics measures the monitor's runs and notifications.
+func WithMetrics(metrics *Metrics) Option {
+	return func(m *ExpiryMonitor) {
+		m.metrics = metrics
+	}
+}
+
+func NewMonitor(certs service.CertificateService, alerts service.AlertService, interval time.Duration, rules []Rule, notifiers []notify.Notifier, logger *slog.Logger, opts ...Option) *ExpiryMonitor {
+	m := &ExpiryMonitor{service: certs, alerts: alerts, interval: interval, rules: rules, notifiers: notifiers, logger: logger, clock: service.NewClock(), metrics: &Metrics{}}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
 func (m *ExpiryMonitor) Start(ctx context.Context) {
 
 	m.logger.InfoContext(ctx, "expiry monitor starter",
 		"interval", m.interval,
-		"window", m.window)
+		"rules", len(m.rules),
+		"notifiers", len(m.notifiers))
+	// Certificates are checked right away rather than one interval after
+	// startup; the stored alert state keeps a restart from alerting twice.
+	m.Check(ctx)
 	ticker := time.NewTicker(m.interval)
 	defer ticker.Stop()
 
-	reported := map[string]model.Certificate{}
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			certs, err := m.service.ListExpiring(ctx, m.window, service.ExcludeExpired)
-			if err != nil {
-				m.logger.WarnContext(ctx, "unknown error occured")
-				continue
-			}
-			if len(certs) > 0 {
-				m.logger.InfoContext(ctx, "Found "+strconv.Itoa(len(certs))+" expiring certs!")
-				for _, cert := range certs {
-					_, alreadyReported := reported[cert.Id]
-					if alreadyReported {
-						continue
-					}
-					m.logger.WarnContext(ctx,
-						"Expiring.",
-						"id", cert.Id,
-						"common_name", cert.CommonName,
-						"expires_at", cert.NotAfter)
-					reported[cert.Id] = cert
-				}
-			}
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check runs a single monitoring pass. Every certificate is placed in the
+// tightest stage of its rule it has crossed, and alerted once per stage.
+// Alert state lives in the database, so this holds across restarts and
+// replicas.
+func (m *ExpiryMonitor) Check(ctx context.Context) {
+	window, option, ok := m.window()
+	if !ok {
+		return
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "ExpiryMonitor.Check")
+	defer span.End()
+	start := time.Now()
+	defer func() {
+		m.metrics.RunDuration.Observe(time.Since(start).Seconds())
+	}()
+	certs, err := m.service.ListExpiring(ctx, window, option)
+	if err != nil {
+		m.logger.WarnContext(ctx, "unknown error occured")
+		m.metrics.RunFailures.Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	span.SetAttributes(attribute.Int("certwatch.certificates", len(certs)))
+	if len(certs) == 0 {
+		return
+	}
+	m.logger.InfoContext(ctx, "Found "+strconv.Itoa(len(certs))+" expiring certs!")
+
+	now := m.clock.Now().UTC()
+	for _, cert := range certs {
+		rule, ok := ruleFor(m.rules, cert.Labels)
+		if !ok {
+			continue
+		}
+		expiresIn := cert.NotAfter.Sub(now)
+		stage, ok := stageFor(rule.Thresholds, expiresIn)
+		if !ok {
+			continue
+		}
+		send, err := m.alerts.Record(ctx, cert.Id, stage.Name)
+		if err != nil {
+			m.logger.WarnContext(ctx, "recording alert failed",
+				"id", cert.Id)
+			continue
+		}
+		if !send {
+			continue
+		}
+		delivered := m.emit(ctx, model.ExpiryAlert{
+			Certificate: cert,
+			Threshold:   stage.Name,
+			Severity:    stage.Severity,
+			ExpiresIn:   expiresIn,
+		})
+		// An alert left undelivered is claimed and sent again by a later run.
+		if !delivered {
+			continue
+		}
+		if err := m.alerts.Delivered(ctx, cert.Id, stage.Name); err != nil {
+			m.logger.WarnContext(ctx, "recording alert delivery failed",
+				"id", cert.Id)
+		}
+	}
+}
+
+// window returns how far ahead to list certificates so that every rule's
+// widest stage is covered, and whether expired certificates are needed.
+func (m *ExpiryMonitor) window() (time.Duration, service.ExpiryOption, bool) {
+	var window time.Duration
+	option := service.ExcludeExpired
+	found := false
+	for _, rule := range m.rules {
+		if len(rule.Thresholds) == 0 {
+			continue
+		}
+		found = true
+		window = max(window, rule.Thresholds[0].Before)
+		if rule.Thresholds[len(rule.Thresholds)-1].Before <= 0 {
+			option = service.IncludeExpired
 		}
 	}
+	// The expired stage alone still needs a window.
+	return max(window, time.Second), option, found
+}
+
+// emit sends the alert through every notifier and reports whether all of
+// them delivered it. When one fails, the alert is sent again through all of
+// them, so a notifier that succeeded may send it twice.
+func (m *ExpiryMonitor) emit(ctx context.Context, alert model.ExpiryAlert) bool {
+	m.logger.WarnContext(ctx,
+		"Expiring.",
+		"id", alert.Certificate.Id,
+		"common_name", alert.Certificate.CommonName,
+		"owner_team", alert.Certificate.OwnerTeam,
+		"expires_at", alert.Certificate.NotAfter,
+		"threshold", alert.Threshold,
+		"severity", alert.Severity)
+
+	delivered := true
+	for _, notifier := range m.notifiers {
+		if err := m.notify(ctx, notifier, alert); err != nil {
+			m.logger.WarnContext(ctx, "notification failed",
+				"id", alert.Certificate.Id,
+				"notifier", notifier.Name())
+			m.metrics.Notifications.Inc(notifier.Name(), "failed")
+			delivered = false
+			continue
+		}
+		m.metrics.Notifications.Inc(notifier.Name(), "sent")
+	}
+	return delivered
+}
+
+// notify delivers the alert through the notifier in a span of its own.
+func (m *ExpiryMonitor) notify(ctx context.Context, notifier notify.Notifier, alert model.ExpiryAlert) error {
+	ctx, span := tracing.Tracer().Start(ctx, "notify "+notifier.Name(),
+		trace.WithAttributes(
+			attribute.String("certwatch.notifier", notifier.Name()),
+			attribute.String("certwatch.certificate.id", alert.Certificate.Id),
+			attribute.String("certwatch.threshold", alert.Threshold),
+		))
+	err := notifier.Notify(ctx, alert)
+	tracing.End(span, err)
+	return err
 }
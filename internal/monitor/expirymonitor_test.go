@@ -0,0 +1,299 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/metrics"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/notify"
+	"github.com/hytonhan/certwatch/internal/service"
+	"github.com/hytonhan/certwatch/internal/tracing/tracingtest"
+)
+
+const day = 24 * time.Hour
+
+type FakeNotifier struct {
+	alerts []model.ExpiryAlert
+	err    error
+}
+
+func (fn *FakeNotifier) Name() string {
+	return "fake"
+}
+
+func (fn *FakeNotifier) Notify(ctx context.Context, alert model.ExpiryAlert) error {
+	fn.alerts = append(fn.alerts, alert)
+	return fn.err
+}
+
+type FakeCertService struct {
+	service.CertificateService
+	certs []model.Certificate
+	err   error
+}
+
+func (fcs *FakeCertService) ListExpiring(ctx context.Context, window time.Duration, expiryOption service.ExpiryOption) ([]model.Certificate, error) {
+	return fcs.certs, fcs.err
+}
+
+type FakeAlertService struct {
+	service.AlertService
+	recorded  map[string]string
+	delivered []string
+}
+
+func (fas *FakeAlertService) Delivered(ctx context.Context, certificateID string, threshold string) error {
+	fas.delivered = append(fas.delivered, certificateID+"/"+threshold)
+	return nil
+}
+
+func (fas *FakeAlertService) Record(ctx context.Context, certificateID string, threshold string) (bool, error) {
+	if fas.recorded[certificateID] == threshold {
+		return false, nil
+	}
+	fas.recorded[certificateID] = threshold
+	return true, nil
+}
+
+func TestCheckStages(t *testing.T) {
+	now := time.Now()
+	certs := &FakeCertService{certs: []model.Certificate{
+		{Id: "in30", NotAfter: now.Add(20 * day)},
+		{Id: "in14", NotAfter: now.Add(10 * day)},
+		{Id: "in7", NotAfter: now.Add(5 * day)},
+		{Id: "in1", NotAfter: now.Add(10 * time.Hour)},
+		{Id: "expired", NotAfter: now.Add(-time.Hour)},
+	}}
+	alerts := &FakeAlertService{recorded: map[string]string{}}
+	thresholds := NewThresholds([]time.Duration{day, 0, 30 * day, 7 * day, 14 * day})
+	notifier := &FakeNotifier{}
+	m := NewMonitor(certs, alerts, time.Minute, []Rule{{Thresholds: thresholds}}, []notify.Notifier{notifier}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	m.Check(context.Background())
+	if len(notifier.alerts) != len(certs.certs) {
+		t.Errorf("Check() notified %d alerts; want %d", len(notifier.alerts), len(certs.certs))
+	}
+
+	want := map[string]string{
+		"in30":    "30d",
+		"in14":    "14d",
+		"in7":     "7d",
+		"in1":     "1d",
+		"expired": "expired",
+	}
+	for id, threshold := range want {
+		if alerts.recorded[id] != threshold {
+			t.Errorf("Check() recorded %q for %s; want %q", alerts.recorded[id], id, threshold)
+		}
+	}
+
+	// Crossing into the next stage produces a new alert.
+	certs.certs[0].NotAfter = now.Add(12 * day)
+	m.Check(context.Background())
+	if alerts.recorded["in30"] != "14d" {
+		t.Errorf("Check() recorded %q for in30 after moving closer; want %q", alerts.recorded["in30"], "14d")
+	}
+	if len(notifier.alerts) != len(certs.certs)+1 {
+		t.Errorf("Check() notified %d alerts; want %d", len(notifier.alerts), len(certs.certs)+1)
+	}
+	last := notifier.alerts[len(notifier.alerts)-1]
+	if last.Certificate.Id != "in30" || last.Severity != model.SeverityWarning {
+		t.Errorf("Check() notified %s with %s; want in30 with %s", last.Certificate.Id, last.Severity, model.SeverityWarning)
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	now := time.Now()
+	certs := &FakeCertService{certs: []model.Certificate{
+		{Id: "prod", NotAfter: now.Add(20 * day), Labels: map[string]string{"env": "prod"}},
+		{Id: "dev", NotAfter: now.Add(20 * day), Labels: map[string]string{"env": "dev"}},
+		{Id: "unlabeled", NotAfter: now.Add(5 * day), Labels: map[string]string{}},
+	}}
+	alerts := &FakeAlertService{recorded: map[string]string{}}
+	rules, err := ParseRules("env=prod:60d,30d,0;env=dev:", NewThresholds([]time.Duration{7 * day, 0}))
+	if err != nil {
+		t.Fatalf("ParseRules() error = %v", err)
+	}
+	notifier := &FakeNotifier{}
+	m := NewMonitor(certs, alerts, time.Minute, rules, []notify.Notifier{notifier}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	m.Check(context.Background())
+	want := map[string]string{
+		"prod":      "30d",
+		"dev":       "",
+		"unlabeled": "7d",
+	}
+	for id, threshold := range want {
+		if alerts.recorded[id] != threshold {
+			t.Errorf("Check() recorded %q for %s; want %q", alerts.recorded[id], id, threshold)
+		}
+	}
+	if len(notifier.alerts) != 2 {
+		t.Errorf("Check() notified %d alerts; want 2", len(notifier.alerts))
+	}
+}
+
+// sampleValues maps the label values of the collector's samples to their
+// values.
+func sampleValues(t *testing.T, collector metrics.Collector) map[string]float64 {
+	t.Helper()
+	families, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]float64{}
+	for _, sample := range families[0].Samples {
+		key := sample.Suffix
+		for _, label := range sample.Labels {
+			key += "," + label.Value
+		}
+		values[key] = sample.Value
+	}
+	return values
+}
+
+func TestCheckMetrics(t *testing.T) {
+	now := time.Now()
+	certs := &FakeCertService{certs: []model.Certificate{
+		{Id: "a", NotAfter: now.Add(5 * day)},
+		{Id: "b", NotAfter: now.Add(6 * day)},
+	}}
+	alerts := &FakeAlertService{recorded: map[string]string{}}
+	working := &FakeNotifier{}
+	failing := &broken{FakeNotifier{err: errors.New("connection refused")}}
+	stats := NewMetrics()
+	m := NewMonitor(certs, alerts, time.Minute, []Rule{{Thresholds: NewThresholds([]time.Duration{7 * day})}},
+		[]notify.Notifier{working, failing}, slog.New(slog.NewTextHandler(io.Discard, nil)), WithMetrics(stats))
+
+	m.Check(context.Background())
+	certs.err = errors.New("database is locked")
+	m.Check(context.Background())
+
+	notifications := sampleValues(t, stats.Notifications)
+	if notifications[",fake,sent"] != 2 || notifications[",broken,failed"] != 2 {
+		t.Errorf("notifications = %v; want 2 sent by fake and 2 failed by broken", notifications)
+	}
+	if failures := sampleValues(t, stats.RunFailures); failures[""] != 1 {
+		t.Errorf("run failures = %v; want 1", failures)
+	}
+	if runs := sampleValues(t, stats.RunDuration); runs["_count"] != 2 {
+		t.Errorf("runs = %v; want 2", runs["_count"])
+	}
+}
+
+func TestStartChecksRightAway(t *testing.T) {
+	certs := &FakeCertService{certs: []model.Certificate{{Id: "a", NotAfter: time.Now().Add(5 * day)}}}
+	alerts := &FakeAlertService{recorded: map[string]string{}}
+	notifier := &FakeNotifier{}
+	m := NewMonitor(certs, alerts, time.Hour, []Rule{{Thresholds: NewThresholds([]time.Duration{7 * day})}},
+		[]notify.Notifier{notifier}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// The context is cancelled, so Start returns after its first check.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.Start(ctx)
+	if len(notifier.alerts) != 1 {
+		t.Errorf("Start() sent %d alerts before the first tick; want 1", len(notifier.alerts))
+	}
+}
+
+func TestCheckFailedDelivery(t *testing.T) {
+	certs := &FakeCertService{certs: []model.Certificate{{Id: "a", NotAfter: time.Now().Add(5 * day)}}}
+	alerts := &FakeAlertService{recorded: map[string]string{}}
+	notifier := &FakeNotifier{err: errors.New("connection refused")}
+	m := NewMonitor(certs, alerts, time.Minute, []Rule{{Thresholds: NewThresholds([]time.Duration{7 * day})}},
+		[]notify.Notifier{notifier}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	m.Check(context.Background())
+	if len(notifier.alerts) != 1 || len(alerts.delivered) != 0 {
+		t.Fatalf("sent %d, delivered %v; want one attempt left undelivered", len(notifier.alerts), alerts.delivered)
+	}
+
+	// The retry is claimed again; this time the notifier works.
+	delete(alerts.recorded, "a")
+	notifier.err = nil
+	m.Check(context.Background())
+	if len(notifier.alerts) != 2 || len(alerts.delivered) != 1 || alerts.delivered[0] != "a/7d" {
+		t.Errorf("sent %d, delivered %v; want the retry delivered as a/7d", len(notifier.alerts), alerts.delivered)
+	}
+}
+
+func TestCheckSpans(t *testing.T) {
+	spans := tracingtest.Record(t)
+	certs := &FakeCertService{certs: []model.Certificate{{Id: "a", NotAfter: time.Now().Add(5 * day)}}}
+	alerts := &FakeAlertService{recorded: map[string]string{}}
+	m := NewMonitor(certs, alerts, time.Minute, []Rule{{Thresholds: NewThresholds([]time.Duration{7 * day})}},
+		[]notify.Notifier{&FakeNotifier{}}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	m.Check(context.Background())
+
+	checks := tracingtest.Named(spans, "ExpiryMonitor.Check")
+	deliveries := tracingtest.Named(spans, "notify fake")
+	if len(checks) != 1 || len(deliveries) != 1 {
+		t.Fatalf("recorded %d checks and %d deliveries; want 1 each", len(checks), len(deliveries))
+	}
+	if deliveries[0].Parent.SpanID() != checks[0].SpanContext.SpanID() {
+		t.Errorf("delivery is not a child of the check")
+	}
+}
+
+type broken struct {
+	FakeNotifier
+}
+
+func (b *broken) Name() string {
+	return "broken"
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  string
+		rules int
+		err   error
+	}{
+		{"empty", "", 1, nil},
+		{"single", "env=prod:30d,7d,0", 2, nil},
+		{"several", "env=prod:30d,0; app in (legacy,old):", 3, nil},
+		{"missing thresholds", "env=prod", 0, ErrInvalidRule},
+		{"missing selector", ":30d", 0, ErrInvalidRule},
+		{"invalid selector", "env=pr od:30d", 0, ErrInvalidRule},
+		{"invalid threshold", "env=prod:soon", 0, ErrInvalidRule},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := ParseRules(test.spec, nil)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("ParseRules(%q) error = %v; want %v", test.spec, err, test.err)
+			}
+			if len(rules) != test.rules {
+				t.Errorf("ParseRules(%q) = %d rules; want %d", test.spec, len(rules), test.rules)
+			}
+		})
+	}
+}
+
+func TestNewThresholds(t *testing.T) {
+	thresholds := NewThresholds([]time.Duration{day, 0, 30 * day, 7 * day, 14 * day, day})
+	want := []Threshold{
+		{"30d", 30 * day, model.SeverityInfo},
+		{"14d", 14 * day, model.SeverityWarning},
+		{"7d", 7 * day, model.SeverityHigh},
+		{"1d", day, model.SeverityCritical},
+		{"expired", 0, model.SeverityCritical},
+	}
+	if len(thresholds) != len(want) {
+		t.Fatalf("NewThresholds() = %v; want %v", thresholds, want)
+	}
+	for i := range want {
+		if thresholds[i] != want[i] {
+			t.Errorf("NewThresholds()[%d] = %v; want %v", i, thresholds[i], want[i])
+		}
+	}
+}
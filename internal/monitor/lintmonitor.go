@@ -0,0 +1,57 @@
+package monitor
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/service"
+	"github.com/hytonhan/certwatch/internal/tracing"
+)
+
+// LintMonitor re-evaluates the whole inventory against the policy rules, so
+// that findings follow rule changes and certificates imported or edited
+// elsewhere.
+type LintMonitor struct {
+	findings service.FindingService
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+func NewLintMonitor(findings service.FindingService, interval time.Duration, logger *slog.Logger) *LintMonitor {
+	return &LintMonitor{findings: findings, interval: interval, logger: logger}
+}
+
+func (m *LintMonitor) Start(ctx context.Context) {
+
+	m.logger.InfoContext(ctx, "lint monitor started",
+		"interval", m.interval)
+	// The inventory is linted right away rather than one interval after
+	// startup.
+	m.Check(ctx)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check lints every certificate once.
+func (m *LintMonitor) Check(ctx context.Context) {
+	ctx, span := tracing.Tracer().Start(ctx, "LintMonitor.Check")
+	count, err := m.findings.LintAll(ctx)
+	tracing.End(span, err)
+	if err != nil {
+		m.logger.WarnContext(ctx, "linting certificates failed",
+			"linted", count)
+		return
+	}
+	m.logger.InfoContext(ctx, "linted certificates",
+		"count", count)
+}
@@ -0,0 +1,40 @@
+package monitor
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/service"
+)
+
+type FakeFindingService struct {
+	service.FindingService
+	linted chan struct{}
+}
+
+func (ffs *FakeFindingService) LintAll(ctx context.Context) (int, error) {
+	ffs.linted <- struct{}{}
+	return 0, nil
+}
+
+func TestLintMonitorStartsRightAway(t *testing.T) {
+	findings := &FakeFindingService{linted: make(chan struct{}, 1)}
+	m := NewLintMonitor(findings, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Start(ctx)
+		close(done)
+	}()
+	select {
+	case <-findings.linted:
+	case <-time.After(5 * time.Second):
+		t.Error("Start() did not lint before the first tick")
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,82 @@
+package monitor
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/labels"
+)
+
+var ErrInvalidRule = errors.New("invalid expiry rule")
+
+// Rule applies its thresholds to the certificates matching the selector.
+// A rule without thresholds silences alerts for those certificates.
+type Rule struct {
+	Selector   labels.Selector
+	Thresholds []Threshold
+}
+
+// ParseRules reads rules written as "env=prod:30d,14d,7d,1d,0;env=dev:0",
+// in order of precedence. A catch-all rule with the default thresholds is
+// appended, so certificates matching no rule keep the default stages.
+func ParseRules(spec string, defaults []Threshold) ([]Rule, error) {
+	rules := []Rule{}
+	for _, part := range strings.Split(spec, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		selectorSpec, thresholdSpec, found := strings.Cut(part, ":")
+		if !found || strings.TrimSpace(selectorSpec) == "" {
+			return nil, ErrInvalidRule
+		}
+		selector, err := labels.ParseSelector(selectorSpec)
+		if err != nil {
+			return nil, ErrInvalidRule
+		}
+		durations := []time.Duration{}
+		for _, value := range strings.Split(thresholdSpec, ",") {
+			if strings.TrimSpace(value) == "" {
+				continue
+			}
+			d, err := ParseThreshold(strings.TrimSpace(value))
+			if err != nil {
+				return nil, ErrInvalidRule
+			}
+			durations = append(durations, d)
+		}
+		rules = append(rules, Rule{Selector: selector, Thresholds: NewThresholds(durations)})
+	}
+	return append(rules, Rule{Selector: labels.Selector{}, Thresholds: defaults}), nil
+}
+
+// ParseThreshold is the inverse of FormatThreshold: it accepts whole days,
+// e.g. "30d", "expired", and anything time.ParseDuration does.
+func ParseThreshold(value string) (time.Duration, error) {
+	if value == "expired" || value == "0" {
+		return 0, nil
+	}
+	if days, found := strings.CutSuffix(value, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, ErrInvalidRule
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, ErrInvalidRule
+	}
+	return d, nil
+}
+
+// ruleFor returns the first rule whose selector matches the labels.
+func ruleFor(rules []Rule, certLabels map[string]string) (Rule, bool) {
+	for _, rule := range rules {
+		if rule.Selector.Matches(certLabels) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
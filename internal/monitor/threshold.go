@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+// Threshold is one expiry stage. A certificate is in the stage when it
+// expires within Before; a zero Before means it has already expired.
+type Threshold struct {
+	Name     string
+	Before   time.Duration
+	Severity model.Severity
+}
+
+// NewThresholds builds the ordered stages, widest first, from the configured
+// durations. Severity follows the stage: the closer to expiry, the higher.
+func NewThresholds(durations []time.Duration) []Threshold {
+	thresholds := []Threshold{}
+	seen := map[time.Duration]bool{}
+	for _, d := range durations {
+		if d < 0 || seen[d] {
+			continue
+		}
+		seen[d] = true
+		thresholds = append(thresholds, Threshold{Name: FormatThreshold(d), Before: d, Severity: severityFor(d)})
+	}
+	sort.Slice(thresholds, func(i, j int) bool {
+		return thresholds[i].Before > thresholds[j].Before
+	})
+	return thresholds
+}
+
+// stageFor returns the tightest stage the certificate has crossed into.
+func stageFor(thresholds []Threshold, expiresIn time.Duration) (Threshold, bool) {
+	for i := len(thresholds) - 1; i >= 0; i-- {
+		if expiresIn <= thresholds[i].Before {
+			return thresholds[i], true
+		}
+	}
+	return Threshold{}, false
+}
+
+func severityFor(d time.Duration) model.Severity {
+	day := 24 * time.Hour
+	switch {
+	case d <= day:
+		return model.SeverityCritical
+	case d <= 7*day:
+		return model.SeverityHigh
+	case d <= 14*day:
+		return model.SeverityWarning
+	default:
+		return model.SeverityInfo
+	}
+}
+
+// FormatThreshold names a threshold in whole days when possible, e.g. "3d".
+func FormatThreshold(d time.Duration) string {
+	day := 24 * time.Hour
+	if d <= 0 {
+		return model.ExpiredThreshold
+	}
+	if d%day == 0 {
+		return strconv.FormatInt(int64(d/day), 10) + "d"
+	}
+	return d.String()
+}
@@ -0,0 +1,385 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+type EmailMode string
+
+const (
+	EmailImmediate EmailMode = "immediate"
+	EmailDigest    EmailMode = "digest"
+)
+
+var ErrStartTLSRequired = errors.New("smtp server does not offer STARTTLS")
+
+type EmailConfig struct {
+	Host           string
+	Port           int
+	Username       string
+	Password       string
+	From           string
+	To             []string
+	NotifyOwners   bool
+	Mode           EmailMode
+	RequireTLS     bool
+	DigestInterval time.Duration
+	Timeout        time.Duration
+	// TLSConfig overrides the STARTTLS client configuration, mainly for tests.
+	TLSConfig *tls.Config
+}
+
+// DigestQueue keeps the alerts waiting for an email digest in the database,
+// so that a restart does not lose them. Claimed alerts are removed once sent
+// and released when sending fails.
+type DigestQueue interface {
+	EnqueueDigest(ctx context.Context, alert model.ExpiryAlert, recipients []string) error
+	ClaimDigest(ctx context.Context) ([]model.DigestItem, error)
+	RemoveDigest(ctx context.Context, ids []string) error
+	ReleaseDigest(ctx context.Context, ids []string) error
+}
+
+// EmailNotifier sends expiry alerts over SMTP, either one email per alert or
+// as a periodic digest grouped by urgency.
+type EmailNotifier struct {
+	config   EmailConfig
+	recorder DeliveryRecorder
+	queue    DigestQueue
+	logger   *slog.Logger
+}
+
+// NewEmailNotifier creates the email notifier. The queue is only used, and
+// required, in digest mode.
+func NewEmailNotifier(config EmailConfig, recorder DeliveryRecorder, queue DigestQueue, logger *slog.Logger) *EmailNotifier {
+	if config.Mode == "" {
+		config.Mode = EmailImmediate
+	}
+	return &EmailNotifier{config: config, recorder: recorder, queue: queue, logger: logger}
+}
+
+func (en *EmailNotifier) Name() string {
+	return "email"
+}
+
+// Notify sends the alert right away in immediate mode, and queues it for the
+// next digest otherwise. Queueing fails when the queue is full.
+func (en *EmailNotifier) Notify(ctx context.Context, alert model.ExpiryAlert) error {
+	if en.config.Mode == EmailDigest {
+		return en.queue.EnqueueDigest(ctx, alert, en.recipientsFor(alert))
+	}
+	return en.deliver(ctx, alert)
+}
+
+// Run flushes the digest every DigestInterval until ctx is done, then flushes
+// whatever is still queued. It returns at once in immediate mode.
+func (en *EmailNotifier) Run(ctx context.Context) {
+	if en.config.Mode != EmailDigest {
+		return
+	}
+	en.logger.InfoContext(ctx, "email digest started",
+		"interval", en.config.DigestInterval)
+	ticker := time.NewTicker(en.config.DigestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), en.config.Timeout)
+			en.Flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			en.Flush(ctx)
+		}
+	}
+}
+
+// Flush sends the queued alerts as one digest per recipient. A recipient's
+// alerts are removed from the queue once sent, and stay queued for the next
+// flush if sending to that recipient fails.
+func (en *EmailNotifier) Flush(ctx context.Context) error {
+	items, err := en.queue.ClaimDigest(ctx)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	byRecipient := map[string][]model.DigestItem{}
+	for _, item := range items {
+		item.Alert.ExpiresIn = item.Alert.Certificate.NotAfter.Sub(now)
+		byRecipient[item.Recipient] = append(byRecipient[item.Recipient], item)
+	}
+
+	var failed error
+	for rcpt, rcptItems := range byRecipient {
+		alerts := make([]model.ExpiryAlert, len(rcptItems))
+		ids := make([]string, len(rcptItems))
+		for i, item := range rcptItems {
+			alerts[i] = item.Alert
+			ids[i] = item.Id
+		}
+		if err := en.deliverTo(ctx, rcpt, alerts); err != nil {
+			failed = err
+			if err := en.queue.ReleaseDigest(ctx, ids); err != nil {
+				en.logger.WarnContext(ctx, "releasing email digest failed",
+					"alerts", len(ids))
+			}
+			continue
+		}
+		if err := en.queue.RemoveDigest(ctx, ids); err != nil {
+			en.logger.WarnContext(ctx, "removing sent email digest failed",
+				"alerts", len(ids))
+		}
+	}
+	return failed
+}
+
+// deliver sends the alert to each of its recipients.
+func (en *EmailNotifier) deliver(ctx context.Context, alert model.ExpiryAlert) error {
+	var failed error
+	for _, rcpt := range en.recipientsFor(alert) {
+		if err := en.deliverTo(ctx, rcpt, []model.ExpiryAlert{alert}); err != nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+// deliverTo sends one email containing the alerts to the recipient.
+func (en *EmailNotifier) deliverTo(ctx context.Context, rcpt string, alerts []model.ExpiryAlert) error {
+	msg, err := en.buildMessage(rcpt, alerts)
+	if err == nil {
+		err = en.send(ctx, rcpt, msg)
+	}
+	for _, alert := range alerts {
+		en.record(ctx, alert, err)
+	}
+	if err != nil {
+		en.logger.WarnContext(ctx, "email delivery failed",
+			"alerts", len(alerts))
+		return ErrDeliveryFailed
+	}
+	return nil
+}
+
+// recipientsFor returns the global recipients plus, when enabled, the
+// certificate owner's contact.
+func (en *EmailNotifier) recipientsFor(alert model.ExpiryAlert) []string {
+	contact := alert.Certificate.ContactEmail
+	if !en.config.NotifyOwners || contact == "" || slices.Contains(en.config.To, contact) {
+		return en.config.To
+	}
+	return append(slices.Clone(en.config.To), contact)
+}
+
+func (en *EmailNotifier) send(ctx context.Context, rcpt string, msg []byte) error {
+	addr := net.JoinHostPort(en.config.Host, strconv.Itoa(en.config.Port))
+	dialer := &net.Dialer{Timeout: en.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(en.config.Timeout))
+
+	client, err := smtp.NewClient(conn, en.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		tlsConfig := en.config.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{ServerName: en.config.Host, MinVersion: tls.VersionTLS12}
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	} else if en.config.RequireTLS {
+		return ErrStartTLSRequired
+	}
+
+	if en.config.Username != "" {
+		// PlainAuth itself refuses to send credentials over an unencrypted
+		// connection to anything but localhost.
+		if err := client.Auth(smtp.PlainAuth("", en.config.Username, en.config.Password, en.config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(en.config.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(rcpt); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (en *EmailNotifier) record(ctx context.Context, alert model.ExpiryAlert, err error) {
+	if en.recorder == nil {
+		return
+	}
+	delivery := model.NotificationDelivery{
+		CertificateId: alert.Certificate.Id,
+		Channel:       en.Name(),
+		Target:        "smtp://" + net.JoinHostPort(en.config.Host, strconv.Itoa(en.config.Port)),
+		Threshold:     alert.Threshold,
+		Attempt:       1,
+		Success:       err == nil,
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	if rerr := en.recorder.RecordDelivery(ctx, delivery); rerr != nil {
+		en.logger.WarnContext(ctx, "recording email delivery failed",
+			"id", alert.Certificate.Id)
+	}
+}
+
+// severityOrder lists the digest groups, most urgent first.
+var severityOrder = []model.Severity{model.SeverityCritical, model.SeverityHigh, model.SeverityWarning, model.SeverityInfo}
+
+type emailGroup struct {
+	Severity model.Severity
+	Alerts   []emailAlert
+}
+
+type emailAlert struct {
+	CommonName    string
+	OwnerTeam     string
+	Issuer        string
+	SerialNumber  string
+	NotAfter      string
+	Threshold     string
+	DaysRemaining int
+}
+
+func groupAlerts(alerts []model.ExpiryAlert) []emailGroup {
+	groups := []emailGroup{}
+	for _, severity := range severityOrder {
+		group := emailGroup{Severity: severity}
+		for _, alert := range alerts {
+			if alert.Severity != severity {
+				continue
+			}
+			group.Alerts = append(group.Alerts, emailAlert{
+				CommonName:    alert.Certificate.CommonName,
+				OwnerTeam:     alert.Certificate.OwnerTeam,
+				Issuer:        alert.Certificate.Issuer,
+				SerialNumber:  alert.Certificate.SerialNumber,
+				NotAfter:      alert.Certificate.NotAfter.UTC().Format(time.RFC3339),
+				Threshold:     alert.Threshold,
+				DaysRemaining: DaysRemaining(alert),
+			})
+		}
+		if len(group.Alerts) == 0 {
+			continue
+		}
+		sort.Slice(group.Alerts, func(i, j int) bool {
+			return group.Alerts[i].NotAfter < group.Alerts[j].NotAfter
+		})
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func (en *EmailNotifier) subject(alerts []model.ExpiryAlert) string {
+	if en.config.Mode == EmailDigest {
+		return fmt.Sprintf("[certwatch] Certificate expiry digest: %d certificates", len(alerts))
+	}
+	alert := alerts[0]
+	if alert.Threshold == model.ExpiredThreshold {
+		return fmt.Sprintf("[certwatch] %s: %s has expired", alert.Severity, alert.Certificate.CommonName)
+	}
+	return fmt.Sprintf("[certwatch] %s: %s expires in %d days", alert.Severity, alert.Certificate.CommonName, DaysRemaining(alert))
+}
+
+// buildMessage renders a multipart/alternative email with a text and an HTML
+// part. Certificate fields are user supplied: headers are Q-encoded so they
+// cannot inject new header lines, and the HTML template escapes its input.
+func (en *EmailNotifier) buildMessage(rcpt string, alerts []model.ExpiryAlert) ([]byte, error) {
+	groups := groupAlerts(alerts)
+	var text, html bytes.Buffer
+	if err := textTemplate.Execute(&text, groups); err != nil {
+		return nil, err
+	}
+	if err := htmlTemplate.Execute(&html, groups); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	body := multipart.NewWriter(&msg)
+	headers := []string{
+		"From: " + en.config.From,
+		"To: " + rcpt,
+		"Subject: " + mime.QEncoding.Encode("utf-8", en.subject(alerts)),
+		"Date: " + time.Now().UTC().Format(time.RFC1123Z),
+		"Message-ID: <" + uuid.NewString() + "@certwatch>",
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + body.Boundary(),
+	}
+	var out bytes.Buffer
+	out.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(part.content); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	out.Write(msg.Bytes())
+	return out.Bytes(), nil
+}
@@ -0,0 +1,32 @@
+package notify
+
+import (
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+var textTemplate = texttemplate.Must(texttemplate.New("text").Parse(`Certificates approaching expiry
+{{range .}}
+== {{.Severity}} ==
+{{range .Alerts}}
+- {{.CommonName}} (serial {{.SerialNumber}}, issuer {{.Issuer}}{{if .OwnerTeam}}, owner {{.OwnerTeam}}{{end}})
+  expires {{.NotAfter}}, {{.DaysRemaining}} days remaining, stage {{.Threshold}}
+{{end}}{{end}}
+-- certwatch
+`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html>
+<body>
+<h2>Certificates approaching expiry</h2>
+{{range .}}
+<h3>{{.Severity}}</h3>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Common name</th><th>Owner</th><th>Serial</th><th>Issuer</th><th>Expires</th><th>Days remaining</th><th>Stage</th></tr>
+{{range .Alerts}}<tr><td>{{.CommonName}}</td><td>{{.OwnerTeam}}</td><td>{{.SerialNumber}}</td><td>{{.Issuer}}</td><td>{{.NotAfter}}</td><td>{{.DaysRemaining}}</td><td>{{.Threshold}}</td></tr>
+{{end}}</table>
+{{end}}
+<p>-- certwatch</p>
+</body>
+</html>
+`))
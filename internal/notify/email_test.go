@@ -0,0 +1,421 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+// fakeSMTPServer is a minimal in-process SMTP stand-in. It understands just
+// enough of the protocol for net/smtp: EHLO, optional STARTTLS, AUTH PLAIN,
+// MAIL, RCPT, DATA and QUIT.
+type fakeSMTPServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	messages []fakeSMTPMessage
+	// reject makes RCPT fail for recipients containing it.
+	reject string
+}
+
+type fakeSMTPMessage struct {
+	Auth bool
+	TLS  bool
+	From string
+	To   []string
+	Data string
+}
+
+func newFakeSMTPServer(t *testing.T, tlsConfig *tls.Config) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTPServer{listener: listener, tlsConfig: tlsConfig}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) received() []fakeSMTPMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakeSMTPMessage{}, s.messages...)
+}
+
+func (s *fakeSMTPServer) rejectRecipient(rcpt string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = rcpt
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	msg := fakeSMTPMessage{}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO":
+			if s.tlsConfig != nil && !msg.TLS {
+				reply("250-localhost")
+				reply("250 STARTTLS")
+			} else {
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			reader = bufio.NewReader(conn)
+			msg.TLS = true
+		case "AUTH":
+			msg.Auth = true
+			reply("235 ok")
+		case "MAIL":
+			msg.From = line
+			reply("250 ok")
+		case "RCPT":
+			s.mu.Lock()
+			reject := s.reject != "" && strings.Contains(line, s.reject)
+			s.mu.Unlock()
+			if reject {
+				reply("550 mailbox unavailable")
+				continue
+			}
+			msg.To = append(msg.To, line)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				dl, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dl == ".\r\n" {
+					break
+				}
+				data.WriteString(dl)
+			}
+			msg.Data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func newEmailAlert(id string, commonName string, severity model.Severity, days int) model.ExpiryAlert {
+	return model.ExpiryAlert{
+		Certificate: model.Certificate{Id: id, CommonName: commonName, NotAfter: time.Now().Add(time.Duration(days) * 24 * time.Hour)},
+		Threshold:   "7d",
+		Severity:    severity,
+		ExpiresIn:   time.Duration(days)*24*time.Hour + time.Hour,
+	}
+}
+
+// bodyParts decodes every part of a multipart email and joins them.
+func bodyParts(t *testing.T, data string) string {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(part)
+		out.Write(content)
+	}
+	return out.String()
+}
+
+// fakeDigestQueue keeps the digest queue in memory.
+type fakeDigestQueue struct {
+	mu      sync.Mutex
+	items   []model.DigestItem
+	claimed map[string]bool
+}
+
+func (q *fakeDigestQueue) EnqueueDigest(ctx context.Context, alert model.ExpiryAlert, recipients []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, rcpt := range recipients {
+		q.items = append(q.items, model.DigestItem{Id: strconv.Itoa(len(q.items)), Recipient: rcpt, Alert: alert})
+	}
+	return nil
+}
+
+func (q *fakeDigestQueue) ClaimDigest(ctx context.Context) ([]model.DigestItem, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.claimed == nil {
+		q.claimed = map[string]bool{}
+	}
+	items := []model.DigestItem{}
+	for _, item := range q.items {
+		if !q.claimed[item.Id] {
+			q.claimed[item.Id] = true
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (q *fakeDigestQueue) RemoveDigest(ctx context.Context, ids []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = slices.DeleteFunc(q.items, func(item model.DigestItem) bool {
+		return slices.Contains(ids, item.Id)
+	})
+	return nil
+}
+
+func (q *fakeDigestQueue) ReleaseDigest(ctx context.Context, ids []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, id := range ids {
+		delete(q.claimed, id)
+	}
+	return nil
+}
+
+func (q *fakeDigestQueue) queued() []model.DigestItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.items)
+}
+
+func newTestEmailNotifier(port int, mode EmailMode, recorder DeliveryRecorder, tlsConfig *tls.Config) *EmailNotifier {
+	return NewEmailNotifier(EmailConfig{
+		Host:           "127.0.0.1",
+		Port:           port,
+		Username:       "certwatch",
+		Password:       "secret",
+		From:           "certwatch@example.com",
+		To:             []string{"ops@example.com"},
+		NotifyOwners:   true,
+		Mode:           mode,
+		RequireTLS:     tlsConfig != nil,
+		DigestInterval: time.Hour,
+		Timeout:        2 * time.Second,
+		TLSConfig:      tlsConfig,
+	}, recorder, &fakeDigestQueue{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestEmailImmediate(t *testing.T) {
+	server := newFakeSMTPServer(t, nil)
+	recorder := &FakeRecorder{}
+	notifier := newTestEmailNotifier(server.port(), EmailImmediate, recorder, nil)
+
+	if err := notifier.Notify(context.Background(), newEmailAlert("id1", "api.example.com", model.SeverityHigh, 5)); err != nil {
+		t.Fatalf("Notify() = %v; want nil", err)
+	}
+
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("Notify() sent %d emails; want 1", len(messages))
+	}
+	msg := messages[0]
+	if !msg.Auth || len(msg.To) != 1 || !strings.Contains(msg.To[0], "ops@example.com") {
+		t.Errorf("Notify() sent %+v; want authenticated mail to ops@example.com", msg)
+	}
+	body := msg.Data + bodyParts(t, msg.Data)
+	for _, want := range []string{"multipart/alternative", "text/plain", "text/html", "api.example.com", "expires in 5 days", "5 days remaining"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Notify() email does not contain %q", want)
+		}
+	}
+	if len(recorder.deliveries) != 1 || !recorder.deliveries[0].Success {
+		t.Errorf("Notify() recorded %+v; want one successful delivery", recorder.deliveries)
+	}
+}
+
+func TestEmailDigest(t *testing.T) {
+	server := newFakeSMTPServer(t, nil)
+	notifier := newTestEmailNotifier(server.port(), EmailDigest, &FakeRecorder{}, nil)
+	ctx := context.Background()
+
+	notifier.Notify(ctx, newEmailAlert("id1", "later.example.com", model.SeverityInfo, 25))
+	notifier.Notify(ctx, newEmailAlert("id2", "<b>soon</b>.example.com", model.SeverityCritical, 0))
+	if len(server.received()) != 0 {
+		t.Fatalf("Notify() sent an email in digest mode")
+	}
+
+	if err := notifier.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v; want nil", err)
+	}
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("Flush() sent %d emails; want 1", len(messages))
+	}
+	data := bodyParts(t, messages[0].Data)
+	critical := strings.Index(data, "== critical ==")
+	info := strings.Index(data, "== info ==")
+	if critical < 0 || info < 0 || critical > info {
+		t.Errorf("Flush() digest is not grouped by urgency, most urgent first")
+	}
+	if !strings.Contains(data, "&lt;b&gt;soon&lt;/b&gt;") {
+		t.Errorf("Flush() digest does not escape HTML")
+	}
+
+	if err := notifier.Flush(ctx); err != nil || len(server.received()) != 1 {
+		t.Errorf("Flush() with nothing queued sent an email")
+	}
+}
+
+func TestEmailOwnerRouting(t *testing.T) {
+	server := newFakeSMTPServer(t, nil)
+	notifier := newTestEmailNotifier(server.port(), EmailDigest, &FakeRecorder{}, nil)
+	ctx := context.Background()
+
+	owned := newEmailAlert("id1", "payments.example.com", model.SeverityHigh, 5)
+	owned.Certificate.OwnerTeam = "payments"
+	owned.Certificate.ContactEmail = "payments@example.com"
+	notifier.Notify(ctx, owned)
+	notifier.Notify(ctx, newEmailAlert("id2", "other.example.com", model.SeverityHigh, 5))
+
+	if err := notifier.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v; want nil", err)
+	}
+	byRecipient := map[string]string{}
+	for _, msg := range server.received() {
+		byRecipient[msg.To[0]] = bodyParts(t, msg.Data)
+	}
+	ops := byRecipient["RCPT TO:<ops@example.com>"]
+	owner := byRecipient["RCPT TO:<payments@example.com>"]
+	if !strings.Contains(ops, "payments.example.com") || !strings.Contains(ops, "other.example.com") {
+		t.Errorf("Flush() global digest does not contain every alert")
+	}
+	if !strings.Contains(owner, "payments.example.com") || strings.Contains(owner, "other.example.com") {
+		t.Errorf("Flush() owner digest does not contain exactly the owned certificate")
+	}
+}
+
+func TestEmailDigestRetriesFailedRecipient(t *testing.T) {
+	server := newFakeSMTPServer(t, nil)
+	notifier := newTestEmailNotifier(server.port(), EmailDigest, &FakeRecorder{}, nil)
+	queue := notifier.queue.(*fakeDigestQueue)
+	ctx := context.Background()
+
+	owned := newEmailAlert("id1", "payments.example.com", model.SeverityHigh, 5)
+	owned.Certificate.ContactEmail = "payments@example.com"
+	notifier.Notify(ctx, owned)
+	server.rejectRecipient("payments@example.com")
+
+	if err := notifier.Flush(ctx); !errors.Is(err, ErrDeliveryFailed) {
+		t.Fatalf("Flush() = %v; want %v", err, ErrDeliveryFailed)
+	}
+	queued := queue.queued()
+	if len(queued) != 1 || queued[0].Recipient != "payments@example.com" {
+		t.Fatalf("Flush() left %+v queued; want only the failed recipient's alert", queued)
+	}
+
+	server.rejectRecipient("")
+	if err := notifier.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v; want nil", err)
+	}
+	messages := server.received()
+	if len(messages) != 2 || !strings.Contains(messages[1].To[0], "payments@example.com") {
+		t.Errorf("Flush() sent %+v; want the failed digest sent again to its recipient alone", messages)
+	}
+	if len(queue.queued()) != 0 {
+		t.Errorf("Flush() left %+v queued; want none", queue.queued())
+	}
+}
+
+func TestEmailStartTLS(t *testing.T) {
+	tlsServer := httptest.NewUnstartedServer(http.NotFoundHandler())
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+	clientTLS := tlsServer.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	clientTLS.ServerName = "127.0.0.1"
+
+	server := newFakeSMTPServer(t, tlsServer.TLS)
+	notifier := newTestEmailNotifier(server.port(), EmailImmediate, &FakeRecorder{}, clientTLS)
+
+	if err := notifier.Notify(context.Background(), newEmailAlert("id1", "api.example.com", model.SeverityHigh, 5)); err != nil {
+		t.Fatalf("Notify() = %v; want nil", err)
+	}
+	messages := server.received()
+	if len(messages) != 1 || !messages[0].TLS || !messages[0].Auth {
+		t.Fatalf("Notify() sent %+v; want one authenticated email over STARTTLS", messages)
+	}
+}
+
+func TestEmailRequireTLS(t *testing.T) {
+	server := newFakeSMTPServer(t, nil)
+	recorder := &FakeRecorder{}
+	notifier := newTestEmailNotifier(server.port(), EmailImmediate, recorder, &tls.Config{})
+
+	err := notifier.Notify(context.Background(), newEmailAlert("id1", "api.example.com", model.SeverityHigh, 5))
+	if !errors.Is(err, ErrDeliveryFailed) {
+		t.Errorf("Notify() = %v; want %v", err, ErrDeliveryFailed)
+	}
+	if len(server.received()) != 0 || len(recorder.deliveries) != 1 || recorder.deliveries[0].Success {
+		t.Errorf("Notify() without STARTTLS sent mail or recorded %+v", recorder.deliveries)
+	}
+}
@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"context"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+// Notifier delivers expiry alerts to a channel such as a webhook or email.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, alert model.ExpiryAlert) error
+}
+
+// DeliveryRecorder stores the outcome of every delivery attempt.
+type DeliveryRecorder interface {
+	RecordDelivery(ctx context.Context, delivery model.NotificationDelivery) error
+}
+
+// DaysRemaining rounds towards zero, so a certificate expiring later today has
+// 0 days left and one that expired yesterday has -1.
+func DaysRemaining(alert model.ExpiryAlert) int {
+	return int(alert.ExpiresIn / (24 * time.Hour))
+}
@@ -0,0 +1,250 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	SignatureHeader = "X-Certwatch-Signature"
+	TimestampHeader = "X-Certwatch-Timestamp"
+)
+
+var ErrDeliveryFailed = errors.New("delivery failed")
+
+type WebhookConfig struct {
+	URLs        []string
+	Secret      string
+	Timeout     time.Duration
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+type WebhookNotifier struct {
+	config   WebhookConfig
+	client   *http.Client
+	recorder DeliveryRecorder
+	logger   *slog.Logger
+}
+
+type WebhookCertificate struct {
+	Id                string            `json:"id"`
+	CommonName        string            `json:"common_name"`
+	SerialNumber      string            `json:"serial_number"`
+	Issuer            string            `json:"issuer"`
+	NotBefore         time.Time         `json:"not_before"`
+	NotAfter          time.Time         `json:"not_after"`
+	FingerprintSHA256 string            `json:"fingerprint_sha256"`
+	OwnerTeam         string            `json:"owner_team"`
+	ContactEmail      string            `json:"contact_email"`
+	Description       string            `json:"description"`
+	Labels            map[string]string `json:"labels"`
+	DNSNames          []string          `json:"dns_names"`
+}
+
+type WebhookPayload struct {
+	Event         string             `json:"event"`
+	Certificate   WebhookCertificate `json:"certificate"`
+	Threshold     string             `json:"threshold"`
+	Severity      model.Severity     `json:"severity"`
+	DaysRemaining int                `json:"days_remaining"`
+	SentAt        time.Time          `json:"sent_at"`
+}
+
+func NewWebhookNotifier(config WebhookConfig, recorder DeliveryRecorder, logger *slog.Logger) *WebhookNotifier {
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+	client := &http.Client{
+		// A redirect would hand the signed payload to a host nobody configured.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &WebhookNotifier{config: config, client: client, recorder: recorder, logger: logger}
+}
+
+func (wn *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Notify posts the alert to every configured URL. Each URL is retried with
+// exponential backoff on network errors, 429 and 5xx responses.
+func (wn *WebhookNotifier) Notify(ctx context.Context, alert model.ExpiryAlert) error {
+	body, err := json.Marshal(NewWebhookPayload(alert, time.Now().UTC()))
+	if err != nil {
+		return fmt.Errorf("Encoding webhook payload: %w", err)
+	}
+
+	var failed error
+	for _, target := range wn.config.URLs {
+		if err := wn.deliver(ctx, target, alert, body); err != nil {
+			failed = ErrDeliveryFailed
+		}
+	}
+	return failed
+}
+
+func NewWebhookPayload(alert model.ExpiryAlert, sentAt time.Time) WebhookPayload {
+	cert := alert.Certificate
+	return WebhookPayload{
+		Event: "certificate_expiring",
+		Certificate: WebhookCertificate{
+			Id:                cert.Id,
+			CommonName:        cert.CommonName,
+			SerialNumber:      cert.SerialNumber,
+			Issuer:            cert.Issuer,
+			NotBefore:         cert.NotBefore,
+			NotAfter:          cert.NotAfter,
+			FingerprintSHA256: cert.FingerprintSHA256,
+			OwnerTeam:         cert.OwnerTeam,
+			ContactEmail:      cert.ContactEmail,
+			Description:       cert.Description,
+			Labels:            cert.Labels,
+			DNSNames:          cert.DNSNames,
+		},
+		Threshold:     alert.Threshold,
+		Severity:      alert.Severity,
+		DaysRemaining: DaysRemaining(alert),
+		SentAt:        sentAt,
+	}
+}
+
+// Sign returns the signature header value for the body: the hex HMAC-SHA256
+// of "<timestamp>.<body>" keyed with the shared secret.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (wn *WebhookNotifier) deliver(ctx context.Context, target string, alert model.ExpiryAlert, body []byte) error {
+	backoff := wn.config.Backoff
+	var lastErr error
+	for attempt := 1; attempt <= wn.config.MaxAttempts; attempt++ {
+		status, err := wn.post(ctx, target, body)
+		retry := err != nil || status == http.StatusTooManyRequests || status >= 500
+		if err == nil && (status < 200 || status > 299) {
+			err = fmt.Errorf("unexpected status %d", status)
+		}
+		wn.record(ctx, target, alert, attempt, status, err)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		wn.logger.WarnContext(ctx, "webhook delivery failed",
+			"id", alert.Certificate.Id,
+			"target", redactURL(target),
+			"attempt", attempt,
+			"status_code", status)
+		if !retry || attempt == wn.config.MaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return lastErr
+}
+
+// post sends one attempt in a span of its own, and passes the trace on to
+// the receiver in the traceparent header.
+func (wn *WebhookNotifier) post(ctx context.Context, target string, body []byte) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, wn.config.Timeout)
+	defer cancel()
+	ctx, span := tracing.Tracer().Start(ctx, "POST",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", http.MethodPost),
+			attribute.String("certwatch.webhook.target", redactURL(target)),
+		))
+	defer func() {
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if err == nil && status >= 400 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		tracing.End(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "certwatch")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(wn.config.Secret, timestamp, body))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := wn.client.Do(req)
+	if err != nil {
+		// url.Error embeds the full URL; keep only the cause.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return 0, urlErr.Err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	return resp.StatusCode, nil
+}
+
+func (wn *WebhookNotifier) record(ctx context.Context, target string, alert model.ExpiryAlert, attempt int, status int, err error) {
+	if wn.recorder == nil {
+		return
+	}
+	delivery := model.NotificationDelivery{
+		CertificateId: alert.Certificate.Id,
+		Channel:       wn.Name(),
+		Target:        redactURL(target),
+		Threshold:     alert.Threshold,
+		Attempt:       attempt,
+		Success:       err == nil,
+		StatusCode:    status,
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	if rerr := wn.recorder.RecordDelivery(ctx, delivery); rerr != nil {
+		wn.logger.WarnContext(ctx, "recording webhook delivery failed",
+			"id", alert.Certificate.Id)
+	}
+}
+
+// redactURL keeps only the scheme and host. Webhook URLs often carry tokens
+// in their path or query, and those must not end up in logs or the database.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "invalid-url"
+	}
+	return u.Scheme + "://" + u.Host
+}
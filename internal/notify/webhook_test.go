@@ -0,0 +1,152 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/tracing"
+	"github.com/hytonhan/certwatch/internal/tracing/tracingtest"
+)
+
+type FakeRecorder struct {
+	mu         sync.Mutex
+	deliveries []model.NotificationDelivery
+}
+
+func (fr *FakeRecorder) RecordDelivery(ctx context.Context, delivery model.NotificationDelivery) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.deliveries = append(fr.deliveries, delivery)
+	return nil
+}
+
+func testAlert() model.ExpiryAlert {
+	return model.ExpiryAlert{
+		Certificate: model.Certificate{
+			Id:         "id1",
+			CommonName: "api.example.com",
+			NotAfter:   time.Now().Add(5 * 24 * time.Hour),
+		},
+		Threshold: "7d",
+		Severity:  model.SeverityHigh,
+		ExpiresIn: 5*24*time.Hour + time.Hour,
+	}
+}
+
+func TestWebhookNotify(t *testing.T) {
+	const secret = "s3cret"
+	calls := 0
+	var payload WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get(SignatureHeader); got != Sign(secret, r.Header.Get(TimestampHeader), body) {
+			t.Errorf("signature = %q; want a valid HMAC", got)
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.Unmarshal(body, &payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	recorder := &FakeRecorder{}
+	notifier := NewWebhookNotifier(WebhookConfig{
+		URLs:        []string{server.URL + "/hook?token=hidden"},
+		Secret:      secret,
+		Timeout:     time.Second,
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	}, recorder, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if err := notifier.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify() = %v; want nil", err)
+	}
+	if calls != 2 {
+		t.Errorf("Notify() made %d calls; want %d", calls, 2)
+	}
+	if payload.Certificate.CommonName != "api.example.com" || payload.DaysRemaining != 5 || payload.Threshold != "7d" {
+		t.Errorf("Notify() payload = %+v", payload)
+	}
+	if len(recorder.deliveries) != 2 || recorder.deliveries[0].Success || !recorder.deliveries[1].Success {
+		t.Fatalf("Notify() recorded %+v; want a failed and a successful attempt", recorder.deliveries)
+	}
+	if strings.Contains(recorder.deliveries[0].Target, "hidden") {
+		t.Errorf("Notify() recorded target %q; want it redacted", recorder.deliveries[0].Target)
+	}
+}
+
+func TestWebhookTraceparent(t *testing.T) {
+	spans := tracingtest.Record(t)
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	notifier := NewWebhookNotifier(WebhookConfig{
+		URLs:        []string{server.URL},
+		Secret:      "s3cret",
+		Timeout:     time.Second,
+		MaxAttempts: 1,
+	}, &FakeRecorder{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "notify webhook")
+	err := notifier.Notify(ctx, testAlert())
+	parent.End()
+	if err != nil {
+		t.Fatalf("Notify() = %v; want nil", err)
+	}
+
+	posts := tracingtest.Named(spans, "POST")
+	if len(posts) != 1 {
+		t.Fatalf("recorded %d POST spans; want 1", len(posts))
+	}
+	post := posts[0]
+	if post.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("POST span is not a child of the delivery")
+	}
+	want := "00-" + post.SpanContext.TraceID().String() + "-" + post.SpanContext.SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("traceparent = %q; want %q", traceparent, want)
+	}
+}
+
+func TestWebhookNotifyGivesUp(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	recorder := &FakeRecorder{}
+	notifier := NewWebhookNotifier(WebhookConfig{
+		URLs:        []string{server.URL},
+		Secret:      "s3cret",
+		Timeout:     time.Second,
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	}, recorder, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	err := notifier.Notify(context.Background(), testAlert())
+	if !errors.Is(err, ErrDeliveryFailed) {
+		t.Errorf("Notify() = %v; want %v", err, ErrDeliveryFailed)
+	}
+	// Client errors are not retried.
+	if calls != 1 || len(recorder.deliveries) != 1 || recorder.deliveries[0].StatusCode != http.StatusBadRequest {
+		t.Errorf("Notify() made %d calls and recorded %+v; want a single failed attempt", calls, recorder.deliveries)
+	}
+}
@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+// alertStateColumns include the owner of the certificate, which decides who
+// may acknowledge the alert.
+const alertStateColumns = `id, certificate_id, threshold, first_alerted_at, last_alerted_at, acknowledged, acknowledged_at, delivered,
+	(SELECT owner_team FROM certificates WHERE certificates.id = alert_states.certificate_id)`
+
+type AlertRepository interface {
+	Claim(ctx context.Context, state *model.AlertState, renotifyBefore time.Time, retryBefore time.Time) (bool, error)
+	MarkDelivered(ctx context.Context, certificateID string, threshold string) error
+	List(ctx context.Context, acknowledged *bool) ([]model.AlertState, error)
+	Get(ctx context.Context, id string) (*model.AlertState, error)
+	Acknowledge(ctx context.Context, id string, at time.Time, ownerTeam string) error
+}
+
+type alertRepository struct {
+	db *tracedDB
+}
+
+func NewAlertRepository(db *sql.DB) *alertRepository {
+	return &alertRepository{db: &tracedDB{db: db}}
+}
+
+// Claim reports whether the caller should send the alert. It inserts the
+// alert state if the certificate has never been alerted for the threshold.
+// Otherwise it claims the unacknowledged state again when its delivery has not
+// succeeded and it was last claimed before retryBefore, or for a reminder when
+// it was last alerted before renotifyBefore; a zero renotifyBefore disables
+// reminders. A claimed state is undelivered until MarkDelivered. Both
+// statements are conditional, so only one replica wins each claim.
+func (ar *alertRepository) Claim(ctx context.Context, state *model.AlertState, renotifyBefore time.Time, retryBefore time.Time) (bool, error) {
+
+	result, err := ar.db.ExecContext(ctx, `INSERT INTO alert_states (id, certificate_id, threshold, first_alerted_at, last_alerted_at, delivered)
+		VALUES(?,?,?,?,?,0)
+		ON CONFLICT(certificate_id, threshold) DO NOTHING`,
+		state.Id,
+		state.CertificateId,
+		state.Threshold,
+		state.FirstAlertedAt,
+		state.LastAlertedAt)
+	if err != nil {
+		return false, fmt.Errorf("Claiming alert: %w", err)
+	}
+	rows, rowerr := result.RowsAffected()
+	if rowerr != nil {
+		return false, fmt.Errorf("Claiming alert: %w", rowerr)
+	}
+	if rows > 0 {
+		return true, nil
+	}
+
+	result, err = ar.db.ExecContext(ctx, `UPDATE alert_states
+		SET last_alerted_at = ?, delivered = 0
+		WHERE certificate_id = ? AND threshold = ? AND acknowledged = 0
+		AND ((delivered = 0 AND last_alerted_at < ?) OR (? AND last_alerted_at < ?))`,
+		state.LastAlertedAt,
+		state.CertificateId,
+		state.Threshold,
+		retryBefore,
+		!renotifyBefore.IsZero(),
+		renotifyBefore)
+	if err != nil {
+		return false, fmt.Errorf("Claiming alert again: %w", err)
+	}
+	rows, rowerr = result.RowsAffected()
+	if rowerr != nil {
+		return false, fmt.Errorf("Claiming alert again: %w", rowerr)
+	}
+	return rows > 0, nil
+}
+
+// MarkDelivered records that the claimed alert was sent.
+func (ar *alertRepository) MarkDelivered(ctx context.Context, certificateID string, threshold string) error {
+
+	_, err := ar.db.ExecContext(ctx,
+		"UPDATE alert_states SET delivered = 1 WHERE certificate_id = ? AND threshold = ?",
+		certificateID,
+		threshold)
+	if err != nil {
+		return fmt.Errorf("Marking alert delivered: %w", err)
+	}
+	return nil
+}
+
+func (ar *alertRepository) List(ctx context.Context, acknowledged *bool) ([]model.AlertState, error) {
+
+	query := "SELECT " + alertStateColumns + " FROM alert_states"
+	args := []any{}
+	if acknowledged != nil {
+		query += " WHERE acknowledged = ?"
+		args = append(args, *acknowledged)
+	}
+	query += " ORDER BY last_alerted_at DESC"
+
+	result, err := ar.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for alerts: %w", err)
+	}
+	defer result.Close()
+
+	retValue := []model.AlertState{}
+	for result.Next() {
+		item, err2 := scanAlertState(result)
+		if err2 != nil {
+			return nil, fmt.Errorf("Querying for alerts: %w", err2)
+		}
+		retValue = append(retValue, item)
+	}
+	if er := result.Err(); er != nil {
+		return nil, fmt.Errorf("Querying for alerts: %w", er)
+	}
+	return retValue, nil
+}
+
+func (ar *alertRepository) Get(ctx context.Context, id string) (*model.AlertState, error) {
+
+	row := ar.db.QueryRowContext(ctx, "SELECT "+alertStateColumns+" FROM alert_states WHERE id = ?", id)
+	item, err := scanAlertState(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("Getting alert: %w", err)
+	}
+	return &item, nil
+}
+
+// Acknowledge marks the alert acknowledged. Acknowledging it again keeps the
+// original acknowledged_at, but is still recorded in the audit trail. The
+// certificate must still be owned by ownerTeam, the owner the caller was
+// authorized against, or ErrStaleVersion is returned.
+func (ar *alertRepository) Acknowledge(ctx context.Context, id string, at time.Time, ownerTeam string) error {
+
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Acknowledging alert: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getAlertState(ctx, tx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("Acknowledging alert: %w", err)
+	}
+	if before.OwnerTeam != ownerTeam {
+		return ErrStaleVersion
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE alert_states SET acknowledged = 1, acknowledged_at = COALESCE(acknowledged_at, ?) WHERE id = ?",
+		at,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("Acknowledging alert: %w", err)
+	}
+	after, err := getAlertState(ctx, tx, id)
+	if err != nil {
+		return fmt.Errorf("Acknowledging alert: %w", err)
+	}
+	if err := recordAudit(ctx, tx, model.AuditAlertAcknowledge, model.AuditEntityAlert, id, before, after); err != nil {
+		return fmt.Errorf("Acknowledging alert: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Acknowledging alert: %w", err)
+	}
+
+	return nil
+}
+
+func getAlertState(ctx context.Context, tx *tracedTx, id string) (*model.AlertState, error) {
+	row := tx.QueryRowContext(ctx, "SELECT "+alertStateColumns+" FROM alert_states WHERE id = ?", id)
+	item, err := scanAlertState(row)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func scanAlertState(row rowScanner) (model.AlertState, error) {
+	item := model.AlertState{}
+	var acknowledgedAt sql.NullTime
+	var ownerTeam sql.NullString
+	err := row.Scan(
+		&item.Id,
+		&item.CertificateId,
+		&item.Threshold,
+		&item.FirstAlertedAt,
+		&item.LastAlertedAt,
+		&item.Acknowledged,
+		&acknowledgedAt,
+		&item.Delivered,
+		&ownerTeam)
+	if err != nil {
+		return item, err
+	}
+	if acknowledgedAt.Valid {
+		item.AcknowledgedAt = &acknowledgedAt.Time
+	}
+	item.OwnerTeam = ownerTeam.String
+	return item, nil
+}
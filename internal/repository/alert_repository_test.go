@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+func TestClaimRetriesUndelivered(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := newTestDB(t)
+	if err := NewCertificateRepository(sqlDB).Create(ctx, testCertificate("cert", 1)); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewAlertRepository(sqlDB)
+	start := time.Now().UTC()
+	claim := func(at time.Time, retryBefore time.Time) bool {
+		t.Helper()
+		state := &model.AlertState{Id: at.String(), CertificateId: "cert", Threshold: "7d", FirstAlertedAt: at, LastAlertedAt: at}
+		claimed, err := repo.Claim(ctx, state, time.Time{}, retryBefore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return claimed
+	}
+
+	if !claim(start, start) {
+		t.Fatal("first Claim() = false; want true")
+	}
+	if claim(start.Add(time.Minute), start) {
+		t.Error("Claim() while the delivery may still run = true; want false")
+	}
+	later := start.Add(10 * time.Minute)
+	if !claim(later, later.Add(-5*time.Minute)) {
+		t.Error("Claim() of an undelivered alert after the retry delay = false; want true")
+	}
+	if err := repo.MarkDelivered(ctx, "cert", "7d"); err != nil {
+		t.Fatal(err)
+	}
+	latest := later.Add(10 * time.Minute)
+	if claim(latest, latest.Add(-5*time.Minute)) {
+		t.Error("Claim() of a delivered alert = true; want false without reminders")
+	}
+}
+
+func TestAcknowledgeChecksOwner(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := newTestDB(t)
+	cert := testCertificate("cert", 1)
+	cert.OwnerTeam = "payments"
+	if err := NewCertificateRepository(sqlDB).Create(ctx, cert); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewAlertRepository(sqlDB)
+	now := time.Now().UTC()
+	state := &model.AlertState{Id: "alert", CertificateId: "cert", Threshold: "7d", FirstAlertedAt: now, LastAlertedAt: now}
+	if _, err := repo.Claim(ctx, state, time.Time{}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := repo.Get(ctx, "alert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.OwnerTeam != "payments" {
+		t.Errorf("Get() owner = %q; want the certificate's owner", stored.OwnerTeam)
+	}
+	if err := repo.Acknowledge(ctx, "alert", now, "billing"); !errors.Is(err, ErrStaleVersion) {
+		t.Errorf("Acknowledge() for another owner = %v; want %v", err, ErrStaleVersion)
+	}
+	if err := repo.Acknowledge(ctx, "alert", now, "payments"); err != nil {
+		t.Errorf("Acknowledge() = %v; want nil", err)
+	}
+	if _, err := repo.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a missing alert = %v; want %v", err, ErrNotFound)
+	}
+}
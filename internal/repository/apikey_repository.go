@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const apiKeyColumns = `id, name, prefix, secret_hash, scopes, team, created_at, last_used_at, revoked_at`
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *model.APIKey) error
+	GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error)
+	List(ctx context.Context) ([]model.APIKey, error)
+	Revoke(ctx context.Context, id string, at time.Time) error
+	Touch(ctx context.Context, id string, at time.Time, before time.Time) error
+}
+
+type apiKeyRepository struct {
+	db *tracedDB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *apiKeyRepository {
+	return &apiKeyRepository{db: &tracedDB{db: db}}
+}
+
+func (ar *apiKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Creating api key: %w", err)
+	}
+	defer tx.Rollback()
+
+	scopes, err := json.Marshal(orEmpty(key.Scopes))
+	if err != nil {
+		return fmt.Errorf("Creating api key: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO api_keys (id, name, prefix, secret_hash, scopes, team, created_at) VALUES(?,?,?,?,?,?,?)",
+		key.Id,
+		key.Name,
+		key.Prefix,
+		key.SecretHash,
+		string(scopes),
+		key.Team,
+		key.CreatedAt)
+	if err != nil {
+		var sqlErr *sqlite.Error
+		if errors.As(err, &sqlErr) {
+			if sqlErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+				return ErrConflict
+			}
+		}
+		return fmt.Errorf("Creating api key: %w", err)
+	}
+	if err := recordAudit(ctx, tx, model.AuditAPIKeyCreate, model.AuditEntityAPIKey, key.Id, nil, key); err != nil {
+		return fmt.Errorf("Creating api key: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Creating api key: %w", err)
+	}
+	return nil
+}
+
+func (ar *apiKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+
+	row := ar.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix = ?", prefix)
+	key, err := scanAPIKey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("Get api key: %w", err)
+	}
+	return &key, nil
+}
+
+func (ar *apiKeyRepository) List(ctx context.Context) ([]model.APIKey, error) {
+
+	result, err := ar.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("Querying for api keys: %w", err)
+	}
+	defer result.Close()
+
+	retValue := []model.APIKey{}
+	for result.Next() {
+		item, err2 := scanAPIKey(result)
+		if err2 != nil {
+			return nil, fmt.Errorf("Querying for api keys: %w", err2)
+		}
+		retValue = append(retValue, item)
+	}
+	if er := result.Err(); er != nil {
+		return nil, fmt.Errorf("Querying for api keys: %w", er)
+	}
+	return retValue, nil
+}
+
+// Revoke disables the key for good. Revoking it again keeps the original
+// revoked_at and records nothing.
+func (ar *apiKeyRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Revoking api key: %w", err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?", id)
+	before, err := scanAPIKey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("Revoking api key: %w", err)
+	}
+	if before.RevokedAt != nil {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE api_keys SET revoked_at = ? WHERE id = ?", at, id); err != nil {
+		return fmt.Errorf("Revoking api key: %w", err)
+	}
+	after := before
+	after.RevokedAt = &at
+	if err := recordAudit(ctx, tx, model.AuditAPIKeyRevoke, model.AuditEntityAPIKey, id, before, after); err != nil {
+		return fmt.Errorf("Revoking api key: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Revoking api key: %w", err)
+	}
+	return nil
+}
+
+// Touch records that the key was used at at, unless that was already recorded
+// after before, so that authenticating does not write on every request.
+func (ar *apiKeyRepository) Touch(ctx context.Context, id string, at time.Time, before time.Time) error {
+
+	_, err := ar.db.ExecContext(ctx,
+		"UPDATE api_keys SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)",
+		at,
+		id,
+		before)
+	if err != nil {
+		return fmt.Errorf("Touching api key: %w", err)
+	}
+	return nil
+}
+
+func scanAPIKey(row rowScanner) (model.APIKey, error) {
+	item := model.APIKey{}
+	var scopes string
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&item.Id,
+		&item.Name,
+		&item.Prefix,
+		&item.SecretHash,
+		&scopes,
+		&item.Team,
+		&item.CreatedAt,
+		&lastUsedAt,
+		&revokedAt)
+	if err != nil {
+		return item, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &item.Scopes); err != nil {
+		return item, err
+	}
+	if lastUsedAt.Valid {
+		item.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		item.RevokedAt = &revokedAt.Time
+	}
+	return item, nil
+}
@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/audit"
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+const auditEventColumns = `id, occurred_at, actor, request_id, action, entity_type, entity_id, before, after, prev_hash, hash`
+
+type AuditRepository interface {
+	List(ctx context.Context, filter AuditFilter, before model.AuditEventId, limit int) ([]model.AuditEvent, error)
+	ListAfter(ctx context.Context, after model.AuditEventId, limit int) ([]model.AuditEvent, error)
+	Seal(ctx context.Context) (int, error)
+	RecordDenied(ctx context.Context, entityType string, entityID string, denial model.AccessDenial) error
+}
+
+// AuditFilter narrows List. Zero values do not filter.
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	EntityType string
+	EntityId   string
+	RequestId  string
+	From       time.Time
+	To         time.Time
+}
+
+type auditRepository struct {
+	db *tracedDB
+}
+
+func NewAuditRepository(db *sql.DB) *auditRepository {
+	return &auditRepository{db: &tracedDB{db: db}}
+}
+
+// recordAudit appends an audit event within the transaction of the change it
+// describes, so that a change is never stored without its event. The actor and
+// request id come from ctx. before and after are snapshots of the entity; nil
+// stores null. The event is chained to the newest event; the transaction
+// holds the database lock, so no other event can be appended in between.
+func recordAudit(ctx context.Context, tx *tracedTx, action string, entityType string, entityID string, before any, after any) error {
+	beforeJSON, err := snapshotJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshotJSON(after)
+	if err != nil {
+		return err
+	}
+
+	event := model.AuditEvent{
+		OccurredAt: time.Now().UTC(),
+		Actor:      audit.ActorFrom(ctx),
+		RequestId:  audit.RequestIDFrom(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityId:   entityID,
+		Before:     beforeJSON,
+		After:      afterJSON,
+	}
+	var lastID model.AuditEventId
+	err = tx.QueryRowContext(ctx, "SELECT id, hash FROM audit_events ORDER BY id DESC LIMIT 1").Scan(&lastID, &event.PrevHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		event.PrevHash = audit.GenesisHash
+	} else if err != nil {
+		return err
+	}
+	// The id is part of the hash, so it is assigned here rather than by SQLite.
+	event.Id = lastID + 1
+	event.Hash = audit.Hash(event)
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO audit_events (id, occurred_at, actor, request_id, action, entity_type, entity_id, before, after, prev_hash, hash)
+		VALUES(?,?,?,?,?,?,?,?,?,?,?)`,
+		event.Id,
+		event.OccurredAt,
+		event.Actor,
+		event.RequestId,
+		event.Action,
+		event.EntityType,
+		event.EntityId,
+		nullJSON(event.Before),
+		nullJSON(event.After),
+		event.PrevHash,
+		event.Hash)
+	return err
+}
+
+func snapshotJSON(snapshot any) (json.RawMessage, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	return json.Marshal(snapshot)
+}
+
+func nullJSON(snapshot json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(snapshot), Valid: snapshot != nil}
+}
+
+// List returns the events older than the event before, newest first. A zero
+// before starts from the newest event.
+func (ar *auditRepository) List(ctx context.Context, filter AuditFilter, before model.AuditEventId, limit int) ([]model.AuditEvent, error) {
+
+	conditions, args := auditConditions(filter)
+	if before > 0 {
+		conditions = append(conditions, "id < ?")
+		args = append(args, before)
+	}
+	query := "SELECT " + auditEventColumns + " FROM audit_events"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+
+	result, err := ar.db.QueryContext(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for audit events: %w", err)
+	}
+	defer result.Close()
+
+	retValue, err := scanAuditEvents(result)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for audit events: %w", err)
+	}
+	return retValue, nil
+}
+
+// ListAfter returns the events following the event after, oldest first, for
+// walking the hash chain.
+func (ar *auditRepository) ListAfter(ctx context.Context, after model.AuditEventId, limit int) ([]model.AuditEvent, error) {
+
+	result, err := ar.db.QueryContext(
+		ctx,
+		"SELECT "+auditEventColumns+" FROM audit_events WHERE id > ? ORDER BY id LIMIT ?",
+		after,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for audit events: %w", err)
+	}
+	defer result.Close()
+
+	retValue, err := scanAuditEvents(result)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for audit events: %w", err)
+	}
+	return retValue, nil
+}
+
+// Seal chains the events recorded before events were hashed, oldest first,
+// and returns how many there were. The update trigger allows setting the
+// hash of an event only while it has none.
+func (ar *auditRepository) Seal(ctx context.Context) (int, error) {
+
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("Sealing audit events: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.QueryContext(ctx, "SELECT "+auditEventColumns+" FROM audit_events WHERE hash = '' ORDER BY id")
+	if err != nil {
+		return 0, fmt.Errorf("Sealing audit events: %w", err)
+	}
+	unsealed, err := scanAuditEvents(result)
+	result.Close()
+	if err != nil {
+		return 0, fmt.Errorf("Sealing audit events: %w", err)
+	}
+	if len(unsealed) == 0 {
+		return 0, nil
+	}
+
+	prevHash := audit.GenesisHash
+	err = tx.QueryRowContext(ctx, "SELECT hash FROM audit_events WHERE id < ? ORDER BY id DESC LIMIT 1", unsealed[0].Id).Scan(&prevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("Sealing audit events: %w", err)
+	}
+	for _, event := range unsealed {
+		event.PrevHash = prevHash
+		event.Hash = audit.Hash(event)
+		if _, err := tx.ExecContext(ctx, "UPDATE audit_events SET prev_hash = ?, hash = ? WHERE id = ?", event.PrevHash, event.Hash, event.Id); err != nil {
+			return 0, fmt.Errorf("Sealing audit events: %w", err)
+		}
+		prevHash = event.Hash
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Sealing audit events: %w", err)
+	}
+	return len(unsealed), nil
+}
+
+func auditConditions(filter AuditFilter) ([]string, []any) {
+	conditions := []string{}
+	args := []any{}
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.EntityType != "" {
+		conditions = append(conditions, "entity_type = ?")
+		args = append(args, filter.EntityType)
+	}
+	if filter.EntityId != "" {
+		conditions = append(conditions, "entity_id = ?")
+		args = append(args, filter.EntityId)
+	}
+	if filter.RequestId != "" {
+		conditions = append(conditions, "request_id = ?")
+		args = append(args, filter.RequestId)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "occurred_at >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "occurred_at < ?")
+		args = append(args, filter.To.UTC())
+	}
+	return conditions, args
+}
+
+func scanAuditEvents(rows *sql.Rows) ([]model.AuditEvent, error) {
+	retValue := []model.AuditEvent{}
+	for rows.Next() {
+		item := model.AuditEvent{}
+		var before, after sql.NullString
+		err := rows.Scan(
+			&item.Id,
+			&item.OccurredAt,
+			&item.Actor,
+			&item.RequestId,
+			&item.Action,
+			&item.EntityType,
+			&item.EntityId,
+			&before,
+			&after,
+			&item.PrevHash,
+			&item.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if before.Valid {
+			item.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			item.After = json.RawMessage(after.String)
+		}
+		retValue = append(retValue, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return retValue, nil
+}
+
+// RecordDenied records a refused change. Nothing else is written, so the
+// event has a transaction of its own.
+func (ar *auditRepository) RecordDenied(ctx context.Context, entityType string, entityID string, denial model.AccessDenial) error {
+
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Recording denial: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := recordAudit(ctx, tx, model.AuditAccessDenied, entityType, entityID, nil, denial); err != nil {
+		return fmt.Errorf("Recording denial: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Recording denial: %w", err)
+	}
+	return nil
+}
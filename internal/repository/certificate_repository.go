@@ -3,10 +3,14 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/hytonhan/certwatch/internal/labels"
 	"github.com/hytonhan/certwatch/internal/model"
 	"modernc.org/sqlite"
 	sqlite3 "modernc.org/sqlite/lib"
@@ -15,27 +19,266 @@ import (
 var (
 	ErrNotFound = errors.New("not_found")
 	ErrConflict = errors.New("conflict")
+	// ErrStaleVersion means the record was changed since the caller read it.
+	ErrStaleVersion = errors.New("stale_version")
+	// ErrInvalidKeyset means a page position does not fit its sort field.
+	ErrInvalidKeyset = errors.New("invalid_keyset")
+	// ErrQueueFull means a queue holds as many items as it may.
+	ErrQueueFull = errors.New("queue_full")
+)
+
+// maxChainDepth bounds the recursive chain queries so that a corrupted
+// issuer link can never make them loop.
+const maxChainDepth = 16
+
+const certificateColumns = `c.id, c.common_name, c.serial_number, c.issuer, c.not_before, c.not_after, c.fingerprint_sha256, c.created_at,
+	c.subject_key_id, c.authority_key_id, c.issuer_id, c.owner_team, c.contact_email, c.description,
+	c.notes, c.version, c.key_algorithm, c.key_size, c.key_curve, c.signature_algorithm, c.key_usage, c.ext_key_usage, c.is_ca, c.self_signed, c.pem,
+	(SELECT json_group_object(l.key, l.value) FROM certificate_labels l WHERE l.certificate_id = c.id),
+	(SELECT json_group_array(json_array(s.type, s.value)) FROM certificate_sans s WHERE s.certificate_id = c.id)`
+
+const (
+	sanDNS   = "dns"
+	sanIP    = "ip"
+	sanURI   = "uri"
+	sanEmail = "email"
 )
 
 type CertificateRepository interface {
 	Create(ctx context.Context, cert *model.Certificate) error
 	GetByID(ctx context.Context, id string) (*model.Certificate, error)
-	List(ctx context.Context) ([]model.Certificate, error)
-	ListExpiring(ctx context.Context, before time.Time, now time.Time) ([]model.Certificate, error)
-	Delete(ctx context.Context, id string) error
+	GetByFingerprint(ctx context.Context, fingerprint string) (*model.Certificate, error)
+	List(ctx context.Context, filter CertificateFilter, page Page) ([]model.Certificate, error)
+	ListExpiring(ctx context.Context, before time.Time, after time.Time) ([]model.Certificate, error)
+	Update(ctx context.Context, cert *model.Certificate) error
+	Delete(ctx context.Context, id string, version int64) error
+	ImportChain(ctx context.Context, members []ChainMember) error
+	LinkByKeyId(ctx context.Context, id string) error
+	GetChain(ctx context.Context, id string) ([]model.Certificate, error)
+	ListIssuedBy(ctx context.Context, issuerID string) ([]model.Certificate, error)
+	Search(ctx context.Context, match string, limit int) ([]model.SearchResult, error)
+	Summarize(ctx context.Context, filter CertificateFilter) (*model.CryptoSummary, error)
+	SummarizeExpiry(ctx context.Context, now time.Time, windows []time.Duration) (*model.ExpirySummary, error)
+}
+
+// CertificateFilter narrows List and Summarize. Zero values do not filter.
+type CertificateFilter struct {
+	OwnerTeam        string
+	Selector         labels.Selector
+	Issuer           string
+	CommonNamePrefix string
+	// DNSName matches certificates whose DNS SANs or common name cover the
+	// name, exactly or through a wildcard.
+	DNSName string
+	// NotAfterFrom is inclusive and NotAfterTo exclusive.
+	NotAfterFrom time.Time
+	NotAfterTo   time.Time
+	KeyAlgorithm string
+	KeySize      int
+	// KeySizeBelow leaves out keys of unknown size.
+	KeySizeBelow       int
+	SignatureAlgorithm string
+	KeyUsage           string
+	ExtKeyUsage        string
+	IsCA               *bool
+}
+
+type SortField string
+
+const (
+	SortNotAfter   SortField = "not_after"
+	SortNotBefore  SortField = "not_before"
+	SortCreatedAt  SortField = "created_at"
+	SortCommonName SortField = "common_name"
+	SortIssuer     SortField = "issuer"
+)
+
+// sortColumns is the whitelist of sortable columns. Only these names ever
+// reach the ORDER BY clause.
+var sortColumns = map[SortField]string{
+	SortNotAfter:   "c.not_after",
+	SortNotBefore:  "c.not_before",
+	SortCreatedAt:  "c.created_at",
+	SortCommonName: "c.common_name",
+	SortIssuer:     "c.issuer",
+}
+
+// Page selects one page of a List ordered by Sort and then id.
+type Page struct {
+	Sort  SortField
+	Desc  bool
+	After *Keyset
+	Limit int
+}
+
+// Keyset is the position a page continues from: the sort value and the id
+// of the last certificate of the previous page.
+type Keyset struct {
+	Value string
+	Id    string
+}
+
+// ChainMember is one certificate of a chain stored by ImportChain.
+type ChainMember struct {
+	Certificate *model.Certificate
+	// Issuer is the index of the member that signed the certificate, or -1.
+	Issuer int
+	// Version is the version of the stored certificate the caller checked,
+	// or 0 if it found none. ImportChain is ErrStaleVersion if that changed.
+	Version int64
+	// Created is set by ImportChain: false when the certificate was already
+	// stored, in which case Certificate is replaced by the stored one.
+	Created bool
+}
+
+func ValidSortField(field SortField) bool {
+	_, ok := sortColumns[field]
+	return ok
 }
 
 type certificateRepository struct {
-	db *sql.DB
+	db *tracedDB
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
 }
 
 func NewCertificateRepository(db *sql.DB) *certificateRepository {
-	return &certificateRepository{db: db}
+	return &certificateRepository{db: &tracedDB{db: db}}
 }
 
+// Create stores the certificate together with its labels.
 func (cr *certificateRepository) Create(ctx context.Context, cert *model.Certificate) error {
 
-	_, err := cr.db.ExecContext(ctx, "INSERT INTO certificates (id, common_name, serial_number, issuer, not_before, not_after, fingerprint_sha256, created_at)  VALUES(?,?,?,?,?,?,?,?)",
+	tx, err := cr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Creating cert: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := createCertificate(ctx, tx, cert); err != nil {
+		if errors.Is(err, ErrConflict) {
+			return ErrConflict
+		}
+		return fmt.Errorf("Creating cert: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Creating cert: %w", err)
+	}
+	return nil
+}
+
+// ImportChain stores the members of a chain in one transaction, so that a
+// failure leaves none of them behind. Every member must be stored at the
+// version the caller checked, or not at all if it found none. A member already stored by its
+// fingerprint is kept, getting only the key details it may lack. Each member
+// is then linked to its issuer, and each new member to the stored
+// certificates it shares key ids with.
+func (cr *certificateRepository) ImportChain(ctx context.Context, members []ChainMember) error {
+
+	tx, err := cr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Importing chain: %w", err)
+	}
+	defer tx.Rollback()
+
+	// befores are the snapshots of the stored members, whose changes are
+	// recorded once the chain is linked.
+	befores := map[string]*model.Certificate{}
+	for i := range members {
+		member := &members[i]
+		existing, err := getCertificateByFingerprint(ctx, tx, member.Certificate.FingerprintSHA256)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("Importing chain: %w", err)
+		}
+		if (existing == nil && member.Version != 0) || (existing != nil && existing.Version != member.Version) {
+			return ErrStaleVersion
+		}
+		if existing == nil {
+			if err := createCertificate(ctx, tx, member.Certificate); err != nil {
+				if errors.Is(err, ErrConflict) {
+					return ErrConflict
+				}
+				return fmt.Errorf("Importing chain: %w", err)
+			}
+			member.Created = true
+			continue
+		}
+		if _, ok := befores[existing.Id]; !ok {
+			before := *existing
+			befores[existing.Id] = &before
+		}
+		if existing.KeyAlgorithm == "" && member.Certificate.KeyAlgorithm != "" {
+			// Registered before key details were recorded.
+			copyKeyDetails(existing, member.Certificate)
+			if err := fillKeyDetails(ctx, tx, existing); err != nil {
+				return fmt.Errorf("Importing chain: %w", err)
+			}
+			existing.Version++
+		}
+		if existing.PEM == "" && member.Certificate.PEM != "" {
+			// Registered by hand, or before the PEM was kept.
+			copyPEM(existing, member.Certificate)
+			if err := fillPEM(ctx, tx, existing); err != nil {
+				return fmt.Errorf("Importing chain: %w", err)
+			}
+			existing.Version++
+		}
+		member.Certificate = existing
+	}
+
+	for _, member := range members {
+		if member.Issuer < 0 {
+			continue
+		}
+		issuerID := members[member.Issuer].Certificate.Id
+		if issuerID == member.Certificate.Id {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE certificates SET issuer_id = ? WHERE id = ? AND issuer_id IS NOT ?", issuerID, member.Certificate.Id, issuerID); err != nil {
+			return fmt.Errorf("Importing chain: %w", err)
+		}
+		member.Certificate.IssuerId = issuerID
+	}
+	for _, member := range members {
+		before, ok := befores[member.Certificate.Id]
+		if member.Created || !ok {
+			continue
+		}
+		delete(befores, member.Certificate.Id)
+		if err := recordUpdate(ctx, tx, before); err != nil {
+			return fmt.Errorf("Importing chain: %w", err)
+		}
+	}
+	for _, member := range members {
+		if !member.Created || (member.Certificate.SubjectKeyId == "" && member.Certificate.AuthorityKeyId == "") {
+			continue
+		}
+		if err := linkByKeyId(ctx, tx, member.Certificate.Id); err != nil {
+			return fmt.Errorf("Importing chain: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Importing chain: %w", err)
+	}
+	return nil
+}
+
+// createCertificate stores the certificate, its labels and SANs, and its
+// audit event within tx. A certificate already stored is ErrConflict.
+func createCertificate(ctx context.Context, tx *tracedTx, cert *model.Certificate) error {
+
+	keyUsage, extKeyUsage, err := usagesJSON(cert)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO certificates (id, common_name, serial_number, issuer, not_before, not_after, fingerprint_sha256, created_at,
+		subject_key_id, authority_key_id, owner_team, contact_email, description, notes, version,
+		key_algorithm, key_size, key_curve, signature_algorithm, key_usage, ext_key_usage, is_ca, self_signed, pem)
+		VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
 		cert.Id,
 		cert.CommonName,
 		cert.SerialNumber,
@@ -43,7 +286,23 @@ func (cr *certificateRepository) Create(ctx context.Context, cert *model.Certifi
 		cert.NotBefore,
 		cert.NotAfter,
 		cert.FingerprintSHA256,
-		cert.CreatedAt)
+		cert.CreatedAt,
+		cert.SubjectKeyId,
+		cert.AuthorityKeyId,
+		cert.OwnerTeam,
+		cert.ContactEmail,
+		cert.Description,
+		cert.Notes,
+		cert.Version,
+		cert.KeyAlgorithm,
+		cert.KeySize,
+		cert.KeyCurve,
+		cert.SignatureAlgorithm,
+		keyUsage,
+		extKeyUsage,
+		cert.IsCA,
+		cert.SelfSigned,
+		cert.PEM)
 	if err != nil {
 		var sqlErr *sqlite.Error
 		if errors.As(err, &sqlErr) {
@@ -51,27 +310,25 @@ func (cr *certificateRepository) Create(ctx context.Context, cert *model.Certifi
 				return ErrConflict
 			}
 		}
-		return fmt.Errorf("Creating cert: %w", err)
+		return err
 	}
-	return nil
+	if err := insertLabels(ctx, tx, cert.Id, cert.Labels); err != nil {
+		return err
+	}
+	if err := insertSANs(ctx, tx, cert); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, model.AuditCertificateCreate, model.AuditEntityCertificate, cert.Id, nil, cert)
 }
 
 func (cr *certificateRepository) GetByID(ctx context.Context, id string) (*model.Certificate, error) {
 
 	result := cr.db.QueryRowContext(
 		ctx,
-		"SELECT id, common_name, serial_number, issuer, not_before, not_after, fingerprint_sha256, created_at FROM certificates WHERE id = ?",
+		"SELECT "+certificateColumns+" FROM certificates c WHERE c.id = ?",
 		id)
 
-	var returnVal model.Certificate
-	err := result.Scan(&returnVal.Id,
-		&returnVal.CommonName,
-		&returnVal.SerialNumber,
-		&returnVal.Issuer,
-		&returnVal.NotBefore,
-		&returnVal.NotAfter,
-		&returnVal.FingerprintSHA256,
-		&returnVal.CreatedAt)
+	returnVal, err := scanCertificate(result)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -82,95 +339,918 @@ func (cr *certificateRepository) GetByID(ctx context.Context, id string) (*model
 	return &returnVal, nil
 }
 
-func (cr *certificateRepository) List(ctx context.Context) ([]model.Certificate, error) {
+func (cr *certificateRepository) GetByFingerprint(ctx context.Context, fingerprint string) (*model.Certificate, error) {
+
+	result := cr.db.QueryRowContext(
+		ctx,
+		"SELECT "+certificateColumns+" FROM certificates c WHERE c.fingerprint_sha256 = ?",
+		fingerprint)
+
+	returnVal, err := scanCertificate(result)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("Get cert by fingerprint: %w", err)
+	}
+
+	return &returnVal, nil
+}
+
+func (cr *certificateRepository) List(ctx context.Context, filter CertificateFilter, page Page) ([]model.Certificate, error) {
+
+	column, ok := sortColumns[page.Sort]
+	if !ok {
+		return nil, fmt.Errorf("Querying for certs: unknown sort field %q", page.Sort)
+	}
+
+	conditions, args := filterConditions(filter)
+
+	order := "ASC"
+	compare := ">"
+	if page.Desc {
+		order = "DESC"
+		compare = "<"
+	}
+	if page.After != nil {
+		value, err := keysetValue(page.Sort, page.After.Value)
+		if err != nil {
+			return nil, ErrInvalidKeyset
+		}
+		conditions = append(conditions, "("+column+" "+compare+" ? OR ("+column+" = ? AND c.id "+compare+" ?))")
+		args = append(args, value, value, page.After.Id)
+	}
+
+	query := "SELECT " + certificateColumns + " FROM certificates c"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + column + " " + order + ", c.id " + order + " LIMIT ?"
+	args = append(args, page.Limit)
+
+	result, err := cr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for certs: %w", err)
+	}
+	defer result.Close()
+
+	retValue, err := scanCertificates(result)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for certs: %w", err)
+	}
+	return retValue, nil
+}
 
+// ListExpiring returns the certificates expiring between after and before.
+func (cr *certificateRepository) ListExpiring(ctx context.Context, before time.Time, after time.Time) ([]model.Certificate, error) {
 	result, err := cr.db.QueryContext(
 		ctx,
-		"SELECT id, common_name, serial_number, issuer, not_before, not_after, fingerprint_sha256, created_at FROM certificates",
+		`SELECT `+certificateColumns+`
+		FROM certificates c
+		WHERE c.not_after < ? AND c.not_after > ?`,
+		before,
+		after,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("Querying for certs: %w", err)
+		return nil, fmt.Errorf("Querying for expiring certs: %w", err)
 	}
 	defer result.Close()
 
-	retValue := []model.Certificate{}
+	retValue, err := scanCertificates(result)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for expiring certs: %w", err)
+	}
+	return retValue, nil
+}
+
+// Update writes the editable fields of the certificate and replaces its
+// labels, provided the stored version still equals cert.Version. On success
+// cert.Version is the new version.
+func (cr *certificateRepository) Update(ctx context.Context, cert *model.Certificate) error {
+
+	tx, err := cr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Updating cert: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getCertificate(ctx, tx, cert.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("Updating cert: %w", err)
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		`UPDATE certificates
+		SET common_name = ?, serial_number = ?, issuer = ?, not_before = ?, not_after = ?,
+			owner_team = ?, contact_email = ?, description = ?, notes = ?, version = version + 1
+		WHERE id = ? AND version = ?`,
+		cert.CommonName,
+		cert.SerialNumber,
+		cert.Issuer,
+		cert.NotBefore,
+		cert.NotAfter,
+		cert.OwnerTeam,
+		cert.ContactEmail,
+		cert.Description,
+		cert.Notes,
+		cert.Id,
+		cert.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("Updating cert: %w", err)
+	}
+	rows, rowerr := result.RowsAffected()
+	if rowerr != nil {
+		return fmt.Errorf("Updating cert: %w", rowerr)
+	}
+	if rows == 0 {
+		return ErrStaleVersion
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM certificate_labels WHERE certificate_id = ?", cert.Id); err != nil {
+		return fmt.Errorf("Updating labels: %w", err)
+	}
+	if err := insertLabels(ctx, tx, cert.Id, cert.Labels); err != nil {
+		return fmt.Errorf("Updating labels: %w", err)
+	}
+	after, err := getCertificate(ctx, tx, cert.Id)
+	if err != nil {
+		return fmt.Errorf("Updating cert: %w", err)
+	}
+	if err := recordAudit(ctx, tx, model.AuditCertificateUpdate, model.AuditEntityCertificate, cert.Id, before, after); err != nil {
+		return fmt.Errorf("Updating cert: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Updating cert: %w", err)
+	}
+	cert.Version++
+
+	return nil
+}
+
+// Delete removes the certificate if it is still at version, so that what the
+// caller checked before deleting it cannot have changed in between; otherwise
+// it is ErrStaleVersion.
+func (cr *certificateRepository) Delete(ctx context.Context, id string, version int64) error {
+
+	tx, err := cr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Deleting cert: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getCertificate(ctx, tx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("Deleting cert: %w", err)
+	}
+	if before.Version != version {
+		return ErrStaleVersion
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM certificates WHERE id = ?", id); err != nil {
+		return fmt.Errorf("Deleting cert: %w", err)
+	}
+	if err := recordAudit(ctx, tx, model.AuditCertificateDelete, model.AuditEntityCertificate, id, before, nil); err != nil {
+		return fmt.Errorf("Deleting cert: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Deleting cert: %w", err)
+	}
+
+	return nil
+}
+
+// LinkByKeyId links the certificate to an already stored issuer whose subject
+// key id matches its authority key id, and adopts any stored certificates
+// that were issued by it but had no issuer linked yet.
+func (cr *certificateRepository) LinkByKeyId(ctx context.Context, id string) error {
+
+	tx, err := cr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Linking issuer: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := linkByKeyId(ctx, tx, id); err != nil {
+		return fmt.Errorf("Linking issuer: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Linking issuer: %w", err)
+	}
+	return nil
+}
+
+func linkByKeyId(ctx context.Context, tx *tracedTx, id string) error {
+
+	// The certificate and the ones it may adopt are snapshotted first, so
+	// that the links made are recorded in the audit trail.
+	result, err := tx.QueryContext(
+		ctx,
+		`SELECT id FROM certificates
+		WHERE issuer_id IS NULL AND authority_key_id != ''
+		AND (id = ? OR authority_key_id = (SELECT subject_key_id FROM certificates WHERE id = ?))`,
+		id,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	var ids []string
 	for result.Next() {
-		item := model.Certificate{}
-		err2 := result.Scan(
-			&item.Id,
-			&item.CommonName,
-			&item.SerialNumber,
-			&item.Issuer,
-			&item.NotBefore,
-			&item.NotAfter,
-			&item.FingerprintSHA256,
-			&item.CreatedAt)
-		if err2 != nil {
-			return nil, fmt.Errorf("Querying for certs: %w", err2)
+		var candidate string
+		if err := result.Scan(&candidate); err != nil {
+			result.Close()
+			return err
 		}
-		retValue = append(retValue, item)
+		ids = append(ids, candidate)
+	}
+	result.Close()
+	if err := result.Err(); err != nil {
+		return err
+	}
+	befores := make([]*model.Certificate, 0, len(ids))
+	for _, candidate := range ids {
+		before, err := getCertificate(ctx, tx, candidate)
+		if err != nil {
+			return err
+		}
+		befores = append(befores, before)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE certificates
+		SET issuer_id = (
+			SELECT p.id FROM certificates p
+			WHERE p.subject_key_id = certificates.authority_key_id AND p.id != certificates.id
+			ORDER BY p.not_after DESC
+			LIMIT 1)
+		WHERE id = ? AND issuer_id IS NULL AND authority_key_id != ''`,
+		id,
+	)
+	if err != nil {
+		return err
 	}
-	if er := result.Err(); er != nil {
-		return nil, fmt.Errorf("Querying for certs: %w", er)
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE certificates
+		SET issuer_id = ?
+		WHERE issuer_id IS NULL AND id != ? AND authority_key_id != ''
+		AND authority_key_id = (SELECT subject_key_id FROM certificates WHERE id = ?)`,
+		id,
+		id,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, before := range befores {
+		if err := recordUpdate(ctx, tx, before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordUpdate records the changes made to a stored certificate since before
+// was read as certificate.update, unless there are none. It is for changes
+// the server makes, such as linking issuers, that do not go through Update.
+func recordUpdate(ctx context.Context, tx *tracedTx, before *model.Certificate) error {
+	after, err := getCertificate(ctx, tx, before.Id)
+	if err != nil {
+		return err
+	}
+	if after.Version == before.Version && after.IssuerId == before.IssuerId {
+		return nil
+	}
+	return recordAudit(ctx, tx, model.AuditCertificateUpdate, model.AuditEntityCertificate, before.Id, before, after)
+}
+
+// GetChain returns the certificate followed by its issuers, walking up the
+// issuer links towards the root.
+func (cr *certificateRepository) GetChain(ctx context.Context, id string) ([]model.Certificate, error) {
+
+	result, err := cr.db.QueryContext(
+		ctx,
+		`WITH RECURSIVE chain(id, depth) AS (
+			SELECT id, 0 FROM certificates WHERE id = ?
+			UNION ALL
+			SELECT p.issuer_id, chain.depth + 1
+			FROM certificates p JOIN chain ON p.id = chain.id
+			WHERE p.issuer_id IS NOT NULL AND chain.depth < ?
+		)
+		SELECT `+certificateColumns+`
+		FROM chain JOIN certificates c ON c.id = chain.id
+		ORDER BY chain.depth`,
+		id,
+		maxChainDepth,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for chain: %w", err)
+	}
+	defer result.Close()
+
+	retValue, err := scanCertificates(result)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for chain: %w", err)
+	}
+	if len(retValue) == 0 {
+		return nil, ErrNotFound
 	}
 	return retValue, nil
 }
 
-func (cr *certificateRepository) ListExpiring(ctx context.Context, before time.Time, now time.Time) ([]model.Certificate, error) {
-	// fmt.Printf("before: %v. now: %v.\n", before, now)
+// ListIssuedBy returns every certificate below the given issuer, directly or
+// through intermediates.
+func (cr *certificateRepository) ListIssuedBy(ctx context.Context, issuerID string) ([]model.Certificate, error) {
+
 	result, err := cr.db.QueryContext(
 		ctx,
-		`SELECT id, common_name, serial_number, issuer, not_before, not_after, fingerprint_sha256, created_at 
-		FROM certificates
-		WHERE not_after < ? AND not_after > ?`,
-		before,
-		now,
+		`WITH RECURSIVE issued(id, depth) AS (
+			SELECT id, 1 FROM certificates WHERE issuer_id = ? AND id != ?
+			UNION
+			SELECT ch.id, issued.depth + 1
+			FROM certificates ch JOIN issued ON ch.issuer_id = issued.id
+			WHERE issued.depth < ?
+		)
+		SELECT `+certificateColumns+`
+		FROM certificates c
+		WHERE c.id IN (SELECT id FROM issued)
+		ORDER BY c.not_after`,
+		issuerID,
+		issuerID,
+		maxChainDepth,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("Querying for expiring certs: %w", err)
+		return nil, fmt.Errorf("Querying for issued certs: %w", err)
 	}
 	defer result.Close()
 
-	retValue := []model.Certificate{}
+	retValue, err := scanCertificates(result)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for issued certs: %w", err)
+	}
+	return retValue, nil
+}
+
+// KeysetOf returns the position of the certificate in a listing sorted by
+// the given field.
+func KeysetOf(cert model.Certificate, sort SortField) Keyset {
+	var value string
+	switch sort {
+	case SortNotAfter:
+		value = cert.NotAfter.UTC().Format(time.RFC3339Nano)
+	case SortNotBefore:
+		value = cert.NotBefore.UTC().Format(time.RFC3339Nano)
+	case SortCreatedAt:
+		value = cert.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case SortCommonName:
+		value = cert.CommonName
+	case SortIssuer:
+		value = cert.Issuer
+	}
+	return Keyset{Value: value, Id: cert.Id}
+}
+
+// keysetValue converts a keyset value back to the type it is stored as, so
+// that it compares the same way as the column.
+func keysetValue(sort SortField, value string) (any, error) {
+	switch sort {
+	case SortNotAfter, SortNotBefore, SortCreatedAt:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, err
+		}
+		return t.UTC(), nil
+	}
+	return value, nil
+}
+
+// filterConditions translates the filter into conditions on the certificates
+// table, aliased c, and their arguments.
+func filterConditions(filter CertificateFilter) ([]string, []any) {
+	conditions := []string{}
+	args := []any{}
+	if filter.OwnerTeam != "" {
+		conditions = append(conditions, "c.owner_team = ?")
+		args = append(args, filter.OwnerTeam)
+	}
+	if filter.Issuer != "" {
+		conditions = append(conditions, "c.issuer = ?")
+		args = append(args, filter.Issuer)
+	}
+	if filter.CommonNamePrefix != "" {
+		conditions = append(conditions, `c.common_name LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(filter.CommonNamePrefix)+"%")
+	}
+	if filter.DNSName != "" {
+		names := []any{filter.DNSName, wildcardFor(filter.DNSName)}
+		conditions = append(conditions, `(EXISTS (SELECT 1 FROM certificate_sans s WHERE s.certificate_id = c.id AND s.type = 'dns' AND s.value IN (?, ?))
+			OR lower(c.common_name) IN (?, ?))`)
+		args = append(args, names...)
+		args = append(args, names...)
+	}
+	if !filter.NotAfterFrom.IsZero() {
+		conditions = append(conditions, "c.not_after >= ?")
+		args = append(args, filter.NotAfterFrom)
+	}
+	if !filter.NotAfterTo.IsZero() {
+		conditions = append(conditions, "c.not_after < ?")
+		args = append(args, filter.NotAfterTo)
+	}
+	if filter.KeyAlgorithm != "" {
+		conditions = append(conditions, "c.key_algorithm = ? COLLATE NOCASE")
+		args = append(args, filter.KeyAlgorithm)
+	}
+	if filter.KeySize > 0 {
+		conditions = append(conditions, "c.key_size = ?")
+		args = append(args, filter.KeySize)
+	}
+	if filter.KeySizeBelow > 0 {
+		conditions = append(conditions, "c.key_size > 0 AND c.key_size < ?")
+		args = append(args, filter.KeySizeBelow)
+	}
+	if filter.SignatureAlgorithm != "" {
+		conditions = append(conditions, "c.signature_algorithm = ? COLLATE NOCASE")
+		args = append(args, filter.SignatureAlgorithm)
+	}
+	if filter.KeyUsage != "" {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM json_each(c.key_usage) u WHERE u.value = ?)")
+		args = append(args, filter.KeyUsage)
+	}
+	if filter.ExtKeyUsage != "" {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM json_each(c.ext_key_usage) u WHERE u.value = ?)")
+		args = append(args, filter.ExtKeyUsage)
+	}
+	if filter.IsCA != nil {
+		conditions = append(conditions, "c.is_ca = ?")
+		args = append(args, *filter.IsCA)
+	}
+	for _, req := range filter.Selector {
+		condition, reqArgs := selectorCondition(req)
+		conditions = append(conditions, condition)
+		args = append(args, reqArgs...)
+	}
+	return conditions, args
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// wildcardFor returns the wildcard name that covers the name, e.g.
+// *.example.com for api.example.com. A wildcard covers exactly one label.
+func wildcardFor(name string) string {
+	_, parent, found := strings.Cut(name, ".")
+	if !found || parent == "" {
+		return name
+	}
+	return "*." + parent
+}
+
+func insertSANs(ctx context.Context, tx *tracedTx, cert *model.Certificate) error {
+	groups := []struct {
+		kind   string
+		values []string
+	}{
+		{sanDNS, cert.DNSNames},
+		{sanIP, cert.IPAddresses},
+		{sanURI, cert.URIs},
+		{sanEmail, cert.EmailAddresses},
+	}
+	for _, group := range groups {
+		for _, value := range group.values {
+			_, err := tx.ExecContext(ctx,
+				"INSERT OR IGNORE INTO certificate_sans (certificate_id, type, value) VALUES(?,?,?)",
+				cert.Id, group.kind, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// splitSANs sorts the stored (type, value) pairs into the certificate.
+func splitSANs(item *model.Certificate, sansJSON sql.NullString) error {
+	item.DNSNames, item.IPAddresses, item.URIs, item.EmailAddresses = []string{}, []string{}, []string{}, []string{}
+	if !sansJSON.Valid {
+		return nil
+	}
+	pairs := [][2]string{}
+	if err := json.Unmarshal([]byte(sansJSON.String), &pairs); err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		switch pair[0] {
+		case sanDNS:
+			item.DNSNames = append(item.DNSNames, pair[1])
+		case sanIP:
+			item.IPAddresses = append(item.IPAddresses, pair[1])
+		case sanURI:
+			item.URIs = append(item.URIs, pair[1])
+		case sanEmail:
+			item.EmailAddresses = append(item.EmailAddresses, pair[1])
+		}
+	}
+	sort.Strings(item.DNSNames)
+	sort.Strings(item.IPAddresses)
+	sort.Strings(item.URIs)
+	sort.Strings(item.EmailAddresses)
+	return nil
+}
+
+func insertLabels(ctx context.Context, tx *tracedTx, id string, labels map[string]string) error {
+	for key, value := range labels {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO certificate_labels (certificate_id, key, value) VALUES(?,?,?)",
+			id, key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectorCondition translates one selector requirement into a condition on
+// the certificates table. Keys and values are always passed as parameters.
+func selectorCondition(req labels.Requirement) (string, []any) {
+	const match = "SELECT 1 FROM certificate_labels l WHERE l.certificate_id = c.id AND l.key = ?"
+	args := []any{req.Key}
+	valueMatch := ""
+	if len(req.Values) > 0 {
+		valueMatch = " AND l.value IN (?" + strings.Repeat(",?", len(req.Values)-1) + ")"
+		for _, value := range req.Values {
+			args = append(args, value)
+		}
+	}
+
+	switch req.Operator {
+	case labels.NotEquals, labels.NotIn, labels.DoesNotExist:
+		return "NOT EXISTS (" + match + valueMatch + ")", args
+	default:
+		return "EXISTS (" + match + valueMatch + ")", args
+	}
+}
+
+// Search runs an FTS5 match expression against the search index and returns
+// the best matches first. Common name and SANs weigh the most.
+func (cr *certificateRepository) Search(ctx context.Context, match string, limit int) ([]model.SearchResult, error) {
+
+	result, err := cr.db.QueryContext(
+		ctx,
+		`SELECT `+certificateColumns+`,
+			bm25(certificate_search, 10.0, 2.0, 10.0, 5.0, 1.0, 1.0) AS rank,
+			snippet(certificate_search, -1, '«', '»', '…', 12)
+		FROM certificate_search
+		JOIN certificates c ON c.id = certificate_search.certificate_id
+		WHERE certificate_search MATCH ?
+		ORDER BY rank
+		LIMIT ?`,
+		match,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Searching certs: %w", err)
+	}
+	defer result.Close()
+
+	retValue := []model.SearchResult{}
 	for result.Next() {
-		item := model.Certificate{}
-		err2 := result.Scan(
-			&item.Id,
-			&item.CommonName,
-			&item.SerialNumber,
-			&item.Issuer,
-			&item.NotBefore,
-			&item.NotAfter,
-			&item.FingerprintSHA256,
-			&item.CreatedAt)
-		if err2 != nil {
-			return nil, fmt.Errorf("Querying for expiring certs: %w", err2)
+		item := model.SearchResult{}
+		cert, err := scanCertificate(result, &item.Rank, &item.Snippet)
+		if err != nil {
+			return nil, fmt.Errorf("Searching certs: %w", err)
 		}
+		item.Certificate = cert
 		retValue = append(retValue, item)
 	}
-	if er := result.Err(); er != nil {
-		return nil, fmt.Errorf("Querying for expiring certs: %w", er)
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("Searching certs: %w", err)
 	}
 	return retValue, nil
 }
 
-func (cr *certificateRepository) Delete(ctx context.Context, id string) error {
+// Summarize counts the certificates matching the filter by public key
+// algorithm and size, and by signature algorithm.
+func (cr *certificateRepository) Summarize(ctx context.Context, filter CertificateFilter) (*model.CryptoSummary, error) {
+
+	conditions, args := filterConditions(filter)
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// Both counts are read in one transaction so that they agree.
+	tx, err := cr.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("Summarizing certs: %w", err)
+	}
+	defer tx.Rollback()
+
+	summary := &model.CryptoSummary{Keys: []model.KeySummary{}, SignatureAlgorithms: []model.SignatureSummary{}}
+	keys, err := tx.QueryContext(ctx,
+		`SELECT c.key_algorithm, c.key_size, c.key_curve, COUNT(*) FROM certificates c`+where+`
+		GROUP BY c.key_algorithm, c.key_size, c.key_curve
+		ORDER BY COUNT(*) DESC, c.key_algorithm, c.key_size`,
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("Summarizing certs: %w", err)
+	}
+	defer keys.Close()
+	for keys.Next() {
+		item := model.KeySummary{}
+		if err := keys.Scan(&item.KeyAlgorithm, &item.KeySize, &item.KeyCurve, &item.Count); err != nil {
+			return nil, fmt.Errorf("Summarizing certs: %w", err)
+		}
+		summary.Keys = append(summary.Keys, item)
+		summary.Total += item.Count
+	}
+	if err := keys.Err(); err != nil {
+		return nil, fmt.Errorf("Summarizing certs: %w", err)
+	}
+
+	signatures, err := tx.QueryContext(ctx,
+		`SELECT c.signature_algorithm, COUNT(*) FROM certificates c`+where+`
+		GROUP BY c.signature_algorithm
+		ORDER BY COUNT(*) DESC, c.signature_algorithm`,
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("Summarizing certs: %w", err)
+	}
+	defer signatures.Close()
+	for signatures.Next() {
+		item := model.SignatureSummary{}
+		if err := signatures.Scan(&item.SignatureAlgorithm, &item.Count); err != nil {
+			return nil, fmt.Errorf("Summarizing certs: %w", err)
+		}
+		summary.SignatureAlgorithms = append(summary.SignatureAlgorithms, item)
+	}
+	if err := signatures.Err(); err != nil {
+		return nil, fmt.Errorf("Summarizing certs: %w", err)
+	}
+
+	return summary, nil
+}
+
+// SummarizeExpiry counts the certificates expired by now, those expiring
+// within each window from now, and the certificates of each issuer.
+func (cr *certificateRepository) SummarizeExpiry(ctx context.Context, now time.Time, windows []time.Duration) (*model.ExpirySummary, error) {
+
+	// The counts are read in one transaction so that they agree.
+	tx, err := cr.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("Summarizing expiry: %w", err)
+	}
+	defer tx.Rollback()
 
-	result, err := cr.db.ExecContext(
+	summary := &model.ExpirySummary{ExpiringWithin: map[time.Duration]int{}, Issuers: []model.IssuerSummary{}}
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM certificates c WHERE c.not_after <= ?`, now).Scan(&summary.Expired)
+	if err != nil {
+		return nil, fmt.Errorf("Summarizing expiry: %w", err)
+	}
+	for _, window := range windows {
+		var count int
+		err = tx.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM certificates c WHERE c.not_after > ? AND c.not_after <= ?`,
+			now, now.Add(window)).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("Summarizing expiry: %w", err)
+		}
+		summary.ExpiringWithin[window] = count
+	}
+
+	// Selecting the column rather than MIN() keeps its type for the driver.
+	err = tx.QueryRowContext(ctx,
+		`SELECT c.not_after FROM certificates c WHERE c.not_after > ? ORDER BY c.not_after LIMIT 1`,
+		now).Scan(&summary.SoonestExpiry)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("Summarizing expiry: %w", err)
+	}
+
+	issuers, err := tx.QueryContext(ctx,
+		`SELECT c.issuer, COUNT(*) FROM certificates c
+		GROUP BY c.issuer
+		ORDER BY c.issuer`)
+	if err != nil {
+		return nil, fmt.Errorf("Summarizing expiry: %w", err)
+	}
+	defer issuers.Close()
+	for issuers.Next() {
+		item := model.IssuerSummary{}
+		if err := issuers.Scan(&item.Issuer, &item.Count); err != nil {
+			return nil, fmt.Errorf("Summarizing expiry: %w", err)
+		}
+		summary.Issuers = append(summary.Issuers, item)
+	}
+	if err := issuers.Err(); err != nil {
+		return nil, fmt.Errorf("Summarizing expiry: %w", err)
+	}
+
+	return summary, nil
+}
+
+// fillKeyDetails stores the key details of a certificate that was registered
+// before they were recorded. Details that are already stored are kept. The
+// version is bumped, so that an update read before cannot overwrite them.
+func fillKeyDetails(ctx context.Context, tx *tracedTx, cert *model.Certificate) error {
+
+	keyUsage, extKeyUsage, err := usagesJSON(cert)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(
 		ctx,
-		"DELETE FROM certificates WHERE id = ?",
-		id,
+		`UPDATE certificates
+		SET key_algorithm = ?, key_size = ?, key_curve = ?, signature_algorithm = ?, key_usage = ?, ext_key_usage = ?, is_ca = ?, self_signed = ?,
+			version = version + 1
+		WHERE id = ? AND key_algorithm = ''`,
+		cert.KeyAlgorithm,
+		cert.KeySize,
+		cert.KeyCurve,
+		cert.SignatureAlgorithm,
+		keyUsage,
+		extKeyUsage,
+		cert.IsCA,
+		cert.SelfSigned,
+		cert.Id,
+	)
+	return err
+}
+
+// fillPEM stores the PEM of a certificate that was stored without it,
+// together with the fields and SANs read from it, which take precedence over
+// those entered by hand or missing from before SANs were recorded. The
+// fingerprint proves that it is the same certificate. The version is bumped,
+// so that an update read before cannot write the old fields back.
+func fillPEM(ctx context.Context, tx *tracedTx, cert *model.Certificate) error {
+
+	_, err := tx.ExecContext(
+		ctx,
+		`UPDATE certificates
+		SET pem = ?, common_name = ?, serial_number = ?, issuer = ?, not_before = ?, not_after = ?, version = version + 1
+		WHERE id = ? AND pem = ''`,
+		cert.PEM,
+		cert.CommonName,
+		cert.SerialNumber,
+		cert.Issuer,
+		cert.NotBefore,
+		cert.NotAfter,
+		cert.Id,
 	)
 	if err != nil {
-		return fmt.Errorf("Deleting cert: %w", err)
+		return err
 	}
-	rows, rowerr := result.RowsAffected()
-	if rowerr != nil {
-		return fmt.Errorf("Deleting cert: %w", rowerr)
+	if _, err := tx.ExecContext(ctx, "DELETE FROM certificate_sans WHERE certificate_id = ?", cert.Id); err != nil {
+		return err
 	}
-	if rows == 0 {
-		return ErrNotFound
+	return insertSANs(ctx, tx, cert)
+}
+
+func copyPEM(dst *model.Certificate, src *model.Certificate) {
+	dst.PEM = src.PEM
+	dst.CommonName = src.CommonName
+	dst.SerialNumber = src.SerialNumber
+	dst.Issuer = src.Issuer
+	dst.NotBefore = src.NotBefore
+	dst.NotAfter = src.NotAfter
+	dst.DNSNames = src.DNSNames
+	dst.IPAddresses = src.IPAddresses
+	dst.URIs = src.URIs
+	dst.EmailAddresses = src.EmailAddresses
+}
+
+func copyKeyDetails(dst *model.Certificate, src *model.Certificate) {
+	dst.KeyAlgorithm = src.KeyAlgorithm
+	dst.KeySize = src.KeySize
+	dst.KeyCurve = src.KeyCurve
+	dst.SignatureAlgorithm = src.SignatureAlgorithm
+	dst.KeyUsage = src.KeyUsage
+	dst.ExtKeyUsage = src.ExtKeyUsage
+	dst.IsCA = src.IsCA
+	dst.SelfSigned = src.SelfSigned
+}
+
+func usagesJSON(cert *model.Certificate) (string, string, error) {
+	keyUsage, err := json.Marshal(orEmpty(cert.KeyUsage))
+	if err != nil {
+		return "", "", err
+	}
+	extKeyUsage, err := json.Marshal(orEmpty(cert.ExtKeyUsage))
+	if err != nil {
+		return "", "", err
 	}
+	return string(keyUsage), string(extKeyUsage), nil
+}
 
-	return nil
+func orEmpty(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// getCertificate reads a certificate within tx, e.g. to snapshot it for the
+// audit trail.
+func getCertificate(ctx context.Context, tx *tracedTx, id string) (*model.Certificate, error) {
+	row := tx.QueryRowContext(ctx, "SELECT "+certificateColumns+" FROM certificates c WHERE c.id = ?", id)
+	cert, err := scanCertificate(row)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// getCertificateByFingerprint reads a certificate by its fingerprint within
+// tx.
+func getCertificateByFingerprint(ctx context.Context, tx *tracedTx, fingerprint string) (*model.Certificate, error) {
+	row := tx.QueryRowContext(ctx, "SELECT "+certificateColumns+" FROM certificates c WHERE c.fingerprint_sha256 = ?", fingerprint)
+	cert, err := scanCertificate(row)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// scanCertificate scans the certificateColumns, followed by any extra
+// columns the query selects.
+func scanCertificate(row rowScanner, extra ...any) (model.Certificate, error) {
+	item := model.Certificate{}
+	var issuerID sql.NullString
+	var labelsJSON sql.NullString
+	var sansJSON sql.NullString
+	var keyUsageJSON, extKeyUsageJSON string
+	dest := []any{
+		&item.Id,
+		&item.CommonName,
+		&item.SerialNumber,
+		&item.Issuer,
+		&item.NotBefore,
+		&item.NotAfter,
+		&item.FingerprintSHA256,
+		&item.CreatedAt,
+		&item.SubjectKeyId,
+		&item.AuthorityKeyId,
+		&issuerID,
+		&item.OwnerTeam,
+		&item.ContactEmail,
+		&item.Description,
+		&item.Notes,
+		&item.Version,
+		&item.KeyAlgorithm,
+		&item.KeySize,
+		&item.KeyCurve,
+		&item.SignatureAlgorithm,
+		&keyUsageJSON,
+		&extKeyUsageJSON,
+		&item.IsCA,
+		&item.SelfSigned,
+		&item.PEM,
+		&labelsJSON,
+		&sansJSON,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return item, err
+	}
+	item.IssuerId = issuerID.String
+	item.Labels = map[string]string{}
+	if labelsJSON.Valid {
+		if err := json.Unmarshal([]byte(labelsJSON.String), &item.Labels); err != nil {
+			return item, err
+		}
+	}
+	if err := splitSANs(&item, sansJSON); err != nil {
+		return item, err
+	}
+	if err := json.Unmarshal([]byte(keyUsageJSON), &item.KeyUsage); err != nil {
+		return item, err
+	}
+	if err := json.Unmarshal([]byte(extKeyUsageJSON), &item.ExtKeyUsage); err != nil {
+		return item, err
+	}
+	return item, nil
+}
+
+func scanCertificates(rows *sql.Rows) ([]model.Certificate, error) {
+	retValue := []model.Certificate{}
+	for rows.Next() {
+		item, err := scanCertificate(rows)
+		if err != nil {
+			return nil, err
+		}
+		retValue = append(retValue, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return retValue, nil
 }
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/db"
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+// newTestDB returns a migrated database in a temporary directory.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	ctx := context.Background()
+	sqlDB, err := db.NewSQLite(ctx, t.TempDir()+"/certwatch.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.RunMigrations(ctx, sqlDB); err != nil {
+		t.Fatal(err)
+	}
+	return sqlDB
+}
+
+func TestIssuerPageUsesIndex(t *testing.T) {
+	sqlDB := newTestDB(t)
+
+	rows, err := sqlDB.Query(`EXPLAIN QUERY PLAN SELECT c.id FROM certificates c
+		WHERE (c.issuer > ? OR (c.issuer = ? AND c.id > ?))
+		ORDER BY c.issuer ASC, c.id ASC LIMIT ?`, "CA", "CA", "id", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	plan := []string{}
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			t.Fatal(err)
+		}
+		plan = append(plan, detail)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(plan, "\n"), "idx_cert_issuer_name_id") {
+		t.Errorf("issuer page plan = %q; want it to use idx_cert_issuer_name_id", plan)
+	}
+}
+
+// testCertificate returns a certificate to store whose fingerprint is derived
+// from n.
+func testCertificate(id string, n int) *model.Certificate {
+	now := time.Now().UTC()
+	return &model.Certificate{
+		Id:                id,
+		CommonName:        id + ".example.com",
+		SerialNumber:      fmt.Sprint(n),
+		Issuer:            "Test CA",
+		NotBefore:         now.Add(-time.Hour),
+		NotAfter:          now.Add(time.Hour),
+		FingerprintSHA256: fmt.Sprintf("%064x", n),
+		CreatedAt:         now,
+		Version:           1,
+	}
+}
+
+func TestImportChain(t *testing.T) {
+	ctx := context.Background()
+	repo := NewCertificateRepository(newTestDB(t))
+	root := testCertificate("root", 1)
+	if err := repo.Create(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+
+	rootAgain := testCertificate("root-again", 1)
+	rootAgain.SerialNumber = "0a"
+	rootAgain.DNSNames = []string{"root.example.com"}
+	rootAgain.PEM = "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"
+	members := []ChainMember{
+		{Certificate: testCertificate("leaf", 2), Issuer: 1},
+		{Certificate: rootAgain, Issuer: -1, Version: 1},
+	}
+	stale := []ChainMember{{Certificate: testCertificate("root-stale", 1), Issuer: -1}}
+	if err := repo.ImportChain(ctx, stale); !errors.Is(err, ErrStaleVersion) {
+		t.Errorf("ImportChain() of a member stored since it was checked = %v; want %v", err, ErrStaleVersion)
+	}
+	if err := repo.ImportChain(ctx, members); err != nil {
+		t.Fatal(err)
+	}
+	if !members[0].Created || members[1].Created || members[1].Certificate.Id != "root" {
+		t.Errorf("ImportChain() created %v, %v as %s; want the leaf created and the root kept", members[0].Created, members[1].Created, members[1].Certificate.Id)
+	}
+	leaf, err := repo.GetByID(ctx, "leaf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.IssuerId != "root" {
+		t.Errorf("leaf IssuerId = %q; want root", leaf.IssuerId)
+	}
+	stored, err := repo.GetByID(ctx, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.PEM != rootAgain.PEM || stored.CommonName != rootAgain.CommonName || stored.SerialNumber != "0a" || len(stored.DNSNames) != 1 {
+		t.Errorf("root stored without PEM = %q, %q, %q, %v; want the PEM, its common name, serial and SANs filled in", stored.PEM, stored.CommonName, stored.SerialNumber, stored.DNSNames)
+	}
+	if stored.Version != 2 || members[1].Certificate.Version != 2 {
+		t.Errorf("root version = %d, %d after the PEM was filled in; want 2", stored.Version, members[1].Certificate.Version)
+	}
+	handTyped := testCertificate("root", 1)
+	if err := repo.Update(ctx, handTyped); !errors.Is(err, ErrStaleVersion) {
+		t.Errorf("Update() read before the import = %v; want %v", err, ErrStaleVersion)
+	}
+}
+
+func TestImportChainAuditsStoredChanges(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := newTestDB(t)
+	repo := NewCertificateRepository(sqlDB)
+	if err := repo.Create(ctx, testCertificate("root", 1)); err != nil {
+		t.Fatal(err)
+	}
+	orphan := testCertificate("orphan", 2)
+	orphan.AuthorityKeyId = "aa"
+	if err := repo.Create(ctx, orphan); err != nil {
+		t.Fatal(err)
+	}
+
+	rootAgain := testCertificate("root-again", 1)
+	rootAgain.PEM = "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"
+	issuer := testCertificate("issuer", 3)
+	issuer.SubjectKeyId = "aa"
+	members := []ChainMember{
+		{Certificate: issuer, Issuer: 1},
+		{Certificate: rootAgain, Issuer: -1, Version: 1},
+	}
+	if err := repo.ImportChain(ctx, members); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := NewAuditRepository(sqlDB).List(ctx, AuditFilter{Action: model.AuditCertificateUpdate}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := map[string]model.AuditEvent{}
+	for _, event := range events {
+		updated[event.EntityId] = event
+	}
+	if len(events) != 2 {
+		t.Fatalf("recorded %d updates; want the root's PEM and the orphan's adoption", len(events))
+	}
+	root, ok := updated["root"]
+	if !ok || !strings.Contains(string(root.After), "BEGIN CERTIFICATE") || strings.Contains(string(root.Before), "BEGIN CERTIFICATE") {
+		t.Errorf("root update = %s -> %s; want the PEM filled in", root.Before, root.After)
+	}
+	adopted, ok := updated["orphan"]
+	if !ok || !strings.Contains(string(adopted.After), `"IssuerId":"issuer"`) {
+		t.Errorf("orphan update = %s; want it linked to the issuer", adopted.After)
+	}
+}
+
+func TestImportChainIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	repo := NewCertificateRepository(newTestDB(t))
+
+	invalid := testCertificate("invalid", 4)
+	invalid.SerialNumber = strings.Repeat("f", 129)
+	members := []ChainMember{
+		{Certificate: testCertificate("leaf", 3), Issuer: 1},
+		{Certificate: invalid, Issuer: -1},
+	}
+	if err := repo.ImportChain(ctx, members); err == nil {
+		t.Fatal("ImportChain() error = nil; want the invalid member to fail it")
+	}
+	if _, err := repo.GetByID(ctx, "leaf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetByID(leaf) error = %v; want %v, nothing of the chain stored", err, ErrNotFound)
+	}
+}
+
+func TestDeleteChecksVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := NewCertificateRepository(newTestDB(t))
+	if err := repo.Create(ctx, testCertificate("cert", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Delete(ctx, "cert", 2); !errors.Is(err, ErrStaleVersion) {
+		t.Errorf("Delete() at another version = %v; want %v", err, ErrStaleVersion)
+	}
+	if err := repo.Delete(ctx, "cert", 1); err != nil {
+		t.Errorf("Delete() at the stored version = %v; want nil", err)
+	}
+	if err := repo.Delete(ctx, "cert", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() of a deleted certificate = %v; want %v", err, ErrNotFound)
+	}
+}
+
+func TestSearchSurvivesRenumberedRows(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := newTestDB(t)
+	repo := NewCertificateRepository(sqlDB)
+	for i, id := range []string{"alpha", "bravo", "charlie"} {
+		if err := repo.Create(ctx, testCertificate(id, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Delete(ctx, "alpha", 1); err != nil {
+		t.Fatal(err)
+	}
+	// VACUUM may renumber the rowids of certificates, as this does.
+	if _, err := sqlDB.ExecContext(ctx, "UPDATE certificates SET rowid = rowid + 100"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"bravo", "charlie"} {
+		results, err := repo.Search(ctx, id, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Certificate.Id != id {
+			t.Errorf("Search(%q) = %+v; want only %s", id, results, id)
+		}
+	}
+}
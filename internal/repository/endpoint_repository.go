@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/hytonhan/certwatch/internal/model"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const endpointColumns = `id, host, port, server_name, created_at, last_scanned_at, handshake_failed, last_error, last_fingerprint, owner_team`
+
+type EndpointRepository interface {
+	Create(ctx context.Context, endpoint *model.Endpoint) error
+	List(ctx context.Context) ([]model.Endpoint, error)
+	Get(ctx context.Context, id string) (*model.Endpoint, error)
+	Delete(ctx context.Context, id string, ownerTeam string) error
+	RecordScan(ctx context.Context, id string, result model.ScanResult) error
+}
+
+type endpointRepository struct {
+	db *tracedDB
+}
+
+func NewEndpointRepository(db *sql.DB) *endpointRepository {
+	return &endpointRepository{db: &tracedDB{db: db}}
+}
+
+func (er *endpointRepository) Create(ctx context.Context, endpoint *model.Endpoint) error {
+
+	tx, err := er.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Creating endpoint: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO scan_endpoints (id, host, port, server_name, owner_team, created_at) VALUES(?,?,?,?,?,?)",
+		endpoint.Id,
+		endpoint.Host,
+		endpoint.Port,
+		endpoint.ServerName,
+		endpoint.OwnerTeam,
+		endpoint.CreatedAt)
+	if err != nil {
+		var sqlErr *sqlite.Error
+		if errors.As(err, &sqlErr) {
+			if sqlErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+				return ErrConflict
+			}
+		}
+		return fmt.Errorf("Creating endpoint: %w", err)
+	}
+	if err := recordAudit(ctx, tx, model.AuditEndpointCreate, model.AuditEntityEndpoint, endpoint.Id, nil, endpoint); err != nil {
+		return fmt.Errorf("Creating endpoint: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Creating endpoint: %w", err)
+	}
+	return nil
+}
+
+func (er *endpointRepository) List(ctx context.Context) ([]model.Endpoint, error) {
+
+	result, err := er.db.QueryContext(
+		ctx,
+		"SELECT "+endpointColumns+" FROM scan_endpoints ORDER BY host, port",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for endpoints: %w", err)
+	}
+	defer result.Close()
+
+	retValue := []model.Endpoint{}
+	for result.Next() {
+		item, err2 := scanEndpoint(result)
+		if err2 != nil {
+			return nil, fmt.Errorf("Querying for endpoints: %w", err2)
+		}
+		retValue = append(retValue, item)
+	}
+	if er := result.Err(); er != nil {
+		return nil, fmt.Errorf("Querying for endpoints: %w", er)
+	}
+	return retValue, nil
+}
+
+func (er *endpointRepository) Get(ctx context.Context, id string) (*model.Endpoint, error) {
+
+	row := er.db.QueryRowContext(ctx, "SELECT "+endpointColumns+" FROM scan_endpoints WHERE id = ?", id)
+	item, err := scanEndpoint(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("Getting endpoint: %w", err)
+	}
+	return &item, nil
+}
+
+// Delete removes the endpoint if it is still owned by ownerTeam, the owner the
+// caller was authorized against, and returns ErrStaleVersion otherwise.
+func (er *endpointRepository) Delete(ctx context.Context, id string, ownerTeam string) error {
+
+	tx, err := er.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Deleting endpoint: %w", err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, "SELECT "+endpointColumns+" FROM scan_endpoints WHERE id = ?", id)
+	before, err := scanEndpoint(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("Deleting endpoint: %w", err)
+	}
+	if before.OwnerTeam != ownerTeam {
+		return ErrStaleVersion
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM scan_endpoints WHERE id = ?", id); err != nil {
+		return fmt.Errorf("Deleting endpoint: %w", err)
+	}
+	if err := recordAudit(ctx, tx, model.AuditEndpointDelete, model.AuditEntityEndpoint, id, before, nil); err != nil {
+		return fmt.Errorf("Deleting endpoint: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Deleting endpoint: %w", err)
+	}
+
+	return nil
+}
+
+// RecordScan stores the outcome of a scan. A failed scan keeps the last
+// fingerprint that was successfully served.
+func (er *endpointRepository) RecordScan(ctx context.Context, id string, scan model.ScanResult) error {
+
+	result, err := er.db.ExecContext(
+		ctx,
+		`UPDATE scan_endpoints
+		SET last_scanned_at = ?, handshake_failed = ?, last_error = ?,
+			last_fingerprint = CASE WHEN ? = '' THEN last_fingerprint ELSE ? END
+		WHERE id = ?`,
+		scan.ScannedAt,
+		scan.HandshakeFailed,
+		scan.Error,
+		scan.Fingerprint,
+		scan.Fingerprint,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("Recording scan: %w", err)
+	}
+	rows, rowerr := result.RowsAffected()
+	if rowerr != nil {
+		return fmt.Errorf("Recording scan: %w", rowerr)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func scanEndpoint(row rowScanner) (model.Endpoint, error) {
+	item := model.Endpoint{}
+	var scannedAt sql.NullTime
+	err := row.Scan(
+		&item.Id,
+		&item.Host,
+		&item.Port,
+		&item.ServerName,
+		&item.CreatedAt,
+		&scannedAt,
+		&item.HandshakeFailed,
+		&item.LastError,
+		&item.LastFingerprint,
+		&item.OwnerTeam)
+	if err != nil {
+		return item, err
+	}
+	if scannedAt.Valid {
+		item.LastScannedAt = &scannedAt.Time
+	}
+	return item, nil
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const findingColumns = `f.certificate_id, c.common_name, f.rule_id, f.severity, f.message, f.first_seen_at, f.last_seen_at`
+
+// severityRank orders findings from the most to the least severe.
+const severityRank = `CASE f.severity WHEN 'critical' THEN 4 WHEN 'high' THEN 3 WHEN 'warning' THEN 2 ELSE 1 END`
+
+type FindingRepository interface {
+	Replace(ctx context.Context, certificateID string, findings []model.Finding, at time.Time) error
+	ListByCertificate(ctx context.Context, certificateID string) ([]model.Finding, error)
+	List(ctx context.Context, filter FindingFilter, limit int) ([]model.Finding, error)
+	Count(ctx context.Context, filter FindingFilter) ([]model.FindingCount, error)
+}
+
+// FindingFilter narrows List and Count. Zero values do not filter.
+type FindingFilter struct {
+	RuleId   string
+	Severity model.Severity
+}
+
+type findingRepository struct {
+	db *tracedDB
+}
+
+func NewFindingRepository(db *sql.DB) *findingRepository {
+	return &findingRepository{db: &tracedDB{db: db}}
+}
+
+// Replace makes the findings the current findings of the certificate.
+// Findings that persist keep their first_seen_at, findings that are gone are
+// removed. ErrNotFound means the certificate no longer exists.
+func (fr *findingRepository) Replace(ctx context.Context, certificateID string, findings []model.Finding, at time.Time) error {
+
+	tx, err := fr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Replacing findings: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := "DELETE FROM certificate_findings WHERE certificate_id = ?"
+	args := []any{certificateID}
+	if len(findings) > 0 {
+		query += " AND rule_id NOT IN (?" + strings.Repeat(",?", len(findings)-1) + ")"
+		for _, finding := range findings {
+			args = append(args, finding.RuleId)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("Replacing findings: %w", err)
+	}
+
+	for _, finding := range findings {
+		_, err := tx.ExecContext(ctx, `INSERT INTO certificate_findings (certificate_id, rule_id, severity, message, first_seen_at, last_seen_at)
+			VALUES(?,?,?,?,?,?)
+			ON CONFLICT(certificate_id, rule_id) DO UPDATE
+			SET severity = excluded.severity, message = excluded.message, last_seen_at = excluded.last_seen_at`,
+			certificateID,
+			finding.RuleId,
+			finding.Severity,
+			finding.Message,
+			at,
+			at)
+		if err != nil {
+			var sqlErr *sqlite.Error
+			if errors.As(err, &sqlErr) && sqlErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY {
+				return ErrNotFound
+			}
+			return fmt.Errorf("Replacing findings: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Replacing findings: %w", err)
+	}
+	return nil
+}
+
+func (fr *findingRepository) ListByCertificate(ctx context.Context, certificateID string) ([]model.Finding, error) {
+
+	result, err := fr.db.QueryContext(
+		ctx,
+		`SELECT `+findingColumns+`
+		FROM certificate_findings f JOIN certificates c ON c.id = f.certificate_id
+		WHERE f.certificate_id = ?
+		ORDER BY `+severityRank+` DESC, f.rule_id`,
+		certificateID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for findings: %w", err)
+	}
+	defer result.Close()
+
+	retValue, err := scanFindings(result)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for findings: %w", err)
+	}
+	return retValue, nil
+}
+
+// List returns the findings of every certificate, the most severe first.
+func (fr *findingRepository) List(ctx context.Context, filter FindingFilter, limit int) ([]model.Finding, error) {
+
+	where, args := findingConditions(filter)
+	result, err := fr.db.QueryContext(
+		ctx,
+		`SELECT `+findingColumns+`
+		FROM certificate_findings f JOIN certificates c ON c.id = f.certificate_id`+where+`
+		ORDER BY `+severityRank+` DESC, f.rule_id, c.common_name, f.certificate_id
+		LIMIT ?`,
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for findings: %w", err)
+	}
+	defer result.Close()
+
+	retValue, err := scanFindings(result)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for findings: %w", err)
+	}
+	return retValue, nil
+}
+
+// Count returns how many certificates violate each rule.
+func (fr *findingRepository) Count(ctx context.Context, filter FindingFilter) ([]model.FindingCount, error) {
+
+	where, args := findingConditions(filter)
+	result, err := fr.db.QueryContext(
+		ctx,
+		`SELECT f.rule_id, f.severity, COUNT(*)
+		FROM certificate_findings f`+where+`
+		GROUP BY f.rule_id, f.severity
+		ORDER BY `+severityRank+` DESC, COUNT(*) DESC, f.rule_id`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Counting findings: %w", err)
+	}
+	defer result.Close()
+
+	retValue := []model.FindingCount{}
+	for result.Next() {
+		item := model.FindingCount{}
+		if err := result.Scan(&item.RuleId, &item.Severity, &item.Count); err != nil {
+			return nil, fmt.Errorf("Counting findings: %w", err)
+		}
+		retValue = append(retValue, item)
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("Counting findings: %w", err)
+	}
+	return retValue, nil
+}
+
+func findingConditions(filter FindingFilter) (string, []any) {
+	conditions := []string{}
+	args := []any{}
+	if filter.RuleId != "" {
+		conditions = append(conditions, "f.rule_id = ?")
+		args = append(args, filter.RuleId)
+	}
+	if filter.Severity != "" {
+		conditions = append(conditions, "f.severity = ?")
+		args = append(args, filter.Severity)
+	}
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func scanFindings(rows *sql.Rows) ([]model.Finding, error) {
+	retValue := []model.Finding{}
+	for rows.Next() {
+		item := model.Finding{}
+		err := rows.Scan(
+			&item.CertificateId,
+			&item.CommonName,
+			&item.RuleId,
+			&item.Severity,
+			&item.Message,
+			&item.FirstSeenAt,
+			&item.LastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+		retValue = append(retValue, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return retValue, nil
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+const deliveryColumns = `id, certificate_id, channel, target, threshold, attempt, success, status_code, error, created_at`
+
+// maxDeliveries bounds how many delivery attempts a single list returns.
+const maxDeliveries = 500
+
+type NotificationRepository interface {
+	CreateDelivery(ctx context.Context, delivery *model.NotificationDelivery) error
+	ListDeliveries(ctx context.Context, success *bool) ([]model.NotificationDelivery, error)
+	EnqueueDigest(ctx context.Context, items []model.DigestItem, limit int) error
+	ClaimDigest(ctx context.Context, claim string, at time.Time, staleBefore time.Time) ([]model.DigestItem, error)
+	RemoveDigest(ctx context.Context, ids []string) error
+	ReleaseDigest(ctx context.Context, ids []string) error
+}
+
+type notificationRepository struct {
+	db *tracedDB
+}
+
+func NewNotificationRepository(db *sql.DB) *notificationRepository {
+	return &notificationRepository{db: &tracedDB{db: db}}
+}
+
+func (nr *notificationRepository) CreateDelivery(ctx context.Context, delivery *model.NotificationDelivery) error {
+
+	_, err := nr.db.ExecContext(ctx, "INSERT INTO notification_deliveries ("+deliveryColumns+") VALUES(?,?,?,?,?,?,?,?,?,?)",
+		delivery.Id,
+		sql.NullString{String: delivery.CertificateId, Valid: delivery.CertificateId != ""},
+		delivery.Channel,
+		delivery.Target,
+		delivery.Threshold,
+		delivery.Attempt,
+		delivery.Success,
+		delivery.StatusCode,
+		delivery.Error,
+		delivery.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("Creating delivery: %w", err)
+	}
+	return nil
+}
+
+func (nr *notificationRepository) ListDeliveries(ctx context.Context, success *bool) ([]model.NotificationDelivery, error) {
+
+	query := "SELECT " + deliveryColumns + " FROM notification_deliveries"
+	args := []any{}
+	if success != nil {
+		query += " WHERE success = ?"
+		args = append(args, *success)
+	}
+	query += " ORDER BY created_at DESC LIMIT ?"
+	args = append(args, maxDeliveries)
+
+	result, err := nr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Querying for deliveries: %w", err)
+	}
+	defer result.Close()
+
+	retValue := []model.NotificationDelivery{}
+	for result.Next() {
+		item := model.NotificationDelivery{}
+		var certificateID sql.NullString
+		err2 := result.Scan(
+			&item.Id,
+			&certificateID,
+			&item.Channel,
+			&item.Target,
+			&item.Threshold,
+			&item.Attempt,
+			&item.Success,
+			&item.StatusCode,
+			&item.Error,
+			&item.CreatedAt)
+		if err2 != nil {
+			return nil, fmt.Errorf("Querying for deliveries: %w", err2)
+		}
+		item.CertificateId = certificateID.String
+		retValue = append(retValue, item)
+	}
+	if er := result.Err(); er != nil {
+		return nil, fmt.Errorf("Querying for deliveries: %w", er)
+	}
+	return retValue, nil
+}
+
+// EnqueueDigest queues the items for the next email digest, unless that would
+// take the queue past limit items, in which case nothing is queued and
+// ErrQueueFull is returned. An item already queued for the same certificate,
+// threshold and recipient is not queued twice.
+func (nr *notificationRepository) EnqueueDigest(ctx context.Context, items []model.DigestItem, limit int) error {
+
+	tx, err := nr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Queueing digest: %w", err)
+	}
+	defer tx.Rollback()
+
+	var queued int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM email_digest_items").Scan(&queued); err != nil {
+		return fmt.Errorf("Queueing digest: %w", err)
+	}
+	if queued+len(items) > limit {
+		return ErrQueueFull
+	}
+	for _, item := range items {
+		_, err := tx.ExecContext(ctx, `INSERT INTO email_digest_items (id, certificate_id, recipient, threshold, severity, created_at)
+			VALUES(?,?,?,?,?,?)
+			ON CONFLICT(certificate_id, threshold, recipient) DO NOTHING`,
+			item.Id,
+			item.Alert.Certificate.Id,
+			item.Recipient,
+			item.Alert.Threshold,
+			item.Alert.Severity,
+			item.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("Queueing digest: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Queueing digest: %w", err)
+	}
+	return nil
+}
+
+// ClaimDigest claims every queued item that is not claimed, or whose claim
+// was taken before staleBefore by a flush that never finished, and returns
+// them with their certificates. Claimed items stay queued until they are
+// removed or released.
+func (nr *notificationRepository) ClaimDigest(ctx context.Context, claim string, at time.Time, staleBefore time.Time) ([]model.DigestItem, error) {
+
+	_, err := nr.db.ExecContext(ctx, `UPDATE email_digest_items SET claimed_by = ?, claimed_at = ?
+		WHERE claimed_at IS NULL OR claimed_at < ?`,
+		claim,
+		at,
+		staleBefore)
+	if err != nil {
+		return nil, fmt.Errorf("Claiming digest: %w", err)
+	}
+
+	result, err := nr.db.QueryContext(ctx, `SELECT `+certificateColumns+`, d.id, d.recipient, d.threshold, d.severity, d.created_at
+		FROM email_digest_items d JOIN certificates c ON c.id = d.certificate_id
+		WHERE d.claimed_by = ?
+		ORDER BY d.created_at, d.id`, claim)
+	if err != nil {
+		return nil, fmt.Errorf("Claiming digest: %w", err)
+	}
+	defer result.Close()
+
+	retValue := []model.DigestItem{}
+	for result.Next() {
+		item := model.DigestItem{}
+		cert, err2 := scanCertificate(result,
+			&item.Id,
+			&item.Recipient,
+			&item.Alert.Threshold,
+			&item.Alert.Severity,
+			&item.CreatedAt)
+		if err2 != nil {
+			return nil, fmt.Errorf("Claiming digest: %w", err2)
+		}
+		item.Alert.Certificate = cert
+		retValue = append(retValue, item)
+	}
+	if er := result.Err(); er != nil {
+		return nil, fmt.Errorf("Claiming digest: %w", er)
+	}
+	return retValue, nil
+}
+
+// RemoveDigest removes items that were sent from the queue.
+func (nr *notificationRepository) RemoveDigest(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := nr.db.ExecContext(ctx, "DELETE FROM email_digest_items WHERE id IN ("+digestPlaceholders(ids)+")", digestArgs(ids)...)
+	if err != nil {
+		return fmt.Errorf("Removing digest items: %w", err)
+	}
+	return nil
+}
+
+// ReleaseDigest gives up the claim on items that could not be sent, so that
+// the next flush sends them again.
+func (nr *notificationRepository) ReleaseDigest(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := nr.db.ExecContext(ctx, "UPDATE email_digest_items SET claimed_by = NULL, claimed_at = NULL WHERE id IN ("+digestPlaceholders(ids)+")", digestArgs(ids)...)
+	if err != nil {
+		return fmt.Errorf("Releasing digest items: %w", err)
+	}
+	return nil
+}
+
+func digestPlaceholders(ids []string) string {
+	return "?" + strings.Repeat(",?", len(ids)-1)
+}
+
+func digestArgs(ids []string) []any {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
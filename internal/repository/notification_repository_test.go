@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+func TestDigestQueue(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := newTestDB(t)
+	if err := NewCertificateRepository(sqlDB).Create(ctx, testCertificate("cert", 1)); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewNotificationRepository(sqlDB)
+	now := time.Now().UTC()
+	item := func(id string, rcpt string) model.DigestItem {
+		return model.DigestItem{
+			Id:        id,
+			Recipient: rcpt,
+			Alert:     model.ExpiryAlert{Certificate: model.Certificate{Id: "cert"}, Threshold: "7d", Severity: model.SeverityHigh},
+			CreatedAt: now,
+		}
+	}
+
+	if err := repo.EnqueueDigest(ctx, []model.DigestItem{item("a", "ops@example.com"), item("b", "dev@example.com")}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.EnqueueDigest(ctx, []model.DigestItem{item("c", "sec@example.com")}, 2); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("EnqueueDigest() past the limit = %v; want %v", err, ErrQueueFull)
+	}
+
+	claimed, err := repo.ClaimDigest(ctx, "first", now, now.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 2 || claimed[0].Alert.Certificate.Id != "cert" || claimed[0].Alert.Severity != model.SeverityHigh {
+		t.Fatalf("ClaimDigest() = %+v; want both items with their certificate", claimed)
+	}
+	if again, err := repo.ClaimDigest(ctx, "second", now, now.Add(-time.Minute)); err != nil || len(again) != 0 {
+		t.Errorf("ClaimDigest() of claimed items = %d, %v; want none", len(again), err)
+	}
+
+	if err := repo.RemoveDigest(ctx, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ReleaseDigest(ctx, []string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+	released, err := repo.ClaimDigest(ctx, "third", now, now.Add(-time.Minute))
+	if err != nil || len(released) != 1 || released[0].Id != "b" {
+		t.Errorf("ClaimDigest() after release = %+v, %v; want only the released item", released, err)
+	}
+
+	later := now.Add(time.Hour)
+	stale, err := repo.ClaimDigest(ctx, "fourth", later, later.Add(-time.Minute))
+	if err != nil || len(stale) != 1 {
+		t.Errorf("ClaimDigest() of a stale claim = %+v, %v; want it taken over", stale, err)
+	}
+}
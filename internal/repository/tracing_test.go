@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/tracing/tracingtest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRepositoriesTraceStatements(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := newTestDB(t)
+	if err := NewCertificateRepository(sqlDB).Create(ctx, testCertificate("cert", 1)); err != nil {
+		t.Fatal(err)
+	}
+	spans := tracingtest.Record(t)
+
+	now := time.Now().UTC()
+	endpoint := &model.Endpoint{Id: "endpoint", Host: "example.com", Port: 443, ServerName: "example.com", CreatedAt: now}
+	if err := NewEndpointRepository(sqlDB).Create(ctx, endpoint); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAlertRepository(sqlDB).List(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAuditRepository(sqlDB).ListAfter(ctx, 0, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	inserts := tracingtest.Named(spans, "INSERT")
+	if len(inserts) != 2 {
+		t.Errorf("recorded %d INSERT spans; want the endpoint's and its audit event's", len(inserts))
+	}
+	selects := tracingtest.Named(spans, "SELECT")
+	if len(selects) < 2 {
+		t.Errorf("recorded %d SELECT spans; want the alerts' and the audit events'", len(selects))
+	}
+	for _, span := range append(inserts, selects...) {
+		if span.SpanKind != trace.SpanKindClient {
+			t.Errorf("span %s has kind %v; want client", span.Name, span.SpanKind)
+		}
+	}
+}
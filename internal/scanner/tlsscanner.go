@@ -0,0 +1,140 @@
+package scanner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/audit"
+	"github.com/hytonhan/certwatch/internal/auth"
+	"github.com/hytonhan/certwatch/internal/certparse"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/service"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+var ErrNoPeerCertificates = errors.New("no peer certificates")
+
+// ScannerActor is recorded in the audit trail for certificates the scanner
+// imports.
+const ScannerActor = "system:scanner"
+
+type TLSScanner struct {
+	endpoints service.EndpointService
+	certs     service.CertificateService
+	interval  time.Duration
+	timeout   time.Duration
+	logger    *slog.Logger
+}
+
+func NewScanner(endpoints service.EndpointService, certs service.CertificateService, interval time.Duration, timeout time.Duration, logger *slog.Logger) *TLSScanner {
+	return &TLSScanner{endpoints: endpoints, certs: certs, interval: interval, timeout: timeout, logger: logger}
+}
+
+func (s *TLSScanner) Start(ctx context.Context) {
+
+	s.logger.InfoContext(ctx, "tls scanner started",
+		"interval", s.interval,
+		"timeout", s.timeout)
+	// Endpoints are scanned right away rather than one interval after startup.
+	s.ScanAll(ctx)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.ScanAll(ctx)
+		}
+	}
+}
+
+// ScanAll scans every registered endpoint once, one after another.
+func (s *TLSScanner) ScanAll(ctx context.Context) {
+	ctx = auth.WithPrincipal(ctx, auth.System("scanner"))
+	ctx = audit.WithActor(ctx, ScannerActor)
+	endpoints, err := s.endpoints.List(ctx)
+	if err != nil {
+		s.logger.WarnContext(ctx, "listing scan endpoints failed")
+		return
+	}
+	for _, endpoint := range endpoints {
+		if ctx.Err() != nil {
+			return
+		}
+		s.Scan(ctx, endpoint)
+	}
+}
+
+// Scan connects to the endpoint, imports the presented chain and records the
+// outcome on the endpoint.
+func (s *TLSScanner) Scan(ctx context.Context, endpoint model.Endpoint) model.ScanResult {
+	result := model.ScanResult{ScannedAt: time.Now()}
+
+	chain, err := s.fetchChain(ctx, endpoint)
+	if err != nil {
+		result.HandshakeFailed = true
+		result.Error = err.Error()
+		s.logger.WarnContext(ctx, "tls handshake failed",
+			"endpoint_id", endpoint.Id,
+			"host", endpoint.Host,
+			"port", endpoint.Port)
+	} else {
+		result.Fingerprint = certparse.Fingerprint(chain[0])
+		imported, ierr := s.certs.ImportChain(ctx, chain, dto.Ownership{OwnerTeam: endpoint.OwnerTeam})
+		if ierr != nil {
+			result.Error = "import failed"
+			s.logger.WarnContext(ctx, "importing scanned chain failed",
+				"endpoint_id", endpoint.Id)
+		}
+		for _, cert := range imported {
+			if cert.Created {
+				s.logger.InfoContext(ctx, "discovered certificate",
+					"endpoint_id", endpoint.Id,
+					"id", cert.Certificate.Id,
+					"fingerprint", cert.Certificate.FingerprintSHA256)
+			}
+		}
+	}
+
+	if err := s.endpoints.RecordScan(ctx, endpoint.Id, result); err != nil {
+		s.logger.WarnContext(ctx, "recording scan failed",
+			"endpoint_id", endpoint.Id)
+	}
+	return result
+}
+
+func (s *TLSScanner) fetchChain(ctx context.Context, endpoint model.Endpoint) ([]*x509.Certificate, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: s.timeout},
+		Config: &tls.Config{
+			ServerName: endpoint.ServerName,
+			// The scanner inventories whatever the endpoint presents, including
+			// expired, self-signed and mismatched certificates, so the chain is
+			// deliberately not verified. Nothing is sent over the connection.
+			InsecureSkipVerify: true,
+		},
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(endpoint.Host, strconv.Itoa(endpoint.Port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	chain := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(chain) == 0 {
+		return nil, ErrNoPeerCertificates
+	}
+	return chain, nil
+}
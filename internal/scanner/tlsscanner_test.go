@@ -0,0 +1,134 @@
+package scanner
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/certparse"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/service"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+type FakeEndpointService struct {
+	service.EndpointService
+	endpoints []model.Endpoint
+	recorded  map[string]model.ScanResult
+}
+
+func (fes *FakeEndpointService) List(ctx context.Context) ([]model.Endpoint, error) {
+	return fes.endpoints, nil
+}
+
+func (fes *FakeEndpointService) RecordScan(ctx context.Context, id string, result model.ScanResult) error {
+	fes.recorded[id] = result
+	return nil
+}
+
+type FakeCertService struct {
+	service.CertificateService
+	imported [][]*x509.Certificate
+	owners   []string
+}
+
+func (fcs *FakeCertService) ImportChain(ctx context.Context, chain []*x509.Certificate, ownership dto.Ownership) ([]service.ImportResult, error) {
+	fcs.imported = append(fcs.imported, chain)
+	fcs.owners = append(fcs.owners, ownership.OwnerTeam)
+	results := []service.ImportResult{}
+	for _, cert := range chain {
+		input := certparse.ToCreateInput(cert)
+		results = append(results, service.ImportResult{
+			Certificate: model.Certificate{Id: input.FingerprintSHA256, FingerprintSHA256: input.FingerprintSHA256},
+			Created:     true,
+		})
+	}
+	return results, nil
+}
+
+func TestScanAll(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// A listener that is closed straight away gives a port nobody answers on.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	serverPort := server.Listener.Addr().(*net.TCPAddr).Port
+	endpoints := &FakeEndpointService{
+		endpoints: []model.Endpoint{
+			{Id: "up", Host: "127.0.0.1", Port: serverPort, ServerName: "example.com", OwnerTeam: "payments"},
+			{Id: "down", Host: "127.0.0.1", Port: closedPort, ServerName: "example.com"},
+		},
+		recorded: map[string]model.ScanResult{},
+	}
+	certs := &FakeCertService{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := NewScanner(endpoints, certs, time.Minute, 2*time.Second, logger)
+
+	s.ScanAll(context.Background())
+
+	up, ok := endpoints.recorded["up"]
+	if !ok {
+		t.Fatalf("ScanAll() did not record scan for %s", "up")
+	}
+	want := certparse.Fingerprint(server.Certificate())
+	if up.HandshakeFailed || up.Fingerprint != want {
+		t.Errorf("ScanAll() up = %+v; want fingerprint %v", up, want)
+	}
+	if len(certs.imported) != 1 || certparse.Fingerprint(certs.imported[0][0]) != want {
+		t.Errorf("ScanAll() imported %d chains; want the served chain", len(certs.imported))
+	} else if certs.owners[0] != "payments" {
+		t.Errorf("ScanAll() imported the chain for %q; want the endpoint owner %q", certs.owners[0], "payments")
+	}
+
+	down, ok := endpoints.recorded["down"]
+	if !ok {
+		t.Fatalf("ScanAll() did not record scan for %s", "down:"+strconv.Itoa(closedPort))
+	}
+	if !down.HandshakeFailed || down.Error == "" || down.Fingerprint != "" {
+		t.Errorf("ScanAll() down = %+v; want handshake failure", down)
+	}
+}
+
+// listedEndpointService signals every List call.
+type listedEndpointService struct {
+	service.EndpointService
+	listed chan struct{}
+}
+
+func (les *listedEndpointService) List(ctx context.Context) ([]model.Endpoint, error) {
+	les.listed <- struct{}{}
+	return nil, nil
+}
+
+func TestStartScansRightAway(t *testing.T) {
+	endpoints := &listedEndpointService{listed: make(chan struct{}, 1)}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := NewScanner(endpoints, &FakeCertService{}, time.Hour, time.Second, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Start(ctx)
+		close(done)
+	}()
+	select {
+	case <-endpoints.listed:
+	case <-time.After(5 * time.Second):
+		t.Error("Start() did not scan before the first tick")
+	}
+	cancel()
+	<-done
+}
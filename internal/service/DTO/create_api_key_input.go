@@ -0,0 +1,7 @@
+package dto
+
+type CreateAPIKeyInput struct {
+	Name   string
+	Scopes []string
+	Team   string
+}
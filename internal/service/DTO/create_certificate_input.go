@@ -9,4 +9,35 @@ type CreateCertificateInput struct {
 	NotBefore         time.Time
 	NotAfter          time.Time
 	FingerprintSHA256 string
+	SubjectKeyId      string
+	AuthorityKeyId    string
+	Ownership
+	Labels         map[string]string
+	DNSNames       []string
+	IPAddresses    []string
+	URIs           []string
+	EmailAddresses []string
+	KeyDetails
+	// PEM is set when the input was read from the certificate itself.
+	PEM string
+}
+
+// KeyDetails describes the public key and signature of a certificate, as
+// read from the certificate itself.
+type KeyDetails struct {
+	KeyAlgorithm       string
+	KeySize            int
+	KeyCurve           string
+	SignatureAlgorithm string
+	KeyUsage           []string
+	ExtKeyUsage        []string
+	IsCA               bool
+	SelfSigned         bool
+}
+
+// Ownership is who owns a certificate and whom to contact about it.
+type Ownership struct {
+	OwnerTeam    string
+	ContactEmail string
+	Description  string
 }
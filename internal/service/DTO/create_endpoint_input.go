@@ -0,0 +1,8 @@
+package dto
+
+type CreateEndpointInput struct {
+	Host       string
+	Port       int
+	ServerName string
+	OwnerTeam  string
+}
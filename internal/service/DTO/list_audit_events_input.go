@@ -0,0 +1,16 @@
+package dto
+
+import "time"
+
+type ListAuditEventsInput struct {
+	Actor      string
+	Action     string
+	EntityType string
+	EntityId   string
+	RequestId  string
+	// From and To bound occurred_at; To is exclusive.
+	From   time.Time
+	To     time.Time
+	Cursor string
+	Limit  int
+}
@@ -0,0 +1,32 @@
+package dto
+
+import "time"
+
+type ListCertificatesInput struct {
+	OwnerTeam string
+	// Selector is a label selector such as "env=prod,app!=legacy".
+	Selector         string
+	Issuer           string
+	CommonNamePrefix string
+	// DNSName matches certificates covering the host name, including
+	// through a wildcard SAN.
+	DNSName      string
+	NotAfterFrom time.Time
+	NotAfterTo   time.Time
+	// ExpiringWithin limits the listing to certificates expiring before
+	// now plus the window, and ExcludeExpired to those not yet expired.
+	ExpiringWithin time.Duration
+	ExcludeExpired bool
+	KeyAlgorithm   string
+	KeySize        int
+	// KeySizeBelow matches keys of a known size smaller than the value.
+	KeySizeBelow       int
+	SignatureAlgorithm string
+	KeyUsage           string
+	ExtKeyUsage        string
+	IsCA               *bool
+	// Sort is a sortable field, prefixed with "-" for descending order.
+	Sort   string
+	Cursor string
+	Limit  int
+}
@@ -0,0 +1,7 @@
+package dto
+
+type ListFindingsInput struct {
+	RuleId   string
+	Severity string
+	Limit    int
+}
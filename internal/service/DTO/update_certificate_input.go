@@ -0,0 +1,27 @@
+package dto
+
+import "time"
+
+// UpdateCertificateInput holds the editable fields of a certificate. Nil
+// fields are left unchanged.
+type UpdateCertificateInput struct {
+	// Version is the version the caller last read; the update fails if the
+	// certificate has changed since.
+	Version      int64
+	CommonName   *string
+	SerialNumber *string
+	Issuer       *string
+	NotBefore    *time.Time
+	NotAfter     *time.Time
+	OwnerTeam    *string
+	ContactEmail *string
+	Description  *string
+	Notes        *string
+	// Labels replaces the whole label set when not nil.
+	Labels map[string]string
+	// The cryptographic identity cannot be changed. These are accepted only
+	// so that a request trying to change them can be rejected clearly.
+	FingerprintSHA256 *string
+	SubjectKeyId      *string
+	AuthorityKeyId    *string
+}
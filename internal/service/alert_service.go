@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+)
+
+type AlertService interface {
+	// Record reports whether the alert should be sent now. It is false when
+	// the certificate was already alerted for the threshold, by this or
+	// another replica, and neither a retry nor a reminder is due. An alert
+	// that is not reported Delivered is sent again after deliveryRetryDelay.
+	Record(ctx context.Context, certificateID string, threshold string) (bool, error)
+	Delivered(ctx context.Context, certificateID string, threshold string) error
+	List(ctx context.Context, acknowledged *bool) ([]model.AlertState, error)
+	Acknowledge(ctx context.Context, id string) error
+}
+
+// deliveryRetryDelay is how long an alert that was not delivered waits before
+// it is sent again, whether sending failed or the replica sending it went
+// away. It outlasts a delivery with its retries, so that a replica still
+// delivering the alert is not raced.
+const deliveryRetryDelay = 5 * time.Minute
+
+type alertService struct {
+	repo     repository.AlertRepository
+	audit    repository.AuditRepository
+	renotify time.Duration
+	clock    Clock
+}
+
+// NewAlertService creates the alert service. Unacknowledged alerts are sent
+// again every renotify interval; zero disables reminders. Acknowledgements
+// refused to the caller are recorded in audit unless it is nil.
+func NewAlertService(repo repository.AlertRepository, audit repository.AuditRepository, renotify time.Duration) AlertService {
+	return &alertService{repo: repo, audit: audit, renotify: renotify, clock: NewClock()}
+}
+
+func (as *alertService) Record(ctx context.Context, certificateID string, threshold string) (bool, error) {
+	if certificateID == "" || threshold == "" || len(threshold) > 32 {
+		return false, ErrInvalidInput
+	}
+
+	now := as.clock.Now().UTC()
+	var renotifyBefore time.Time
+	if as.renotify > 0 {
+		renotifyBefore = now.Add(-as.renotify)
+	}
+
+	state := model.AlertState{
+		Id:             uuid.NewString(),
+		CertificateId:  certificateID,
+		Threshold:      threshold,
+		FirstAlertedAt: now,
+		LastAlertedAt:  now,
+	}
+
+	claimed, err := as.repo.Claim(ctx, &state, renotifyBefore, now.Add(-deliveryRetryDelay))
+	if err != nil {
+		return false, fmt.Errorf("Recording alert: %w", err)
+	}
+
+	return claimed, nil
+}
+
+func (as *alertService) Delivered(ctx context.Context, certificateID string, threshold string) error {
+	if certificateID == "" || threshold == "" {
+		return ErrInvalidInput
+	}
+	if err := as.repo.MarkDelivered(ctx, certificateID, threshold); err != nil {
+		return fmt.Errorf("Recording alert delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (as *alertService) List(ctx context.Context, acknowledged *bool) ([]model.AlertState, error) {
+	alerts, err := as.repo.List(ctx, acknowledged)
+	if err != nil {
+		return nil, fmt.Errorf("Getting alerts: %w", err)
+	}
+
+	return alerts, nil
+}
+
+func (as *alertService) Acknowledge(ctx context.Context, id string) error {
+	if id == "" {
+		return ErrInvalidInput
+	}
+	state, err := as.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return repository.ErrNotFound
+		}
+		return fmt.Errorf("Acknowledging alert: %w", err)
+	}
+	if err := authorize(ctx, as.audit, model.AuditEntityAlert, model.AuditAlertAcknowledge, id, state.OwnerTeam); err != nil {
+		return err
+	}
+
+	err = as.repo.Acknowledge(ctx, id, as.clock.Now().UTC(), state.OwnerTeam)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return repository.ErrNotFound
+		}
+		if errors.Is(err, repository.ErrStaleVersion) {
+			return repository.ErrStaleVersion
+		}
+		return fmt.Errorf("Acknowledging alert: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/auth"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+)
+
+type FakeAlertRepo struct {
+	claimed        map[string]bool
+	renotifyBefore time.Time
+	retryBefore    time.Time
+	delivered      []string
+}
+
+func (far *FakeAlertRepo) Claim(ctx context.Context, state *model.AlertState, renotifyBefore time.Time, retryBefore time.Time) (bool, error) {
+	far.renotifyBefore = renotifyBefore
+	far.retryBefore = retryBefore
+	key := state.CertificateId + "/" + state.Threshold
+	if far.claimed[key] {
+		return false, nil
+	}
+	far.claimed[key] = true
+	return true, nil
+}
+
+func (far *FakeAlertRepo) MarkDelivered(ctx context.Context, certificateID string, threshold string) error {
+	far.delivered = append(far.delivered, certificateID+"/"+threshold)
+	return nil
+}
+
+func (far *FakeAlertRepo) List(ctx context.Context, acknowledged *bool) ([]model.AlertState, error) {
+	return []model.AlertState{}, nil
+}
+
+// Get finds id1, owned by payments, and id2, of a certificate without an owner.
+func (far *FakeAlertRepo) Get(ctx context.Context, id string) (*model.AlertState, error) {
+	switch id {
+	case "id1":
+		return &model.AlertState{Id: id, CertificateId: "cert1", Threshold: "7d", OwnerTeam: "payments"}, nil
+	case "id2":
+		return &model.AlertState{Id: id, CertificateId: "cert2", Threshold: "7d"}, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (far *FakeAlertRepo) Acknowledge(ctx context.Context, id string, at time.Time, ownerTeam string) error {
+	if id == "id1" || id == "id2" {
+		return nil
+	}
+	return repository.ErrNotFound
+}
+
+func TestRecordAlert(t *testing.T) {
+	tests := []struct {
+		name          string
+		certificateID string
+		threshold     string
+		send          bool
+		expected      error
+	}{
+		{"first alert is sent", "id1", "3d", true, nil},
+		{"repeated alert is not sent", "id1", "3d", false, nil},
+		{"next threshold is sent", "id1", "1d", true, nil},
+		{"empty certificate id", "", "3d", false, ErrInvalidInput},
+		{"empty threshold", "id1", "", false, ErrInvalidInput},
+	}
+	repo := &FakeAlertRepo{claimed: map[string]bool{}}
+	srv := NewAlertService(repo, nil, 0)
+	ctx := systemContext()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			send, err := srv.Record(ctx, test.certificateID, test.threshold)
+			if !errors.Is(err, test.expected) {
+				t.Errorf("Record(%q, %q) = %v; want %v", test.certificateID, test.threshold, err, test.expected)
+			}
+			if send != test.send {
+				t.Errorf("Record(%q, %q) = %v; want %v", test.certificateID, test.threshold, send, test.send)
+			}
+		})
+	}
+	if !repo.renotifyBefore.IsZero() {
+		t.Errorf("Record() renotifyBefore = %v; want zero when reminders are disabled", repo.renotifyBefore)
+	}
+	if age := time.Since(repo.retryBefore); age < deliveryRetryDelay || age > deliveryRetryDelay+time.Minute {
+		t.Errorf("Record() retryBefore = %v ago; want about %v", age, deliveryRetryDelay)
+	}
+}
+
+func TestAlertDelivered(t *testing.T) {
+	repo := &FakeAlertRepo{}
+	srv := NewAlertService(repo, nil, 0)
+
+	if err := srv.Delivered(context.Background(), "id1", "3d"); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.delivered) != 1 || repo.delivered[0] != "id1/3d" {
+		t.Errorf("delivered = %v; want [id1/3d]", repo.delivered)
+	}
+	if err := srv.Delivered(context.Background(), "", "3d"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Delivered() without id = %v; want %v", err, ErrInvalidInput)
+	}
+}
+
+func TestRecordAlertRenotify(t *testing.T) {
+	repo := &FakeAlertRepo{claimed: map[string]bool{}}
+	srv := NewAlertService(repo, nil, time.Hour)
+
+	if _, err := srv.Record(context.Background(), "id1", "3d"); err != nil {
+		t.Fatal(err)
+	}
+	age := time.Since(repo.renotifyBefore)
+	if age < time.Hour || age > time.Hour+time.Minute {
+		t.Errorf("Record() renotifyBefore = %v ago; want about %v", age, time.Hour)
+	}
+}
+
+func TestAcknowledgeAlert(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected error
+	}{
+		{"valid input", "id1", nil},
+		{"empty input", "", ErrInvalidInput},
+		{"ErrNotFound bubbles", "doesn't exists", repository.ErrNotFound},
+	}
+	srv := NewAlertService(&FakeAlertRepo{}, nil, 0)
+	ctx := systemContext()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := srv.Acknowledge(ctx, test.input)
+			if !errors.Is(err, test.expected) {
+				t.Errorf("Acknowledge(%q); want %v", test.input, test.expected)
+			}
+		})
+	}
+}
+
+func TestAcknowledgeAlertAuthorization(t *testing.T) {
+	admin := &auth.Principal{Kind: auth.KindAPIKey, Name: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}}
+	editor := &auth.Principal{Kind: auth.KindAPIKey, Name: "payments", Scopes: []auth.Scope{auth.ScopeCertsWrite}, Team: "payments"}
+	otherEditor := &auth.Principal{Kind: auth.KindAPIKey, Name: "billing", Scopes: []auth.Scope{auth.ScopeCertsWrite}, Team: "billing"}
+	viewer := &auth.Principal{Kind: auth.KindOIDC, Name: "alice", Scopes: []auth.Scope{auth.ScopeCertsRead}, Team: "payments"}
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		id        string
+		expected  error
+	}{
+		{"editor of the owning team", editor, "id1", nil},
+		{"editor of other team", otherEditor, "id1", ErrForbidden},
+		{"editor on unowned certificate", editor, "id2", ErrForbidden},
+		{"viewer", viewer, "id1", ErrForbidden},
+		{"admin on unowned certificate", admin, "id2", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			audit := &FakeAuditRepo{}
+			srv := NewAlertService(&FakeAlertRepo{}, audit, 0)
+			ctx := auth.WithPrincipal(context.Background(), test.principal)
+
+			err := srv.Acknowledge(ctx, test.id)
+			if !errors.Is(err, test.expected) {
+				t.Errorf("Acknowledge(%q) = %v; want %v", test.id, err, test.expected)
+			}
+			denied := errors.Is(test.expected, ErrForbidden)
+			if denied != (len(audit.denials) == 1) {
+				t.Errorf("denials = %+v; want one only if forbidden", audit.denials)
+			}
+		})
+	}
+}
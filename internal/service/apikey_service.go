@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hytonhan/certwatch/internal/auth"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+const (
+	// touchInterval is how stale a key's last_used_at may get before
+	// authenticating with it records the use again.
+	touchInterval = time.Minute
+	// verifiedTTL is how long a token that verified against its key's secret
+	// hash is trusted without hashing it again. Hashing costs tens of
+	// milliseconds and much memory on purpose, too much for every request.
+	verifiedTTL = time.Minute
+	// maxVerified bounds the verified tokens kept.
+	maxVerified = 1024
+)
+
+var apiKeyNamePattern = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]{0,62}[A-Za-z0-9])?$`)
+
+// CreatedAPIKey is a new key together with its token, which is not stored
+// and cannot be shown again.
+type CreatedAPIKey struct {
+	Key   model.APIKey
+	Token string
+}
+
+type APIKeyService interface {
+	Create(ctx context.Context, input dto.CreateAPIKeyInput) (*CreatedAPIKey, error)
+	List(ctx context.Context) ([]model.APIKey, error)
+	Revoke(ctx context.Context, id string) error
+	// Authenticate resolves a bearer token to its principal. Any token that
+	// does not belong to an active key is auth.ErrInvalidToken.
+	Authenticate(ctx context.Context, token string) (*auth.Principal, error)
+}
+
+type apiKeyService struct {
+	repo  repository.APIKeyRepository
+	clock Clock
+
+	mu sync.Mutex
+	// verified holds the tokens that verified recently, by their SHA-256.
+	verified map[[sha256.Size]byte]verifiedToken
+}
+
+// verifiedToken records that a token verified against a key's secret hash.
+type verifiedToken struct {
+	keyID      string
+	secretHash string
+	expires    time.Time
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository) APIKeyService {
+	return &apiKeyService{repo: repo, clock: NewClock(), verified: map[[sha256.Size]byte]verifiedToken{}}
+}
+
+func (as *apiKeyService) Create(ctx context.Context, input dto.CreateAPIKeyInput) (*CreatedAPIKey, error) {
+	if !apiKeyNamePattern.MatchString(input.Name) || len(input.Scopes) == 0 {
+		return nil, ErrInvalidInput
+	}
+	scopes := []string{}
+	for _, scope := range input.Scopes {
+		if !auth.ValidScope(auth.Scope(scope)) {
+			return nil, ErrInvalidInput
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	// An editor key without a team could change no certificate.
+	team := strings.TrimSpace(input.Team)
+	if len(team) > maxOwnerTeamLength || hasControlChars(team) {
+		return nil, ErrInvalidInput
+	}
+	if slices.Contains(scopes, string(auth.ScopeCertsWrite)) && !slices.Contains(scopes, string(auth.ScopeAdmin)) && team == "" {
+		return nil, ErrInvalidInput
+	}
+
+	token, prefix, err := auth.NewToken()
+	if err != nil {
+		return nil, fmt.Errorf("Creating api key: %w", err)
+	}
+	_, secret, err := auth.ParseToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("Creating api key: %w", err)
+	}
+	secretHash, err := auth.HashSecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("Creating api key: %w", err)
+	}
+
+	key := model.APIKey{
+		Id:         uuid.NewString(),
+		Name:       input.Name,
+		Prefix:     prefix,
+		SecretHash: secretHash,
+		Scopes:     scopes,
+		Team:       team,
+		CreatedAt:  as.clock.Now().UTC(),
+	}
+	if err := as.repo.Create(ctx, &key); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return nil, repository.ErrConflict
+		}
+		return nil, fmt.Errorf("Creating api key: %w", err)
+	}
+
+	return &CreatedAPIKey{Key: key, Token: token}, nil
+}
+
+func (as *apiKeyService) List(ctx context.Context) ([]model.APIKey, error) {
+	keys, err := as.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Getting api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (as *apiKeyService) Revoke(ctx context.Context, id string) error {
+	if id == "" {
+		return ErrInvalidInput
+	}
+	if err := as.repo.Revoke(ctx, id, as.clock.Now().UTC()); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return repository.ErrNotFound
+		}
+		return fmt.Errorf("Revoking api key: %w", err)
+	}
+	as.forget(id)
+	return nil
+}
+
+func (as *apiKeyService) Authenticate(ctx context.Context, token string) (*auth.Principal, error) {
+	prefix, secret, err := auth.ParseToken(token)
+	if err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+	key, err := as.repo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("Authenticating: %w", err)
+	}
+	if key.RevokedAt != nil {
+		return nil, auth.ErrInvalidToken
+	}
+	now := as.clock.Now().UTC()
+	// The key is still read on every request, so that a key revoked on
+	// another replica is refused at once.
+	digest := sha256.Sum256([]byte(token))
+	if !as.isVerified(digest, key, now) {
+		ok, err := auth.VerifySecret(secret, key.SecretHash)
+		if err != nil {
+			return nil, fmt.Errorf("Authenticating: %w", err)
+		}
+		if !ok {
+			return nil, auth.ErrInvalidToken
+		}
+		as.remember(digest, key, now)
+	}
+
+	if err := as.repo.Touch(ctx, key.Id, now, now.Add(-touchInterval)); err != nil {
+		return nil, fmt.Errorf("Authenticating: %w", err)
+	}
+
+	principal := &auth.Principal{Id: key.Id, Name: key.Name, Kind: auth.KindAPIKey, Team: key.Team}
+	for _, scope := range key.Scopes {
+		principal.Scopes = append(principal.Scopes, auth.Scope(scope))
+	}
+	return principal, nil
+}
+
+// isVerified reports whether the token verified against the key's current
+// secret hash within verifiedTTL.
+func (as *apiKeyService) isVerified(digest [sha256.Size]byte, key *model.APIKey, now time.Time) bool {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	verified, ok := as.verified[digest]
+	return ok && verified.keyID == key.Id && verified.secretHash == key.SecretHash && now.Before(verified.expires)
+}
+
+// remember records that the token verified. When the cache is full, expired
+// tokens are dropped, and the token is not kept if that frees no room.
+func (as *apiKeyService) remember(digest [sha256.Size]byte, key *model.APIKey, now time.Time) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if len(as.verified) >= maxVerified {
+		for d, verified := range as.verified {
+			if !now.Before(verified.expires) {
+				delete(as.verified, d)
+			}
+		}
+		if len(as.verified) >= maxVerified {
+			return
+		}
+	}
+	as.verified[digest] = verifiedToken{keyID: key.Id, secretHash: key.SecretHash, expires: now.Add(verifiedTTL)}
+}
+
+// forget drops the verified tokens of the key.
+func (as *apiKeyService) forget(id string) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	for d, verified := range as.verified {
+		if verified.keyID == id {
+			delete(as.verified, d)
+		}
+	}
+}
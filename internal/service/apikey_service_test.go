@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/auth"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+type FakeAPIKeyRepo struct {
+	keys    map[string]*model.APIKey
+	touched int
+}
+
+func (fkr *FakeAPIKeyRepo) Create(ctx context.Context, key *model.APIKey) error {
+	for _, existing := range fkr.keys {
+		if existing.Name == key.Name {
+			return repository.ErrConflict
+		}
+	}
+	stored := *key
+	fkr.keys[key.Id] = &stored
+	return nil
+}
+
+func (fkr *FakeAPIKeyRepo) GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	for _, key := range fkr.keys {
+		if key.Prefix == prefix {
+			found := *key
+			return &found, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (fkr *FakeAPIKeyRepo) List(ctx context.Context) ([]model.APIKey, error) {
+	return []model.APIKey{}, nil
+}
+
+func (fkr *FakeAPIKeyRepo) Revoke(ctx context.Context, id string, at time.Time) error {
+	key, ok := fkr.keys[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	key.RevokedAt = &at
+	return nil
+}
+
+func (fkr *FakeAPIKeyRepo) Touch(ctx context.Context, id string, at time.Time, before time.Time) error {
+	fkr.touched++
+	return nil
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    dto.CreateAPIKeyInput
+		expected error
+	}{
+		{"valid", dto.CreateAPIKeyInput{Name: "ci-deployer", Scopes: []string{"certs:read", "certs:write"}, Team: "payments"}, nil},
+		{"duplicate scopes", dto.CreateAPIKeyInput{Name: "reader", Scopes: []string{"certs:read", "certs:read"}}, nil},
+		{"taken name", dto.CreateAPIKeyInput{Name: "ci-deployer", Scopes: []string{"admin"}}, repository.ErrConflict},
+		{"no name", dto.CreateAPIKeyInput{Scopes: []string{"admin"}}, ErrInvalidInput},
+		{"invalid name", dto.CreateAPIKeyInput{Name: "ci deployer", Scopes: []string{"admin"}}, ErrInvalidInput},
+		{"no scopes", dto.CreateAPIKeyInput{Name: "none"}, ErrInvalidInput},
+		{"editor without team", dto.CreateAPIKeyInput{Name: "editor", Scopes: []string{"certs:write"}}, ErrInvalidInput},
+		{"team with control chars", dto.CreateAPIKeyInput{Name: "reader", Scopes: []string{"certs:read"}, Team: "pay\nments"}, ErrInvalidInput},
+		{"unknown scope", dto.CreateAPIKeyInput{Name: "root", Scopes: []string{"root"}}, ErrInvalidInput},
+	}
+	repo := &FakeAPIKeyRepo{keys: map[string]*model.APIKey{}}
+	srv := NewAPIKeyService(repo)
+	ctx := context.Background()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			created, err := srv.Create(ctx, test.input)
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("Create() error = %v; want %v", err, test.expected)
+			}
+			if err != nil {
+				return
+			}
+			stored := repo.keys[created.Key.Id]
+			if stored == nil || stored.SecretHash == "" || stored.SecretHash == created.Token {
+				t.Errorf("Create() stored %+v; want a hashed secret", stored)
+			}
+			if len(stored.Scopes) > len(test.input.Scopes) || len(stored.Scopes) == 0 {
+				t.Errorf("Create() scopes = %v", stored.Scopes)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	repo := &FakeAPIKeyRepo{keys: map[string]*model.APIKey{}}
+	srv := NewAPIKeyService(repo)
+	ctx := context.Background()
+
+	reader, err := srv.Create(ctx, dto.CreateAPIKeyInput{Name: "reader", Scopes: []string{"certs:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := srv.Create(ctx, dto.CreateAPIKeyInput{Name: "revoked", Scopes: []string{"admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Revoke(ctx, revoked.Key.Id); err != nil {
+		t.Fatal(err)
+	}
+	forged, _, _ := auth.NewToken()
+	prefix, _, _ := auth.ParseToken(reader.Token)
+
+	tests := []struct {
+		name     string
+		token    string
+		expected error
+	}{
+		{"valid", reader.Token, nil},
+		{"revoked", revoked.Token, auth.ErrInvalidToken},
+		{"unknown", forged, auth.ErrInvalidToken},
+		{"wrong secret", "cw_" + prefix + "_wrong", auth.ErrInvalidToken},
+		{"malformed", "Bearer " + reader.Token, auth.ErrInvalidToken},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			principal, err := srv.Authenticate(ctx, test.token)
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("Authenticate() error = %v; want %v", err, test.expected)
+			}
+			if err == nil && (principal.Id != reader.Key.Id || !principal.HasScope(auth.ScopeCertsRead) || principal.HasScope(auth.ScopeCertsWrite)) {
+				t.Errorf("Authenticate() = %+v; want reader", principal)
+			}
+		})
+	}
+	if repo.touched != 1 {
+		t.Errorf("Authenticate() touched %d times; want 1", repo.touched)
+	}
+}
+
+func TestAuthenticateRemembersVerifiedTokens(t *testing.T) {
+	repo := &FakeAPIKeyRepo{keys: map[string]*model.APIKey{}}
+	srv := NewAPIKeyService(repo).(*apiKeyService)
+	ctx := context.Background()
+
+	reader, err := srv.Create(ctx, dto.CreateAPIKeyInput{Name: "reader", Scopes: []string{"certs:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix, _, _ := auth.ParseToken(reader.Token)
+	for _, token := range []string{reader.Token, reader.Token, "cw_" + prefix + "_wrong"} {
+		srv.Authenticate(ctx, token)
+	}
+	if len(srv.verified) != 1 {
+		t.Fatalf("Authenticate() remembered %d tokens; want only the valid one", len(srv.verified))
+	}
+
+	// A changed secret hash is verified again rather than trusted.
+	secretHash := repo.keys[reader.Key.Id].SecretHash
+	repo.keys[reader.Key.Id].SecretHash = "changed"
+	if _, err := srv.Authenticate(ctx, reader.Token); err == nil {
+		t.Error("Authenticate() with a changed secret hash = nil error; want the token verified again")
+	}
+	repo.keys[reader.Key.Id].SecretHash = secretHash
+
+	if err := srv.Revoke(ctx, reader.Key.Id); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.verified) != 0 {
+		t.Errorf("Revoke() kept %d verified tokens; want none", len(srv.verified))
+	}
+	if _, err := srv.Authenticate(ctx, reader.Token); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Authenticate() after Revoke() error = %v; want %v", err, auth.ErrInvalidToken)
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	srv := NewAPIKeyService(&FakeAPIKeyRepo{keys: map[string]*model.APIKey{}})
+	ctx := context.Background()
+
+	if err := srv.Revoke(ctx, "missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Revoke(missing) error = %v; want %v", err, repository.ErrNotFound)
+	}
+	if err := srv.Revoke(ctx, ""); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Revoke() error = %v; want %v", err, ErrInvalidInput)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/hytonhan/certwatch/internal/audit"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 500
+	maxAuditFilter    = 256
+	// verifyBatchSize is how many events Verify reads at a time.
+	verifyBatchSize = 500
+)
+
+// AuditPage is one page of audit events, newest first. NextCursor is empty on
+// the last page.
+type AuditPage struct {
+	Items      []model.AuditEvent
+	NextCursor string
+}
+
+type AuditService interface {
+	List(ctx context.Context, input dto.ListAuditEventsInput) (*AuditPage, error)
+	// Verify walks the hash chain from the first event and reports the
+	// first event that breaks it.
+	Verify(ctx context.Context) (*model.AuditVerification, error)
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(repo repository.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+// List returns one page of the audit trail. The cursor is the id of the last
+// event of the previous page, so events recorded while paging do not shift
+// the pages.
+func (as *auditService) List(ctx context.Context, input dto.ListAuditEventsInput) (*AuditPage, error) {
+	if input.Limit < 0 || input.Limit > maxAuditLimit {
+		return nil, ErrInvalidInput
+	}
+	for _, value := range []string{input.Actor, input.Action, input.EntityType, input.EntityId, input.RequestId} {
+		if len(value) > maxAuditFilter {
+			return nil, ErrInvalidInput
+		}
+	}
+	if !input.From.IsZero() && !input.To.IsZero() && !input.From.Before(input.To) {
+		return nil, ErrInvalidDateRange
+	}
+	var before model.AuditEventId
+	if input.Cursor != "" {
+		id, err := strconv.ParseInt(input.Cursor, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, ErrInvalidInput
+		}
+		before = id
+	}
+	limit := input.Limit
+	if limit == 0 {
+		limit = defaultAuditLimit
+	}
+
+	filter := repository.AuditFilter{
+		Actor:      input.Actor,
+		Action:     input.Action,
+		EntityType: input.EntityType,
+		EntityId:   input.EntityId,
+		RequestId:  input.RequestId,
+		From:       input.From,
+		To:         input.To,
+	}
+	// One extra row tells whether there is a next page.
+	events, err := as.repo.List(ctx, filter, before, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("Getting audit events: %w", err)
+	}
+
+	result := &AuditPage{Items: events}
+	if len(events) > limit {
+		result.Items = events[:limit]
+		result.NextCursor = strconv.FormatInt(events[limit-1].Id, 10)
+	}
+	return result, nil
+}
+
+func (as *auditService) Verify(ctx context.Context) (*model.AuditVerification, error) {
+	result := &model.AuditVerification{Valid: true, LastHash: audit.GenesisHash}
+	for {
+		events, err := as.repo.ListAfter(ctx, result.LastId, verifyBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("Verifying audit events: %w", err)
+		}
+		for _, event := range events {
+			result.Checked++
+			if err := audit.Check(result.LastHash, event); err != nil {
+				result.Valid = false
+				result.BrokenAt = event.Id
+				result.Reason = err.Error()
+				return result, nil
+			}
+			result.LastId = event.Id
+			result.LastHash = event.Hash
+		}
+		if len(events) < verifyBatchSize {
+			return result, nil
+		}
+	}
+}
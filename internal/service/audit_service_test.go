@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/audit"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+type FakeAuditRepo struct {
+	// events are ordered newest first, like the repository returns them.
+	events  []model.AuditEvent
+	denials []model.AccessDenial
+}
+
+func (far *FakeAuditRepo) List(ctx context.Context, filter repository.AuditFilter, before model.AuditEventId, limit int) ([]model.AuditEvent, error) {
+	retValue := []model.AuditEvent{}
+	for _, event := range far.events {
+		if before > 0 && event.Id >= before {
+			continue
+		}
+		if filter.Action != "" && event.Action != filter.Action {
+			continue
+		}
+		if len(retValue) == limit {
+			break
+		}
+		retValue = append(retValue, event)
+	}
+	return retValue, nil
+}
+
+func (far *FakeAuditRepo) ListAfter(ctx context.Context, after model.AuditEventId, limit int) ([]model.AuditEvent, error) {
+	retValue := []model.AuditEvent{}
+	for i := len(far.events) - 1; i >= 0 && len(retValue) < limit; i-- {
+		if far.events[i].Id > after {
+			retValue = append(retValue, far.events[i])
+		}
+	}
+	return retValue, nil
+}
+
+func (far *FakeAuditRepo) Seal(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (far *FakeAuditRepo) RecordDenied(ctx context.Context, entityType string, entityID string, denial model.AccessDenial) error {
+	far.denials = append(far.denials, denial)
+	return nil
+}
+
+// newTestAuditService returns a service over a valid chain of five events.
+func newTestAuditService() (AuditService, *FakeAuditRepo) {
+	repo := &FakeAuditRepo{}
+	prevHash := audit.GenesisHash
+	for id := int64(1); id <= 5; id++ {
+		action := model.AuditCertificateUpdate
+		if id == 1 {
+			action = model.AuditCertificateCreate
+		}
+		event := model.AuditEvent{
+			Id:         id,
+			OccurredAt: time.Date(2026, 1, 1, 0, 0, int(id), 0, time.UTC),
+			Action:     action,
+			EntityType: "certificate",
+			EntityId:   "id1",
+			After:      json.RawMessage(`{"Version":` + strconv.FormatInt(id, 10) + `}`),
+			PrevHash:   prevHash,
+		}
+		event.Hash = audit.Hash(event)
+		prevHash = event.Hash
+		repo.events = append([]model.AuditEvent{event}, repo.events...)
+	}
+	return NewAuditService(repo), repo
+}
+
+func TestListAuditEvents(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		input      dto.ListAuditEventsInput
+		count      int
+		nextCursor string
+		expected   error
+	}{
+		{"no filter", dto.ListAuditEventsInput{}, 5, "", nil},
+		{"first page", dto.ListAuditEventsInput{Limit: 2}, 2, "4", nil},
+		{"second page", dto.ListAuditEventsInput{Limit: 2, Cursor: "4"}, 2, "2", nil},
+		{"last page", dto.ListAuditEventsInput{Limit: 2, Cursor: "2"}, 1, "", nil},
+		{"action", dto.ListAuditEventsInput{Action: model.AuditCertificateCreate}, 1, "", nil},
+		{"time range", dto.ListAuditEventsInput{From: now.Add(-time.Hour), To: now}, 5, "", nil},
+		{"inverted range", dto.ListAuditEventsInput{From: now, To: now.Add(-time.Hour)}, 0, "", ErrInvalidDateRange},
+		{"invalid cursor", dto.ListAuditEventsInput{Cursor: "abc"}, 0, "", ErrInvalidInput},
+		{"negative cursor", dto.ListAuditEventsInput{Cursor: "-1"}, 0, "", ErrInvalidInput},
+		{"limit too large", dto.ListAuditEventsInput{Limit: maxAuditLimit + 1}, 0, "", ErrInvalidInput},
+	}
+	srv, _ := newTestAuditService()
+	ctx := context.Background()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := srv.List(ctx, test.input)
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("List() error = %v; want %v", err, test.expected)
+			}
+			if err != nil {
+				return
+			}
+			if len(page.Items) != test.count || page.NextCursor != test.nextCursor {
+				t.Errorf("List() = %d events, cursor %q; want %d, %q", len(page.Items), page.NextCursor, test.count, test.nextCursor)
+			}
+		})
+	}
+}
+
+func TestVerifyAuditEvents(t *testing.T) {
+	// Events are stored newest first, so index 4 is the event with id 1.
+	tests := []struct {
+		name     string
+		modify   func(events []model.AuditEvent) []model.AuditEvent
+		valid    bool
+		brokenAt model.AuditEventId
+		reason   error
+	}{
+		{"intact", func(events []model.AuditEvent) []model.AuditEvent { return events }, true, 0, nil},
+		{"edited actor", func(events []model.AuditEvent) []model.AuditEvent {
+			events[2].Actor = "someone else"
+			return events
+		}, false, 3, audit.ErrTampered},
+		{"edited snapshot", func(events []model.AuditEvent) []model.AuditEvent {
+			events[3].After = json.RawMessage(`{"Version":7}`)
+			return events
+		}, false, 2, audit.ErrTampered},
+		{"rehashed event", func(events []model.AuditEvent) []model.AuditEvent {
+			events[3].Actor = "someone else"
+			events[3].Hash = audit.Hash(events[3])
+			return events
+		}, false, 3, audit.ErrBrokenLink},
+		{"removed event", func(events []model.AuditEvent) []model.AuditEvent {
+			return append(events[:2], events[3:]...)
+		}, false, 4, audit.ErrBrokenLink},
+		{"removed first event", func(events []model.AuditEvent) []model.AuditEvent { return events[:4] }, false, 2, audit.ErrBrokenLink},
+	}
+	ctx := context.Background()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, repo := newTestAuditService()
+			repo.events = test.modify(repo.events)
+			result, err := srv.Verify(ctx)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if result.Valid != test.valid || result.BrokenAt != test.brokenAt {
+				t.Errorf("Verify() = %+v; want valid %v, broken at %d", result, test.valid, test.brokenAt)
+			}
+			if test.reason != nil && result.Reason != test.reason.Error() {
+				t.Errorf("Verify() reason = %q; want %q", result.Reason, test.reason)
+			}
+			if test.valid && (result.Checked != 5 || result.LastId != 5 || result.LastHash != repo.events[0].Hash) {
+				t.Errorf("Verify() = %+v; want head 5 %s", result, repo.events[0].Hash)
+			}
+		})
+	}
+}
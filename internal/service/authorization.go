@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hytonhan/certwatch/internal/auth"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+)
+
+// authorize checks that the caller may make the change to an entity owned by
+// the owners: admins change anything, editors what is owned by their team and
+// viewers nothing. The server's own work, e.g. the TLS scanner, runs as an
+// auth.System principal, which is an admin; calls without a principal are
+// refused, and so are editors when there are no owners to check. A refusal is
+// recorded in the audit trail unless audit is nil.
+func authorize(ctx context.Context, audit repository.AuditRepository, entityType string, action string, id string, owners ...string) error {
+	principal := auth.PrincipalFrom(ctx)
+	var role auth.Role
+	team := ""
+	reason := ""
+	if principal == nil {
+		reason = "caller is not authenticated"
+	} else {
+		role, team = principal.Role(), principal.Team
+		switch role {
+		case auth.RoleAdmin:
+			return nil
+		case auth.RoleEditor:
+			if len(owners) == 0 {
+				// Nothing shows the entity is the team's.
+				reason = entityType + " has no owner to check"
+			}
+			for _, owner := range owners {
+				if team == "" || owner != team {
+					reason = entityType + " is not owned by the caller's team"
+					break
+				}
+			}
+		default:
+			reason = "role may not change " + entityType + "s"
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+
+	if audit != nil {
+		ownerTeam := ""
+		if len(owners) > 0 {
+			ownerTeam = owners[0]
+		}
+		denial := model.AccessDenial{Action: action, Role: string(role), Team: team, OwnerTeam: ownerTeam, Reason: reason}
+		if err := audit.RecordDenied(ctx, entityType, id, denial); err != nil {
+			return fmt.Errorf("Authorizing: %w", err)
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrForbidden, reason)
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hytonhan/certwatch/internal/auth"
+	"github.com/hytonhan/certwatch/internal/model"
+)
+
+func TestAuthorizeWithoutOwners(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		expected  error
+	}{
+		{"admin", &auth.Principal{Kind: auth.KindAPIKey, Name: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}}, nil},
+		{"editor", &auth.Principal{Kind: auth.KindAPIKey, Name: "payments", Scopes: []auth.Scope{auth.ScopeCertsWrite}, Team: "payments"}, ErrForbidden},
+		{"viewer", &auth.Principal{Kind: auth.KindAPIKey, Name: "alice", Scopes: []auth.Scope{auth.ScopeCertsRead}}, ErrForbidden},
+		{"unauthenticated", nil, ErrForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			audit := &FakeAuditRepo{}
+			ctx := context.Background()
+			if test.principal != nil {
+				ctx = auth.WithPrincipal(ctx, test.principal)
+			}
+
+			err := authorize(ctx, audit, model.AuditEntityCertificate, model.AuditCertificateUpdate, "id1")
+			if !errors.Is(err, test.expected) {
+				t.Errorf("authorize() without owners = %v; want %v", err, test.expected)
+			}
+			if denied := errors.Is(test.expected, ErrForbidden); denied != (len(audit.denials) == 1) {
+				t.Errorf("denials = %+v; want one only if forbidden", audit.denials)
+			}
+		})
+	}
+}
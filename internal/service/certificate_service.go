@@ -2,21 +2,56 @@ package service
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"net/mail"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
+	"github.com/hytonhan/certwatch/internal/certparse"
+	"github.com/hytonhan/certwatch/internal/labels"
 	"github.com/hytonhan/certwatch/internal/model"
 	"github.com/hytonhan/certwatch/internal/repository"
 	dto "github.com/hytonhan/certwatch/internal/service/DTO"
 )
 
+const (
+	// maxChainLength caps how many certificates a single import may contain.
+	maxChainLength     = 10
+	maxOwnerTeamLength = 64
+	maxSelectorLength  = 1024
+	maxNotesLength     = 4096
+	defaultPageSize    = 100
+	maxPageSize        = 500
+	maxCursorLength    = 1024
+	maxSearchLength    = 256
+	maxSearchTerms     = 16
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// maxSANs bounds the subject alternative names stored per certificate.
+	maxSANs            = 1000
+	maxAlgorithmLength = 64
+	maxKeySize         = 1 << 16
+	maxKeyUsages       = 32
+	// maxPEMLength matches the pem column constraint.
+	maxPEMLength = 65536
+	// defaultExpiredLookback is how long ago a certificate listed as
+	// IncludeExpired may have expired.
+	defaultExpiredLookback = 7 * 24 * time.Hour
+)
+
 type ExpiryOption int
 
 const (
+	// IncludeExpired also lists the certificates that expired within the
+	// look-back, see WithExpiredLookback.
 	IncludeExpired ExpiryOption = iota
 	ExcludeExpired
 )
@@ -24,23 +59,90 @@ const (
 var (
 	ErrInvalidInput     = errors.New("invalid_input")
 	ErrInvalidDateRange = errors.New("invalid date range")
+	ErrImmutableField   = errors.New("immutable_field")
+	ErrForbidden        = errors.New("forbidden")
 )
 
+// CertificatePage is one page of a listing. NextCursor is empty on the last
+// page.
+type CertificatePage struct {
+	Items      []model.Certificate
+	NextCursor string
+}
+
+// cursor is the decoded form of the opaque pagination cursor. It records
+// the sort it was issued for, so it cannot be replayed against another.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	Id    string `json:"i"`
+}
+
+// ImportResult is one certificate of an imported chain. Created is false when
+// the certificate was already known by its fingerprint.
+type ImportResult struct {
+	Certificate model.Certificate
+	Created     bool
+}
+
 type CertificateService interface {
 	Create(ctx context.Context, input dto.CreateCertificateInput) (*model.Certificate, error)
+	CreateFromPEM(ctx context.Context, pemData []byte, ownership dto.Ownership) ([]ImportResult, error)
+	ImportChain(ctx context.Context, chain []*x509.Certificate, ownership dto.Ownership) ([]ImportResult, error)
 	Get(ctx context.Context, id string) (*model.Certificate, error)
-	List(ctx context.Context) ([]model.Certificate, error)
+	GetChain(ctx context.Context, id string) ([]model.Certificate, error)
+	List(ctx context.Context, input dto.ListCertificatesInput) (*CertificatePage, error)
 	ListExpiring(ctx context.Context, window time.Duration, expiryOption ExpiryOption) ([]model.Certificate, error)
+	ListIssuedBy(ctx context.Context, id string) ([]model.Certificate, error)
+	Search(ctx context.Context, query string, limit int) ([]model.SearchResult, error)
+	Summarize(ctx context.Context, input dto.ListCertificatesInput) (*model.CryptoSummary, error)
+	SummarizeExpiry(ctx context.Context, windows []time.Duration) (*model.ExpirySummary, error)
+	Update(ctx context.Context, id string, input dto.UpdateCertificateInput) (*model.Certificate, error)
 	Delete(ctx context.Context, id string) error
 }
 
 type certificateService struct {
-	repo  repository.CertificateRepository
-	clock Clock
+	repo     repository.CertificateRepository
+	clock    Clock
+	findings FindingService
+	audit    repository.AuditRepository
+	// expiredLookback bounds how long ago an expired certificate listed by
+	// ListExpiring may have expired.
+	expiredLookback time.Duration
+}
+
+// Option configures optional behaviour of the certificate service.
+type Option func(*certificateService)
+
+// WithFindings lints every certificate the service creates or updates.
+func WithFindings(findings FindingService) Option {
+	return func(cs *certificateService) {
+		cs.findings = findings
+	}
+}
+
+// WithAudit records the changes the service refuses in the audit trail.
+func WithAudit(audit repository.AuditRepository) Option {
+	return func(cs *certificateService) {
+		cs.audit = audit
+	}
+}
+
+// WithExpiredLookback sets how long ago a certificate ListExpiring includes
+// as expired may have expired, so that enabling alerts on expiry does not
+// raise one for every certificate that expired long ago.
+func WithExpiredLookback(lookback time.Duration) Option {
+	return func(cs *certificateService) {
+		cs.expiredLookback = lookback
+	}
 }
 
-func New(repo repository.CertificateRepository) CertificateService {
-	return &certificateService{repo: repo, clock: NewClock()}
+func New(repo repository.CertificateRepository, opts ...Option) CertificateService {
+	cs := &certificateService{repo: repo, clock: NewClock(), expiredLookback: defaultExpiredLookback}
+	for _, opt := range opts {
+		opt(cs)
+	}
+	return cs
 }
 
 func (cs *certificateService) Create(ctx context.Context, input dto.CreateCertificateInput) (*model.Certificate, error) {
@@ -49,33 +151,145 @@ func (cs *certificateService) Create(ctx context.Context, input dto.CreateCertif
 	if valerr != nil {
 		return nil, ErrInvalidInput
 	}
+	if err := cs.authorize(ctx, model.AuditCertificateCreate, "", strings.TrimSpace(input.OwnerTeam)); err != nil {
+		return nil, err
+	}
+
+	return cs.create(ctx, input)
+}
+
+// create stores a validated certificate the caller may create.
+func (cs *certificateService) create(ctx context.Context, input dto.CreateCertificateInput) (*model.Certificate, error) {
+
+	cert := cs.newCertificate(input)
+	err := cs.repo.Create(ctx, cert)
+	if err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return nil, repository.ErrConflict
+		}
+		return nil, fmt.Errorf("Creating cert: %w", err)
+	}
+
+	if cert.SubjectKeyId != "" || cert.AuthorityKeyId != "" {
+		if err := cs.repo.LinkByKeyId(ctx, cert.Id); err != nil {
+			return nil, fmt.Errorf("Linking cert: %w", err)
+		}
+	}
+	cs.lint(ctx, *cert)
 
-	nb := input.NotBefore.UTC()
-	na := input.NotAfter.UTC()
-	fp := strings.ToLower(input.FingerprintSHA256)
-	id := uuid.NewString()
-	createdAt := cs.clock.Now().UTC()
+	return cert, nil
+}
 
-	cert := model.Certificate{
-		Id:                id,
+// newCertificate builds the certificate to store from a validated input.
+func (cs *certificateService) newCertificate(input dto.CreateCertificateInput) *model.Certificate {
+	cert := &model.Certificate{
+		Id:                uuid.NewString(),
 		CommonName:        input.CommonName,
 		SerialNumber:      input.SerialNumber,
 		Issuer:            input.Issuer,
-		NotBefore:         nb,
-		NotAfter:          na,
-		FingerprintSHA256: fp,
-		CreatedAt:         createdAt,
+		NotBefore:         input.NotBefore.UTC(),
+		NotAfter:          input.NotAfter.UTC(),
+		FingerprintSHA256: strings.ToLower(input.FingerprintSHA256),
+		CreatedAt:         cs.clock.Now().UTC(),
+		SubjectKeyId:      strings.ToLower(input.SubjectKeyId),
+		AuthorityKeyId:    strings.ToLower(input.AuthorityKeyId),
+		OwnerTeam:         strings.TrimSpace(input.OwnerTeam),
+		ContactEmail:      strings.TrimSpace(input.ContactEmail),
+		Description:       input.Description,
+		Labels:            input.Labels,
+		Version:           1,
+		DNSNames:          normalizeDNSNames(input.DNSNames),
+		IPAddresses:       normalizeIPs(input.IPAddresses),
+		URIs:              orEmpty(input.URIs),
+		EmailAddresses:    orEmpty(input.EmailAddresses),
+		PEM:               input.PEM,
+	}
+	setKeyDetails(cert, input.KeyDetails)
+	if cert.Labels == nil {
+		cert.Labels = map[string]string{}
 	}
+	return cert
+}
+
+func (cs *certificateService) CreateFromPEM(ctx context.Context, pemData []byte, ownership dto.Ownership) ([]ImportResult, error) {
 
-	err := cs.repo.Create(ctx, &cert)
+	certs, err := certparse.ParsePEM(pemData)
 	if err != nil {
+		return nil, ErrInvalidInput
+	}
+
+	return cs.ImportChain(ctx, certs, ownership)
+}
+
+// ImportChain stores every certificate of the chain, keeping those already
+// known by their fingerprint, and links each certificate to the chain member
+// that signed it. The chain is stored in one transaction, so it is never
+// stored in part. The ownership applies to the leaf, the first certificate
+// of the chain, when it is newly created.
+func (cs *certificateService) ImportChain(ctx context.Context, chain []*x509.Certificate, ownership dto.Ownership) ([]ImportResult, error) {
+
+	if len(chain) == 0 || len(chain) > maxChainLength {
+		return nil, ErrInvalidInput
+	}
+	if validateOwnership(ownership) != nil {
+		return nil, ErrInvalidInput
+	}
+	members := make([]repository.ChainMember, 0, len(chain))
+	// Only the leaf gets an owner; the issuers are shared. Members already
+	// stored are changed too, e.g. linked to their issuer, so the caller must
+	// be allowed to change those that are owned.
+	owners := []string{strings.TrimSpace(ownership.OwnerTeam)}
+	for i, parsed := range chain {
+		input := certparse.ToCreateInput(parsed)
+		if i == 0 {
+			input.Ownership = ownership
+		}
+		if validateInput(input) != nil {
+			return nil, ErrInvalidInput
+		}
+		member := repository.ChainMember{Certificate: cs.newCertificate(input), Issuer: -1}
+		existing, err := cs.repo.GetByFingerprint(ctx, member.Certificate.FingerprintSHA256)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("Importing chain: %w", err)
+		}
+		if existing != nil {
+			member.Version = existing.Version
+			if existing.OwnerTeam != "" {
+				owners = append(owners, existing.OwnerTeam)
+			}
+		}
+		members = append(members, member)
+	}
+	if err := cs.authorize(ctx, model.AuditCertificateCreate, "", owners...); err != nil {
+		return nil, err
+	}
+	for i, child := range chain {
+		for j, parent := range chain {
+			if i != j && child.CheckSignatureFrom(parent) == nil {
+				members[i].Issuer = j
+				break
+			}
+		}
+	}
+
+	if err := cs.repo.ImportChain(ctx, members); err != nil {
 		if errors.Is(err, repository.ErrConflict) {
 			return nil, repository.ErrConflict
 		}
-		return nil, fmt.Errorf("Creating cert: %w", err)
+		if errors.Is(err, repository.ErrStaleVersion) {
+			return nil, repository.ErrStaleVersion
+		}
+		return nil, fmt.Errorf("Importing chain: %w", err)
 	}
 
-	return &cert, nil
+	results := make([]ImportResult, 0, len(members))
+	for _, member := range members {
+		if member.Created {
+			cs.lint(ctx, *member.Certificate)
+		}
+		results = append(results, ImportResult{Certificate: *member.Certificate, Created: member.Created})
+	}
+	return results, nil
 }
 
 func (cs *certificateService) Get(ctx context.Context, id string) (*model.Certificate, error) {
@@ -93,13 +307,154 @@ func (cs *certificateService) Get(ctx context.Context, id string) (*model.Certif
 	return cert, nil
 }
 
-func (cs *certificateService) List(ctx context.Context) ([]model.Certificate, error) {
-	certs, err := cs.repo.List(ctx)
+func (cs *certificateService) GetChain(ctx context.Context, id string) ([]model.Certificate, error) {
+	if id == "" {
+		return nil, ErrInvalidInput
+	}
+	chain, err := cs.repo.GetChain(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("Getting chain: %w", err)
+	}
+
+	return chain, nil
+}
+
+// List returns one page of certificates matching the filters, sorted by
+// input.Sort and then by id so that the order is stable.
+func (cs *certificateService) List(ctx context.Context, input dto.ListCertificatesInput) (*CertificatePage, error) {
+	if len(input.Cursor) > maxCursorLength || input.Limit < 0 || input.Limit > maxPageSize {
+		return nil, ErrInvalidInput
+	}
+	filter, err := cs.listFilter(input)
+	if err != nil {
+		return nil, err
+	}
+
+	sort := input.Sort
+	if sort == "" {
+		sort = string(repository.SortNotAfter)
+	}
+	field, desc := strings.CutPrefix(sort, "-")
+	if !repository.ValidSortField(repository.SortField(field)) {
+		return nil, ErrInvalidInput
+	}
+	limit := input.Limit
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+	// One extra row tells whether there is a next page.
+	page := repository.Page{Sort: repository.SortField(field), Desc: desc, Limit: limit + 1}
+	if input.Cursor != "" {
+		after, err := decodeCursor(input.Cursor, sort)
+		if err != nil {
+			return nil, ErrInvalidInput
+		}
+		page.After = &after
+	}
+
+	certs, err := cs.repo.List(ctx, filter, page)
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidKeyset) {
+			return nil, ErrInvalidInput
+		}
 		return nil, fmt.Errorf("Getting many certs: %w", err)
 	}
 
-	return certs, nil
+	result := &CertificatePage{Items: certs}
+	if len(certs) > limit {
+		result.Items = certs[:limit]
+		result.NextCursor = encodeCursor(sort, repository.KeysetOf(certs[limit-1], page.Sort))
+	}
+	return result, nil
+}
+
+// Summarize counts the certificates matching the filters of the input by key
+// algorithm and size, and by signature algorithm. Sort and page are ignored.
+func (cs *certificateService) Summarize(ctx context.Context, input dto.ListCertificatesInput) (*model.CryptoSummary, error) {
+	filter, err := cs.listFilter(input)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := cs.repo.Summarize(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("Summarizing certs: %w", err)
+	}
+
+	return summary, nil
+}
+
+// SummarizeExpiry counts the whole inventory by how soon it expires from
+// now: expired, expiring within each window, and by issuer.
+func (cs *certificateService) SummarizeExpiry(ctx context.Context, windows []time.Duration) (*model.ExpirySummary, error) {
+	summary, err := cs.repo.SummarizeExpiry(ctx, cs.clock.Now().UTC(), windows)
+	if err != nil {
+		return nil, fmt.Errorf("Summarizing expiry: %w", err)
+	}
+
+	return summary, nil
+}
+
+// listFilter validates the filters of a listing and resolves the relative
+// ones against the current time.
+func (cs *certificateService) listFilter(input dto.ListCertificatesInput) (repository.CertificateFilter, error) {
+	if len(input.OwnerTeam) > maxOwnerTeamLength || len(input.Issuer) > 255 || len(input.CommonNamePrefix) > 255 {
+		return repository.CertificateFilter{}, ErrInvalidInput
+	}
+	if len(input.Selector) > maxSelectorLength || input.ExpiringWithin < 0 {
+		return repository.CertificateFilter{}, ErrInvalidInput
+	}
+	if len(input.KeyAlgorithm) > maxAlgorithmLength || len(input.SignatureAlgorithm) > maxAlgorithmLength {
+		return repository.CertificateFilter{}, ErrInvalidInput
+	}
+	if len(input.KeyUsage) > maxAlgorithmLength || len(input.ExtKeyUsage) > maxAlgorithmLength {
+		return repository.CertificateFilter{}, ErrInvalidInput
+	}
+	if input.KeySize < 0 || input.KeySize > maxKeySize || input.KeySizeBelow < 0 {
+		return repository.CertificateFilter{}, ErrInvalidInput
+	}
+	selector, err := labels.ParseSelector(input.Selector)
+	if err != nil {
+		return repository.CertificateFilter{}, ErrInvalidInput
+	}
+	dnsName := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(input.DNSName)), ".")
+	if input.DNSName != "" && (!validHostname(dnsName) || strings.Contains(dnsName, ":")) {
+		return repository.CertificateFilter{}, ErrInvalidInput
+	}
+
+	filter := repository.CertificateFilter{
+		OwnerTeam:          strings.TrimSpace(input.OwnerTeam),
+		Selector:           selector,
+		Issuer:             input.Issuer,
+		CommonNamePrefix:   input.CommonNamePrefix,
+		DNSName:            dnsName,
+		NotAfterFrom:       input.NotAfterFrom.UTC(),
+		NotAfterTo:         input.NotAfterTo.UTC(),
+		KeyAlgorithm:       input.KeyAlgorithm,
+		KeySize:            input.KeySize,
+		KeySizeBelow:       input.KeySizeBelow,
+		SignatureAlgorithm: input.SignatureAlgorithm,
+		KeyUsage:           input.KeyUsage,
+		ExtKeyUsage:        input.ExtKeyUsage,
+		IsCA:               input.IsCA,
+	}
+	now := cs.clock.Now().UTC()
+	if input.ExpiringWithin > 0 {
+		before := now.Add(input.ExpiringWithin)
+		if filter.NotAfterTo.IsZero() || before.Before(filter.NotAfterTo) {
+			filter.NotAfterTo = before
+		}
+	}
+	if input.ExcludeExpired && now.After(filter.NotAfterFrom) {
+		filter.NotAfterFrom = now
+	}
+	if !filter.NotAfterFrom.IsZero() && !filter.NotAfterTo.IsZero() && !filter.NotAfterTo.After(filter.NotAfterFrom) {
+		return repository.CertificateFilter{}, ErrInvalidDateRange
+	}
+	return filter, nil
 }
 
 func (cs *certificateService) ListExpiring(ctx context.Context, window time.Duration, expiryOption ExpiryOption) ([]model.Certificate, error) {
@@ -107,14 +462,13 @@ func (cs *certificateService) ListExpiring(ctx context.Context, window time.Dura
 	if window == 0 {
 		return nil, ErrInvalidInput
 	}
-	var now time.Time
+	now := cs.clock.Now().UTC()
+	after := now
 	if expiryOption == IncludeExpired {
-		now = time.Time{}
-	} else {
-		now = cs.clock.Now().UTC()
+		after = now.Add(-cs.expiredLookback)
 	}
 
-	certs, err := cs.repo.ListExpiring(ctx, cs.clock.Now().UTC().Add(window), now)
+	certs, err := cs.repo.ListExpiring(ctx, now.Add(window), after)
 	if err != nil {
 		return nil, fmt.Errorf("Getting expired certs: %w", err)
 	}
@@ -122,15 +476,245 @@ func (cs *certificateService) ListExpiring(ctx context.Context, window time.Dura
 	return certs, nil
 }
 
+func (cs *certificateService) ListIssuedBy(ctx context.Context, id string) ([]model.Certificate, error) {
+	if id == "" {
+		return nil, ErrInvalidInput
+	}
+	// Resolve the issuer first so an unknown id is a 404 rather than an empty list.
+	if _, err := cs.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	certs, err := cs.repo.ListIssuedBy(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("Getting issued certs: %w", err)
+	}
+
+	return certs, nil
+}
+
+// Search finds certificates mentioning every term of the query in their
+// common name, issuer, SANs, owner, notes or description. Terms match as
+// prefixes, so "pay" finds payments-api.
+func (cs *certificateService) Search(ctx context.Context, query string, limit int) ([]model.SearchResult, error) {
+	if len(query) > maxSearchLength || limit < 0 || limit > maxSearchLimit {
+		return nil, ErrInvalidInput
+	}
+	match, ok := ftsQuery(query)
+	if !ok {
+		return nil, ErrInvalidInput
+	}
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	results, err := cs.repo.Search(ctx, match, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Searching certs: %w", err)
+	}
+
+	return results, nil
+}
+
+// ftsQuery turns free text into an FTS5 match expression. Every term becomes
+// a quoted prefix phrase, so the FTS5 query syntax is never exposed to users.
+func ftsQuery(query string) (string, bool) {
+	phrases := []string{}
+	for _, term := range strings.Fields(query) {
+		if strings.IndexFunc(term, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+		if hasControlChars(term) {
+			return "", false
+		}
+		phrases = append(phrases, `"`+strings.ReplaceAll(term, `"`, `""`)+`"*`)
+	}
+	if len(phrases) == 0 || len(phrases) > maxSearchTerms {
+		return "", false
+	}
+	return strings.Join(phrases, " "), true
+}
+
+// Update applies the changed fields to the certificate, provided it is still
+// at input.Version. The fingerprint and key identifiers are the certificate's
+// cryptographic identity and cannot be changed.
+func (cs *certificateService) Update(ctx context.Context, id string, input dto.UpdateCertificateInput) (*model.Certificate, error) {
+	if id == "" {
+		return nil, ErrInvalidInput
+	}
+	cert, err := cs.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkImmutable(cert, input); err != nil {
+		return nil, err
+	}
+	// An editor may neither change another team's certificate nor hand its
+	// own to another team.
+	owners := []string{cert.OwnerTeam}
+	if input.OwnerTeam != nil {
+		owners = append(owners, strings.TrimSpace(*input.OwnerTeam))
+	}
+	if err := cs.authorize(ctx, model.AuditCertificateUpdate, id, owners...); err != nil {
+		return nil, err
+	}
+	if cert.Version != input.Version {
+		return nil, repository.ErrStaleVersion
+	}
+
+	if input.CommonName != nil {
+		cert.CommonName = *input.CommonName
+	}
+	if input.SerialNumber != nil {
+		cert.SerialNumber = *input.SerialNumber
+	}
+	if input.Issuer != nil {
+		cert.Issuer = *input.Issuer
+	}
+	if input.NotBefore != nil {
+		cert.NotBefore = input.NotBefore.UTC()
+	}
+	if input.NotAfter != nil {
+		cert.NotAfter = input.NotAfter.UTC()
+	}
+	if input.OwnerTeam != nil {
+		cert.OwnerTeam = strings.TrimSpace(*input.OwnerTeam)
+	}
+	if input.ContactEmail != nil {
+		cert.ContactEmail = strings.TrimSpace(*input.ContactEmail)
+	}
+	if input.Description != nil {
+		cert.Description = *input.Description
+	}
+	if input.Notes != nil {
+		cert.Notes = *input.Notes
+	}
+	if input.Labels != nil {
+		cert.Labels = input.Labels
+	}
+
+	validation := dto.CreateCertificateInput{
+		CommonName:        cert.CommonName,
+		SerialNumber:      cert.SerialNumber,
+		Issuer:            cert.Issuer,
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+		FingerprintSHA256: cert.FingerprintSHA256,
+		SubjectKeyId:      cert.SubjectKeyId,
+		AuthorityKeyId:    cert.AuthorityKeyId,
+		Ownership:         dto.Ownership{OwnerTeam: cert.OwnerTeam, ContactEmail: cert.ContactEmail, Description: cert.Description},
+		Labels:            cert.Labels,
+	}
+	if err := validateInput(validation); err != nil {
+		return nil, err
+	}
+	if validateText(cert.Notes, maxNotesLength) != nil {
+		return nil, ErrInvalidInput
+	}
+
+	err = cs.repo.Update(ctx, cert)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, repository.ErrNotFound
+		}
+		if errors.Is(err, repository.ErrStaleVersion) {
+			return nil, repository.ErrStaleVersion
+		}
+		return nil, fmt.Errorf("Updating cert: %w", err)
+	}
+	cs.lint(ctx, *cert)
+
+	return cert, nil
+}
+
+// lint stores the findings of the certificate. A failure is not returned, as
+// the certificate itself is stored and the periodic run lints it again.
+func (cs *certificateService) lint(ctx context.Context, cert model.Certificate) {
+	if cs.findings == nil {
+		return
+	}
+	cs.findings.Lint(ctx, cert)
+}
+
+// authorize checks that the caller may make the change to a certificate owned
+// by the owners; see authorize.
+func (cs *certificateService) authorize(ctx context.Context, action string, id string, owners ...string) error {
+	return authorize(ctx, cs.audit, model.AuditEntityCertificate, action, id, owners...)
+}
+
+func encodeCursor(sort string, keyset repository.Keyset) string {
+	data, _ := json.Marshal(cursor{Sort: sort, Value: keyset.Value, Id: keyset.Id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded string, sort string) (repository.Keyset, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return repository.Keyset{}, err
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return repository.Keyset{}, err
+	}
+	if c.Sort != sort || c.Id == "" {
+		return repository.Keyset{}, ErrInvalidInput
+	}
+	return repository.Keyset{Value: c.Value, Id: c.Id}, nil
+}
+
+// checkImmutable rejects attempts to change the cryptographic identity and,
+// for a certificate imported from its PEM, the fields read from it. Repeating
+// the stored value is allowed, so a client may send back a record it has read.
+func checkImmutable(cert *model.Certificate, input dto.UpdateCertificateInput) error {
+	if input.FingerprintSHA256 != nil && !strings.EqualFold(*input.FingerprintSHA256, cert.FingerprintSHA256) {
+		return fmt.Errorf("%w: fingerprint_sha256", ErrImmutableField)
+	}
+	if input.SubjectKeyId != nil && !strings.EqualFold(*input.SubjectKeyId, cert.SubjectKeyId) {
+		return fmt.Errorf("%w: subject_key_id", ErrImmutableField)
+	}
+	if input.AuthorityKeyId != nil && !strings.EqualFold(*input.AuthorityKeyId, cert.AuthorityKeyId) {
+		return fmt.Errorf("%w: authority_key_id", ErrImmutableField)
+	}
+	if cert.PEM == "" {
+		return nil
+	}
+	if input.SerialNumber != nil && !strings.EqualFold(*input.SerialNumber, cert.SerialNumber) {
+		return fmt.Errorf("%w: serial_number", ErrImmutableField)
+	}
+	if input.CommonName != nil && *input.CommonName != cert.CommonName {
+		return fmt.Errorf("%w: common_name", ErrImmutableField)
+	}
+	if input.Issuer != nil && *input.Issuer != cert.Issuer {
+		return fmt.Errorf("%w: issuer", ErrImmutableField)
+	}
+	if input.NotBefore != nil && !input.NotBefore.Equal(cert.NotBefore) {
+		return fmt.Errorf("%w: not_before", ErrImmutableField)
+	}
+	if input.NotAfter != nil && !input.NotAfter.Equal(cert.NotAfter) {
+		return fmt.Errorf("%w: not_after", ErrImmutableField)
+	}
+	return nil
+}
+
 func (cs *certificateService) Delete(ctx context.Context, id string) error {
 	if id == "" {
 		return ErrInvalidInput
 	}
-	err := cs.repo.Delete(ctx, id)
+	cert, err := cs.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := cs.authorize(ctx, model.AuditCertificateDelete, id, cert.OwnerTeam); err != nil {
+		return err
+	}
+	// The version read makes sure the owner just checked is still the owner.
+	err = cs.repo.Delete(ctx, id, cert.Version)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			return repository.ErrNotFound
 		}
+		if errors.Is(err, repository.ErrStaleVersion) {
+			return repository.ErrStaleVersion
+		}
 		return fmt.Errorf("Deleting cert: %w", err)
 	}
 
@@ -153,16 +737,164 @@ func validateInput(input dto.CreateCertificateInput) error {
 	if len(input.FingerprintSHA256) != 64 {
 		return ErrInvalidInput
 	}
+	if len(input.PEM) > maxPEMLength {
+		return ErrInvalidInput
+	}
 	_, hexerr := hex.DecodeString(input.FingerprintSHA256)
 	if hexerr != nil {
 		return ErrInvalidInput
 	}
+	if len(input.SubjectKeyId) > 128 || len(input.AuthorityKeyId) > 128 {
+		return ErrInvalidInput
+	}
+	if _, err := hex.DecodeString(input.SubjectKeyId); err != nil {
+		return ErrInvalidInput
+	}
+	if _, err := hex.DecodeString(input.AuthorityKeyId); err != nil {
+		return ErrInvalidInput
+	}
 	if input.NotBefore.IsZero() || input.NotAfter.IsZero() {
 		return ErrInvalidDateRange
 	}
 	if !input.NotAfter.After(input.NotBefore) {
 		return ErrInvalidDateRange
 	}
+	if err := validateOwnership(input.Ownership); err != nil {
+		return err
+	}
+	if labels.Validate(input.Labels) != nil {
+		return ErrInvalidInput
+	}
+	if err := validateSANs(input); err != nil {
+		return err
+	}
+	if err := validateKeyDetails(input.KeyDetails); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateKeyDetails bounds the key details. They are read from the
+// certificate, so this only guards against malformed input.
+func validateKeyDetails(details dto.KeyDetails) error {
+	if details.KeySize < 0 || details.KeySize > maxKeySize {
+		return ErrInvalidInput
+	}
+	names := []string{details.KeyAlgorithm, details.KeyCurve, details.SignatureAlgorithm}
+	if len(details.KeyUsage) > maxKeyUsages || len(details.ExtKeyUsage) > maxKeyUsages {
+		return ErrInvalidInput
+	}
+	names = append(names, details.KeyUsage...)
+	names = append(names, details.ExtKeyUsage...)
+	for _, name := range names {
+		if len(name) > maxAlgorithmLength || hasControlChars(name) {
+			return ErrInvalidInput
+		}
+	}
+	return nil
+}
+
+func setKeyDetails(cert *model.Certificate, details dto.KeyDetails) {
+	cert.KeyAlgorithm = details.KeyAlgorithm
+	cert.KeySize = details.KeySize
+	cert.KeyCurve = details.KeyCurve
+	cert.SignatureAlgorithm = details.SignatureAlgorithm
+	cert.KeyUsage = orEmpty(details.KeyUsage)
+	cert.ExtKeyUsage = orEmpty(details.ExtKeyUsage)
+	cert.IsCA = details.IsCA
+	cert.SelfSigned = details.SelfSigned
+}
+
+// validateSANs checks the subject alternative names. They are stored as
+// presented, but must be bounded and free of control characters.
+func validateSANs(input dto.CreateCertificateInput) error {
+	total := len(input.DNSNames) + len(input.IPAddresses) + len(input.URIs) + len(input.EmailAddresses)
+	if total > maxSANs {
+		return ErrInvalidInput
+	}
+	for _, name := range input.DNSNames {
+		if name == "" || len(name) > 253 || hasControlChars(name) {
+			return ErrInvalidInput
+		}
+	}
+	for _, ip := range input.IPAddresses {
+		if net.ParseIP(ip) == nil {
+			return ErrInvalidInput
+		}
+	}
+	for _, uri := range input.URIs {
+		if uri == "" || len(uri) > 2048 || hasControlChars(uri) {
+			return ErrInvalidInput
+		}
+	}
+	for _, email := range input.EmailAddresses {
+		if email == "" || len(email) > 254 || hasControlChars(email) {
+			return ErrInvalidInput
+		}
+	}
+	return nil
+}
+
+// normalizeDNSNames lowercases the names and drops a trailing root dot, so
+// that they compare the way DNS does.
+func normalizeDNSNames(names []string) []string {
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		normalized = append(normalized, strings.TrimSuffix(strings.ToLower(name), "."))
+	}
+	return normalized
+}
+
+func normalizeIPs(ips []string) []string {
+	normalized := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		normalized = append(normalized, net.ParseIP(ip).String())
+	}
+	return normalized
+}
+
+func orEmpty(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
 
+// validateOwnership checks the optional ownership fields. The contact must be
+// a bare address, since it is used as an email recipient.
+func validateOwnership(ownership dto.Ownership) error {
+	owner := strings.TrimSpace(ownership.OwnerTeam)
+	contact := strings.TrimSpace(ownership.ContactEmail)
+	if len(owner) > maxOwnerTeamLength || hasControlChars(owner) {
+		return ErrInvalidInput
+	}
+	if len(contact) > 254 {
+		return ErrInvalidInput
+	}
+	if contact != "" {
+		addr, err := mail.ParseAddress(contact)
+		if err != nil || addr.Address != contact || addr.Name != "" {
+			return ErrInvalidInput
+		}
+	}
+	return validateText(ownership.Description, 1024)
+}
+
+// validateText checks free-form text, which may span lines but must not
+// carry other control characters.
+func validateText(text string, maxLength int) error {
+	if len(text) > maxLength {
+		return ErrInvalidInput
+	}
+	for _, r := range text {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return ErrInvalidInput
+		}
+	}
 	return nil
 }
+
+func hasControlChars(s string) bool {
+	return strings.IndexFunc(s, unicode.IsControl) >= 0
+}
@@ -2,14 +2,28 @@ package service
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
 	"errors"
+	"math/big"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hytonhan/certwatch/internal/auth"
+	"github.com/hytonhan/certwatch/internal/certparse"
 	"github.com/hytonhan/certwatch/internal/model"
 	"github.com/hytonhan/certwatch/internal/repository"
 	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+	"github.com/hytonhan/certwatch/internal/tracing/tracingtest"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type FakeCertRepo struct {
@@ -21,19 +35,92 @@ func (fcr FakeCertRepo) Create(ctx context.Context, cert *model.Certificate) err
 
 func (fcr FakeCertRepo) GetByID(ctx context.Context, id string) (*model.Certificate, error) {
 	cert := model.Certificate{
-		Id: id,
+		Id:                id,
+		CommonName:        "SomeName",
+		SerialNumber:      "01",
+		Issuer:            "SomeIssuer",
+		NotBefore:         time.Now().Add(-time.Hour),
+		NotAfter:          time.Now().Add(time.Hour),
+		FingerprintSHA256: "bde4918f9e08256c787948908be7f5c1ebeead20ab4f596ecfccb62325009b22",
+		OwnerTeam:         "payments",
+		Version:           1,
 	}
-	if id == "id1" {
+	switch id {
+	case "id1":
+		return &cert, nil
+	case "imported":
+		cert.PEM = "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"
 		return &cert, nil
 	}
 	return nil, repository.ErrNotFound
 }
 
-func (fcr FakeCertRepo) List(ctx context.Context) ([]model.Certificate, error) {
+func (fcr FakeCertRepo) GetByFingerprint(ctx context.Context, fingerprint string) (*model.Certificate, error) {
+	return nil, repository.ErrNotFound
+}
+
+func (fcr FakeCertRepo) ImportChain(ctx context.Context, members []repository.ChainMember) error {
+	for i := range members {
+		members[i].Created = true
+		if members[i].Issuer >= 0 {
+			members[i].Certificate.IssuerId = members[members[i].Issuer].Certificate.Id
+		}
+	}
+	return nil
+}
+
+func (fcr FakeCertRepo) LinkByKeyId(ctx context.Context, id string) error {
+	return nil
+}
+
+func (fcr FakeCertRepo) GetChain(ctx context.Context, id string) ([]model.Certificate, error) {
+	if id == "id1" {
+		return []model.Certificate{{Id: id}}, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (fcr FakeCertRepo) ListIssuedBy(ctx context.Context, issuerID string) ([]model.Certificate, error) {
 	return []model.Certificate{}, nil
 }
 
-func (fcr FakeCertRepo) Delete(ctx context.Context, id string) error {
+func (fcr FakeCertRepo) List(ctx context.Context, filter repository.CertificateFilter, page repository.Page) ([]model.Certificate, error) {
+	now := time.Now()
+	certs := []model.Certificate{
+		{Id: "id1", NotAfter: now.Add(time.Hour)},
+		{Id: "id2", NotAfter: now.Add(2 * time.Hour)},
+		{Id: "id3", NotAfter: now.Add(3 * time.Hour), PEM: "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"},
+	}
+	if page.After != nil {
+		certs = certs[2:]
+	}
+	return certs[:min(len(certs), page.Limit)], nil
+}
+
+func (fcr FakeCertRepo) Search(ctx context.Context, match string, limit int) ([]model.SearchResult, error) {
+	return []model.SearchResult{}, nil
+}
+
+func (fcr FakeCertRepo) Summarize(ctx context.Context, filter repository.CertificateFilter) (*model.CryptoSummary, error) {
+	return &model.CryptoSummary{
+		Total: 3,
+		Keys:  []model.KeySummary{{KeyAlgorithm: "ECDSA", KeySize: 256, KeyCurve: "P-256", Count: 2}, {KeyAlgorithm: "RSA", KeySize: 1024, Count: 1}},
+	}, nil
+}
+
+func (fcr FakeCertRepo) SummarizeExpiry(ctx context.Context, now time.Time, windows []time.Duration) (*model.ExpirySummary, error) {
+	return &model.ExpirySummary{ExpiringWithin: map[time.Duration]int{}}, nil
+}
+
+func (fcr FakeCertRepo) Update(ctx context.Context, cert *model.Certificate) error {
+	if cert.Id == "id1" || cert.Id == "imported" {
+		cert.Version++
+		return nil
+	}
+	return repository.ErrNotFound
+}
+
+func (fcr FakeCertRepo) Delete(ctx context.Context, id string, version int64) error {
 	if id == "id1" {
 		return nil
 	}
@@ -44,6 +131,42 @@ func (fcr FakeCertRepo) ListExpiring(ctx context.Context, before time.Time, now
 	return []model.Certificate{}, nil
 }
 
+// expiringRepo records the bounds ListExpiring is called with.
+type expiringRepo struct {
+	FakeCertRepo
+	before time.Time
+	after  time.Time
+}
+
+func (er *expiringRepo) ListExpiring(ctx context.Context, before time.Time, after time.Time) ([]model.Certificate, error) {
+	er.before, er.after = before, after
+	return []model.Certificate{}, nil
+}
+
+func TestListExpiringLookback(t *testing.T) {
+	tests := []struct {
+		name     string
+		option   ExpiryOption
+		lookback time.Duration
+	}{
+		{"exclude expired", ExcludeExpired, 0},
+		{"include expired", IncludeExpired, 48 * time.Hour},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := &expiringRepo{}
+			srv := New(repo, WithExpiredLookback(48*time.Hour))
+
+			if _, err := srv.ListExpiring(context.Background(), time.Hour, test.option); err != nil {
+				t.Fatal(err)
+			}
+			if got := repo.before.Sub(repo.after); got != time.Hour+test.lookback {
+				t.Errorf("ListExpiring() spans %v; want %v", got, time.Hour+test.lookback)
+			}
+		})
+	}
+}
+
 func TestCreate(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -62,17 +185,26 @@ func TestCreate(t *testing.T) {
 		{"empty iss", dto.CreateCertificateInput{CommonName: "test", SerialNumber: "test", FingerprintSHA256: "test"}, ErrInvalidInput},
 		{"empty fingerprint", dto.CreateCertificateInput{CommonName: "test", SerialNumber: "test", Issuer: "test"}, ErrInvalidInput},
 		{"empty not before", dto.CreateCertificateInput{CommonName: "test", SerialNumber: "test", Issuer: "", FingerprintSHA256: "bde4918f9e08256c787948908be7f5c1ebeead20ab4f596ecfccb62325009b22", NotBefore: time.Time{}}, ErrInvalidInput},
+		{"invalid contact email", withOwnership(createInput("", "", "", time.Time{}, time.Time{}, ""), dto.Ownership{ContactEmail: "Ops <ops@example.com>"}), ErrInvalidInput},
+		{"owner with control chars", withOwnership(createInput("", "", "", time.Time{}, time.Time{}, ""), dto.Ownership{OwnerTeam: "team\nforged"}), ErrInvalidInput},
+		{"valid ownership", withOwnership(createInput("", "", "", time.Time{}, time.Time{}, ""), dto.Ownership{OwnerTeam: "payments", ContactEmail: "ops@example.com", Description: "Payments API\nrenewed by ACME"}), nil},
+		{"valid labels", withLabels(createInput("", "", "", time.Time{}, time.Time{}, ""), map[string]string{"env": "prod", "example.com/app": "payments"}), nil},
+		{"invalid label key", withLabels(createInput("", "", "", time.Time{}, time.Time{}, ""), map[string]string{"env prod": "x"}), ErrInvalidInput},
+		{"invalid label value", withLabels(createInput("", "", "", time.Time{}, time.Time{}, ""), map[string]string{"env": "pr'od"}), ErrInvalidInput},
+		{"valid sans", withSANs(createInput("", "", "", time.Time{}, time.Time{}, ""), []string{"api.example.com", "*.example.com"}, []string{"10.0.0.1", "::1"}), nil},
+		{"invalid dns san", withSANs(createInput("", "", "", time.Time{}, time.Time{}, ""), []string{"api\nexample.com"}, nil), ErrInvalidInput},
+		{"invalid ip san", withSANs(createInput("", "", "", time.Time{}, time.Time{}, ""), nil, []string{"10.0.0.256"}), ErrInvalidInput},
 		{"empty not after", dto.CreateCertificateInput{CommonName: "test", SerialNumber: "test", Issuer: "", FingerprintSHA256: "bde4918f9e08256c787948908be7f5c1ebeead20ab4f596ecfccb62325009b22", NotBefore: time.Now(), NotAfter: time.Time{}}, ErrInvalidInput},
 	}
 	repo := FakeCertRepo{}
 	srv := New(repo)
-	ctx := context.Background()
+	ctx := systemContext()
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			cert, err := srv.Create(ctx, test.input)
 			if !errors.Is(err, test.expected) {
-				t.Errorf("Create(%q) = %v; want %v", test.input, cert, test.expected)
+				t.Errorf("Create(%v) = %v; want %v", test.input, cert, test.expected)
 			}
 			if err != nil {
 				return
@@ -81,25 +213,28 @@ func TestCreate(t *testing.T) {
 				t.Errorf("invalid uuid: %v", cert.Id)
 			}
 			if test.input.CommonName != cert.CommonName {
-				t.Errorf("Create(%q) = %v; want %v", test.input, cert.CommonName, test.input.CommonName)
+				t.Errorf("Create(%v) = %v; want %v", test.input, cert.CommonName, test.input.CommonName)
 			}
 			if test.input.SerialNumber != cert.SerialNumber {
-				t.Errorf("Create(%q) = %v; want %v", test.input, cert.SerialNumber, test.input.SerialNumber)
+				t.Errorf("Create(%v) = %v; want %v", test.input, cert.SerialNumber, test.input.SerialNumber)
 			}
 			if test.input.Issuer != cert.Issuer {
-				t.Errorf("Create(%q) = %v; want %v", test.input, cert.Issuer, test.input.Issuer)
+				t.Errorf("Create(%v) = %v; want %v", test.input, cert.Issuer, test.input.Issuer)
 			}
 			if test.input.NotBefore.UTC() != cert.NotBefore {
-				t.Errorf("Create(%q) = %v; want %v", test.input, cert.NotBefore.UTC(), test.input.NotBefore)
+				t.Errorf("Create(%v) = %v; want %v", test.input, cert.NotBefore.UTC(), test.input.NotBefore)
 			}
 			if test.input.NotAfter.UTC() != cert.NotAfter {
-				t.Errorf("Create(%q) = %v; want %v", test.input, cert.NotAfter.UTC(), test.input.NotAfter)
+				t.Errorf("Create(%v) = %v; want %v", test.input, cert.NotAfter.UTC(), test.input.NotAfter)
 			}
 			if test.input.FingerprintSHA256 != cert.FingerprintSHA256 {
-				t.Errorf("Create(%q) = %v; want %v", test.input, cert.FingerprintSHA256, test.input.FingerprintSHA256)
+				t.Errorf("Create(%v) = %v; want %v", test.input, cert.FingerprintSHA256, test.input.FingerprintSHA256)
+			}
+			if test.input.OwnerTeam != cert.OwnerTeam || test.input.ContactEmail != cert.ContactEmail || test.input.Description != cert.Description {
+				t.Errorf("Create(%v) = %v; want %v", test.input, cert.OwnerTeam, test.input.OwnerTeam)
 			}
 			if cert.CreatedAt.IsZero() {
-				t.Errorf("Create(%q) = %v; want %v", test.input, cert.CreatedAt, "\"Valid time\"")
+				t.Errorf("Create(%v) = %v; want %v", test.input, cert.CreatedAt, "\"Valid time\"")
 			}
 		})
 	}
@@ -117,7 +252,7 @@ func TestGet(t *testing.T) {
 	}
 	repo := FakeCertRepo{}
 	srv := New(repo)
-	ctx := context.Background()
+	ctx := systemContext()
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -130,24 +265,170 @@ func TestGet(t *testing.T) {
 }
 
 func TestList(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		input      dto.ListCertificatesInput
+		items      int
+		nextCursor bool
+		expected   error
+	}{
+		{"valid input", dto.ListCertificatesInput{}, 3, false, nil},
+		{"valid selector", dto.ListCertificatesInput{Selector: "env=prod,app!=legacy"}, 3, false, nil},
+		{"invalid selector", dto.ListCertificatesInput{Selector: "env=prod' OR 1=1"}, 0, false, ErrInvalidInput},
+		{"limit", dto.ListCertificatesInput{Limit: 2}, 2, true, nil},
+		{"limit too large", dto.ListCertificatesInput{Limit: maxPageSize + 1}, 0, false, ErrInvalidInput},
+		{"descending sort", dto.ListCertificatesInput{Sort: "-common_name", Limit: 1}, 1, true, nil},
+		{"unknown sort", dto.ListCertificatesInput{Sort: "fingerprint_sha256"}, 0, false, ErrInvalidInput},
+		{"sql in sort", dto.ListCertificatesInput{Sort: "not_after; DROP TABLE certificates"}, 0, false, ErrInvalidInput},
+		{"invalid cursor", dto.ListCertificatesInput{Cursor: "not a cursor"}, 0, false, ErrInvalidInput},
+		{"inverted range", dto.ListCertificatesInput{NotAfterFrom: now, NotAfterTo: now.Add(-time.Hour)}, 0, false, ErrInvalidDateRange},
+		{"expiring within", dto.ListCertificatesInput{ExpiringWithin: time.Hour, ExcludeExpired: true}, 3, false, nil},
+		{"dns name", dto.ListCertificatesInput{DNSName: "API.example.com."}, 3, false, nil},
+		{"invalid dns name", dto.ListCertificatesInput{DNSName: "bad name"}, 0, false, ErrInvalidInput},
+		{"key filters", dto.ListCertificatesInput{KeyAlgorithm: "RSA", KeySizeBelow: 2048, KeyUsage: "keyEncipherment"}, 3, false, nil},
+		{"negative key size", dto.ListCertificatesInput{KeySize: -1}, 0, false, ErrInvalidInput},
+	}
+	repo := FakeCertRepo{}
+	srv := New(repo)
+	ctx := systemContext()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := srv.List(ctx, test.input)
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("List() = %v; want %v", err, test.expected)
+			}
+			if err != nil {
+				return
+			}
+			if len(page.Items) != test.items {
+				t.Errorf("List() returned %d items; want %d", len(page.Items), test.items)
+			}
+			if (page.NextCursor != "") != test.nextCursor {
+				t.Errorf("List() NextCursor = %q; want cursor %v", page.NextCursor, test.nextCursor)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	srv := New(FakeCertRepo{})
+	ctx := systemContext()
+
+	summary, err := srv.Summarize(ctx, dto.ListCertificatesInput{KeyAlgorithm: "RSA"})
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if summary.Total != 3 || len(summary.Keys) != 2 {
+		t.Errorf("Summarize() = %+v; want 3 certs in 2 groups", summary)
+	}
+	if _, err := srv.Summarize(ctx, dto.ListCertificatesInput{Selector: "env=prod' OR 1=1"}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Summarize() error = %v; want %v", err, ErrInvalidInput)
+	}
+}
+
+func TestListCursor(t *testing.T) {
+	srv := New(FakeCertRepo{})
+	ctx := systemContext()
+
+	first, err := srv.List(ctx, dto.ListCertificatesInput{Limit: 2})
+	if err != nil || first.NextCursor == "" {
+		t.Fatalf("List() = %v, %v; want a next cursor", first, err)
+	}
+	second, err := srv.List(ctx, dto.ListCertificatesInput{Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("List(cursor) = %v", err)
+	}
+	if len(second.Items) != 1 || second.Items[0].Id != "id3" || second.NextCursor != "" {
+		t.Errorf("List(cursor) = %v; want the last item only", second)
+	}
+
+	// A cursor is bound to the sort it was issued for.
+	_, err = srv.List(ctx, dto.ListCertificatesInput{Limit: 2, Sort: "-not_after", Cursor: first.NextCursor})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("List(cursor, other sort) = %v; want %v", err, ErrInvalidInput)
+	}
+}
+
+func TestFTSQuery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		ok       bool
+	}{
+		{"payments-api", `"payments-api"*`, true},
+		{"payments  team", `"payments"* "team"*`, true},
+		{`say "hi" OR NOT x*`, `"say"* """hi"""* "OR"* "NOT"* "x*"*`, true},
+		{"- * ()", "", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			match, ok := ftsQuery(test.input)
+			if ok != test.ok || match != test.expected {
+				t.Errorf("ftsQuery(%q) = %q, %v; want %q, %v", test.input, match, ok, test.expected, test.ok)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	team := "payments"
+	badEmail := "not an email"
+	notes := "Renewed manually\nby the payments team"
+	sameFingerprint := "BDE4918F9E08256C787948908BE7F5C1EBEEAD20AB4F596ECFCCB62325009B22"
+	otherFingerprint := "0000000000000000000000000000000000000000000000000000000000000000"
+	otherKeyId := "abcd"
+	otherSerial := "02"
+	sameIssuer := "SomeIssuer"
+	otherName := "other.example.com"
+	past := time.Now().Add(-2 * time.Hour)
+	later := time.Now().Add(2 * time.Hour)
 	tests := []struct {
 		name     string
+		id       string
+		input    dto.UpdateCertificateInput
 		expected error
 	}{
-		{"valid input", nil},
+		{"valid input", "id1", dto.UpdateCertificateInput{Version: 1, OwnerTeam: &team}, nil},
+		{"empty id", "", dto.UpdateCertificateInput{Version: 1, OwnerTeam: &team}, ErrInvalidInput},
+		{"invalid email", "id1", dto.UpdateCertificateInput{Version: 1, ContactEmail: &badEmail}, ErrInvalidInput},
+		{"labels", "id1", dto.UpdateCertificateInput{Version: 1, OwnerTeam: &team, Labels: map[string]string{"env": "prod"}}, nil},
+		{"invalid labels", "id1", dto.UpdateCertificateInput{Version: 1, Labels: map[string]string{"-env": "prod"}}, ErrInvalidInput},
+		{"notes", "id1", dto.UpdateCertificateInput{Version: 1, OwnerTeam: &team, Notes: &notes}, nil},
+		{"not after before not before", "id1", dto.UpdateCertificateInput{Version: 1, NotAfter: &past}, ErrInvalidDateRange},
+		{"stale version", "id1", dto.UpdateCertificateInput{Version: 2, OwnerTeam: &team}, repository.ErrStaleVersion},
+		{"unchanged fingerprint", "id1", dto.UpdateCertificateInput{Version: 1, OwnerTeam: &team, FingerprintSHA256: &sameFingerprint}, nil},
+		{"changed fingerprint", "id1", dto.UpdateCertificateInput{Version: 1, FingerprintSHA256: &otherFingerprint}, ErrImmutableField},
+		{"changed subject key id", "id1", dto.UpdateCertificateInput{Version: 1, SubjectKeyId: &otherKeyId}, ErrImmutableField},
+		{"changed serial", "id1", dto.UpdateCertificateInput{Version: 1, OwnerTeam: &team, SerialNumber: &otherSerial}, nil},
+		{"changed serial of imported", "imported", dto.UpdateCertificateInput{Version: 1, SerialNumber: &otherSerial}, ErrImmutableField},
+		{"changed common name of imported", "imported", dto.UpdateCertificateInput{Version: 1, CommonName: &otherName}, ErrImmutableField},
+		{"changed common name", "id1", dto.UpdateCertificateInput{Version: 1, OwnerTeam: &team, CommonName: &otherName}, nil},
+		{"changed not after of imported", "imported", dto.UpdateCertificateInput{Version: 1, NotAfter: &later}, ErrImmutableField},
+		{"unchanged issuer of imported", "imported", dto.UpdateCertificateInput{Version: 1, OwnerTeam: &team, Issuer: &sameIssuer}, nil},
+		{"ErrNotFound bubbles", "doesn't exists", dto.UpdateCertificateInput{Version: 1, OwnerTeam: &team}, repository.ErrNotFound},
 	}
 	repo := FakeCertRepo{}
 	srv := New(repo)
-	ctx := context.Background()
+	ctx := systemContext()
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			cert, err := srv.List(ctx)
+			cert, err := srv.Update(ctx, test.id, test.input)
 			if !errors.Is(err, test.expected) {
-				t.Errorf("List(); want %v", test.expected)
+				t.Errorf("Update(%q) = %v; want %v", test.id, err, test.expected)
+			}
+			if err != nil {
+				return
 			}
-			if len(cert) != 0 {
-				t.Errorf("List(); want %v", "len(cert) != 0")
+			if cert.OwnerTeam != team {
+				t.Errorf("Update(%q) OwnerTeam = %v; want %v", test.id, cert.OwnerTeam, team)
+			}
+			if cert.Version != test.input.Version+1 {
+				t.Errorf("Update(%q) Version = %v; want %v", test.id, cert.Version, test.input.Version+1)
 			}
 		})
 	}
@@ -165,7 +446,7 @@ func TestDelete(t *testing.T) {
 	}
 	repo := FakeCertRepo{}
 	srv := New(repo)
-	ctx := context.Background()
+	ctx := systemContext()
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -177,6 +458,160 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestAuthorize(t *testing.T) {
+	admin := &auth.Principal{Kind: auth.KindAPIKey, Name: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}}
+	editor := &auth.Principal{Kind: auth.KindAPIKey, Name: "payments", Scopes: []auth.Scope{auth.ScopeCertsRead, auth.ScopeCertsWrite}, Team: "payments"}
+	otherEditor := &auth.Principal{Kind: auth.KindAPIKey, Name: "billing", Scopes: []auth.Scope{auth.ScopeCertsWrite}, Team: "billing"}
+	viewer := &auth.Principal{Kind: auth.KindOIDC, Name: "alice", Scopes: []auth.Scope{auth.ScopeCertsRead}, Team: "payments"}
+	owned := withOwnership(createInput("", "", "", time.Time{}, time.Time{}, ""), dto.Ownership{OwnerTeam: "payments"})
+	handOver := "billing"
+	leaf, _ := newTestCert(t, "pem.example.com", nil, nil)
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		change    func(ctx context.Context, srv CertificateService) error
+		expected  error
+	}{
+		{"system creates", auth.System("scanner"), func(ctx context.Context, srv CertificateService) error {
+			_, err := srv.Create(ctx, owned)
+			return err
+		}, nil},
+		{"unauthenticated creates", nil, func(ctx context.Context, srv CertificateService) error {
+			_, err := srv.Create(ctx, owned)
+			return err
+		}, ErrForbidden},
+		{"admin deletes", admin, func(ctx context.Context, srv CertificateService) error {
+			return srv.Delete(ctx, "id1")
+		}, nil},
+		{"editor creates for its team", editor, func(ctx context.Context, srv CertificateService) error {
+			_, err := srv.Create(ctx, owned)
+			return err
+		}, nil},
+		{"editor creates unowned", editor, func(ctx context.Context, srv CertificateService) error {
+			_, err := srv.Create(ctx, createInput("", "", "", time.Time{}, time.Time{}, ""))
+			return err
+		}, ErrForbidden},
+		{"editor updates its team's", editor, func(ctx context.Context, srv CertificateService) error {
+			_, err := srv.Update(ctx, "id1", dto.UpdateCertificateInput{Version: 1})
+			return err
+		}, nil},
+		{"editor hands over", editor, func(ctx context.Context, srv CertificateService) error {
+			_, err := srv.Update(ctx, "id1", dto.UpdateCertificateInput{Version: 1, OwnerTeam: &handOver})
+			return err
+		}, ErrForbidden},
+		{"editor of other team updates", otherEditor, func(ctx context.Context, srv CertificateService) error {
+			_, err := srv.Update(ctx, "id1", dto.UpdateCertificateInput{Version: 1})
+			return err
+		}, ErrForbidden},
+		{"editor of other team deletes", otherEditor, func(ctx context.Context, srv CertificateService) error {
+			return srv.Delete(ctx, "id1")
+		}, ErrForbidden},
+		{"viewer imports", viewer, func(ctx context.Context, srv CertificateService) error {
+			_, err := srv.CreateFromPEM(ctx, toPEM(leaf), dto.Ownership{OwnerTeam: "payments"})
+			return err
+		}, ErrForbidden},
+		{"viewer deletes", viewer, func(ctx context.Context, srv CertificateService) error {
+			return srv.Delete(ctx, "id1")
+		}, ErrForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			audit := &FakeAuditRepo{}
+			srv := New(FakeCertRepo{}, WithAudit(audit))
+			ctx := context.Background()
+			if test.principal != nil {
+				ctx = auth.WithPrincipal(ctx, test.principal)
+			}
+
+			err := test.change(ctx, srv)
+			if !errors.Is(err, test.expected) {
+				t.Errorf("change error = %v; want %v", err, test.expected)
+			}
+			denied := errors.Is(test.expected, ErrForbidden)
+			if denied != (len(audit.denials) == 1) {
+				t.Errorf("denials = %+v; want one only if forbidden", audit.denials)
+			}
+		})
+	}
+}
+
+// storedChainRepo has some certificates of a chain stored already.
+type storedChainRepo struct {
+	FakeCertRepo
+	stored  map[string]*model.Certificate
+	members []repository.ChainMember
+}
+
+func (sr *storedChainRepo) GetByFingerprint(ctx context.Context, fingerprint string) (*model.Certificate, error) {
+	if cert, ok := sr.stored[fingerprint]; ok {
+		return cert, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (sr *storedChainRepo) ImportChain(ctx context.Context, members []repository.ChainMember) error {
+	sr.members = members
+	return sr.FakeCertRepo.ImportChain(ctx, members)
+}
+
+func TestImportChainChecksStoredOwners(t *testing.T) {
+	root, rootKey := newTestCert(t, "Test Root", nil, nil)
+	leaf, _ := newTestCert(t, "pem.example.com", root, rootKey)
+	chain := append(toPEM(leaf), toPEM(root)...)
+	editor := &auth.Principal{Kind: auth.KindAPIKey, Name: "payments", Scopes: []auth.Scope{auth.ScopeCertsWrite}, Team: "payments"}
+	admin := &auth.Principal{Kind: auth.KindAPIKey, Name: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}}
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		rootOwner string
+		expected  error
+	}{
+		{"shared issuer", editor, "", nil},
+		{"issuer of other team", editor, "billing", ErrForbidden},
+		{"admin", admin, "billing", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stored := &model.Certificate{Id: "root", FingerprintSHA256: certparse.Fingerprint(root), OwnerTeam: test.rootOwner, Version: 3}
+			repo := &storedChainRepo{stored: map[string]*model.Certificate{stored.FingerprintSHA256: stored}}
+			srv := New(repo, WithAudit(&FakeAuditRepo{}))
+			ctx := auth.WithPrincipal(context.Background(), test.principal)
+
+			_, err := srv.CreateFromPEM(ctx, chain, dto.Ownership{OwnerTeam: "payments"})
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("CreateFromPEM() error = %v; want %v", err, test.expected)
+			}
+			if err == nil && (repo.members[0].Version != 0 || repo.members[1].Version != 3) {
+				t.Errorf("CreateFromPEM() versions = %d, %d; want 0 for the new leaf and 3 for the stored root", repo.members[0].Version, repo.members[1].Version)
+			}
+		})
+	}
+}
+
+// systemContext is the context of the server's own work, which may change
+// every certificate.
+func systemContext() context.Context {
+	return auth.WithPrincipal(context.Background(), auth.System("test"))
+}
+
+func withLabels(input dto.CreateCertificateInput, labels map[string]string) dto.CreateCertificateInput {
+	input.Labels = labels
+	return input
+}
+
+func withSANs(input dto.CreateCertificateInput, dnsNames, ipAddresses []string) dto.CreateCertificateInput {
+	input.DNSNames = dnsNames
+	input.IPAddresses = ipAddresses
+	return input
+}
+
+func withOwnership(input dto.CreateCertificateInput, ownership dto.Ownership) dto.CreateCertificateInput {
+	input.Ownership = ownership
+	return input
+}
+
 func createInput(
 	commonName string,
 	serial string,
@@ -213,3 +648,187 @@ func createInput(
 		FingerprintSHA256: fingerprint,
 	}
 }
+
+func TestCreateFromPEM(t *testing.T) {
+	root, rootKey := newTestCert(t, "Test Root", nil, nil)
+	leaf, _ := newTestCert(t, "pem.example.com", root, rootKey)
+	tests := []struct {
+		name     string
+		input    []byte
+		count    int
+		expected error
+	}{
+		{"valid certificate", toPEM(leaf), 1, nil},
+		{"valid chain", append(toPEM(leaf), toPEM(root)...), 2, nil},
+		{"not pem", []byte("not a certificate"), 0, ErrInvalidInput},
+		{"private key rejected", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1, 2, 3}}), 0, ErrInvalidInput},
+		{"garbage der", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1, 2, 3}}), 0, ErrInvalidInput},
+	}
+	repo := FakeCertRepo{}
+	srv := New(repo)
+	ctx := systemContext()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results, err := srv.CreateFromPEM(ctx, test.input, dto.Ownership{})
+			if !errors.Is(err, test.expected) {
+				t.Errorf("CreateFromPEM() = %v; want %v", err, test.expected)
+			}
+			if err != nil {
+				return
+			}
+			if len(results) != test.count {
+				t.Fatalf("CreateFromPEM() returned %d certs; want %d", len(results), test.count)
+			}
+			cert := results[0].Certificate
+			if cert.CommonName != "pem.example.com" {
+				t.Errorf("CreateFromPEM() CommonName = %v; want %v", cert.CommonName, "pem.example.com")
+			}
+			sum := sha256.Sum256(leaf.Raw)
+			if cert.FingerprintSHA256 != hex.EncodeToString(sum[:]) {
+				t.Errorf("CreateFromPEM() FingerprintSHA256 = %v; want %v", cert.FingerprintSHA256, hex.EncodeToString(sum[:]))
+			}
+			if test.count == 2 && cert.IssuerId != results[1].Certificate.Id {
+				t.Errorf("CreateFromPEM() IssuerId = %v; want %v", cert.IssuerId, results[1].Certificate.Id)
+			}
+			if !slices.Equal(cert.DNSNames, []string{"pem.example.com"}) {
+				t.Errorf("CreateFromPEM() DNSNames = %v; want %v", cert.DNSNames, []string{"pem.example.com"})
+			}
+			if cert.KeyAlgorithm != "ECDSA" || cert.KeySize != 256 || cert.KeyCurve != "P-256" || cert.SignatureAlgorithm != "ECDSA-SHA256" {
+				t.Errorf("CreateFromPEM() key = %v %v %v %v; want ECDSA 256 P-256 ECDSA-SHA256", cert.KeyAlgorithm, cert.KeySize, cert.KeyCurve, cert.SignatureAlgorithm)
+			}
+			if !slices.Equal(cert.KeyUsage, []string{"digitalSignature", "keyCertSign"}) || cert.IsCA {
+				t.Errorf("CreateFromPEM() KeyUsage = %v, IsCA = %v; want [digitalSignature keyCertSign], false", cert.KeyUsage, cert.IsCA)
+			}
+			if test.count == 2 && (!results[1].Certificate.IsCA || !results[1].Certificate.SelfSigned) {
+				t.Errorf("CreateFromPEM() root IsCA, SelfSigned = %v, %v; want true, true", results[1].Certificate.IsCA, results[1].Certificate.SelfSigned)
+			}
+			if cert.SelfSigned {
+				t.Errorf("CreateFromPEM() leaf SelfSigned = true; want false")
+			}
+			if results[len(results)-1].Certificate.IssuerId != "" {
+				t.Errorf("CreateFromPEM() root IssuerId = %v; want empty", results[len(results)-1].Certificate.IssuerId)
+			}
+		})
+	}
+}
+
+func TestCreateFromPEMLongName(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without a common name the distinguished name is used, which is longer
+	// than a stored name may be.
+	subject := pkix.Name{Organization: []string{"Example"}}
+	for i := 0; i < 30; i++ {
+		subject.OrganizationalUnit = append(subject.OrganizationalUnit, "Department of Long Names")
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := New(FakeCertRepo{}).CreateFromPEM(systemContext(), toPEM(cert), dto.Ownership{})
+	if err != nil {
+		t.Fatalf("CreateFromPEM() error = %v", err)
+	}
+	if name := results[0].Certificate.CommonName; name == "" || len(name) > 255 {
+		t.Errorf("CreateFromPEM() CommonName has length %d; want 1 to 255", len(name))
+	}
+}
+
+func TestGetChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected error
+	}{
+		{"valid input", "id1", nil},
+		{"empty input", "", ErrInvalidInput},
+		{"ErrNotFound bubbles", "doesn't exists", repository.ErrNotFound},
+	}
+	repo := FakeCertRepo{}
+	srv := New(repo)
+	ctx := systemContext()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chain, err := srv.GetChain(ctx, test.input)
+			if !errors.Is(err, test.expected) {
+				t.Errorf("GetChain(%q) = %v; want %v", test.input, chain, test.expected)
+			}
+		})
+	}
+}
+
+// newTestCert creates a certificate signed by parent, or a self-signed CA
+// when parent is nil.
+func newTestCert(t *testing.T, commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour * 24),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if parent != nil {
+		template.DNSNames = []string{commonName}
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func toPEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+func TestTraced(t *testing.T) {
+	spans := tracingtest.Record(t)
+	cs := Traced(New(FakeCertRepo{}))
+
+	if _, err := cs.Get(context.Background(), "id1"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if _, err := cs.Get(context.Background(), "missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("Get() error = %v; want %v", err, repository.ErrNotFound)
+	}
+
+	got := tracingtest.Named(spans, "CertificateService.Get")
+	if len(got) != 2 {
+		t.Fatalf("recorded %d CertificateService.Get spans; want 2", len(got))
+	}
+	if got[0].Status.Code != codes.Unset || got[1].Status.Code != codes.Error {
+		t.Errorf("span statuses = %v, %v; want unset, then error", got[0].Status.Code, got[1].Status.Code)
+	}
+	if len(got[1].Events) == 0 || got[1].Events[0].Name != "exception" {
+		t.Errorf("failed span has no recorded error")
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"crypto/x509"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/model"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+	"github.com/hytonhan/certwatch/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedCertificateService spans every call of the certificate service.
+type tracedCertificateService struct {
+	next CertificateService
+}
+
+// Traced returns the service with a span for every call, named e.g.
+// "CertificateService.Get", that fails with the call.
+func Traced(next CertificateService) CertificateService {
+	return &tracedCertificateService{next: next}
+}
+
+func startCall(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "CertificateService."+method, trace.WithAttributes(attrs...))
+}
+
+func (t *tracedCertificateService) Create(ctx context.Context, input dto.CreateCertificateInput) (*model.Certificate, error) {
+	ctx, span := startCall(ctx, "Create")
+	result, err := t.next.Create(ctx, input)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedCertificateService) CreateFromPEM(ctx context.Context, pemData []byte, ownership dto.Ownership) ([]ImportResult, error) {
+	ctx, span := startCall(ctx, "CreateFromPEM")
+	result, err := t.next.CreateFromPEM(ctx, pemData, ownership)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedCertificateService) ImportChain(ctx context.Context, chain []*x509.Certificate, ownership dto.Ownership) ([]ImportResult, error) {
+	ctx, span := startCall(ctx, "ImportChain")
+	result, err := t.next.ImportChain(ctx, chain, ownership)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedCertificateService) Get(ctx context.Context, id string) (*model.Certificate, error) {
+	ctx, span := startCall(ctx, "Get", attribute.String("certwatch.certificate.id", id))
+	result, err := t.next.Get(ctx, id)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedCertificateService) GetChain(ctx context.Context, id string) ([]model.Certificate, error) {
+	ctx, span := startCall(ctx, "GetChain", attribute.String("certwatch.certificate.id", id))
+	result, err := t.next.GetChain(ctx, id)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedCertificateService) List(ctx context.Context, input dto.ListCertificatesInput) (*CertificatePage, error) {
+	ctx, span := startCall(ctx, "List")
+	result, err := t.next.List(ctx, input)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedCertificateService) ListExpiring(ctx context.Context, window time.Duration, expiryOption ExpiryOption) ([]model.Certificate, error) {
+	ctx, span := startCall(ctx, "ListExpiring")
+	result, err := t.next.ListExpiring(ctx, window, expiryOption)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedCertificateService) ListIssuedBy(ctx context.Context, id string) ([]model.Certificate, error) {
+	ctx, span := startCall(ctx, "ListIssuedBy", attribute.String("certwatch.certificate.id", id))
+	result, err := t.next.ListIssuedBy(ctx, id)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedCertificateService) Search(ctx context.Context, query string, limit int) ([]model.SearchResult, error) {
+	ctx, span := startCall(ctx, "Search")
+	result, err := t.next.Search(ctx, query, limit)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedCertificateService) Summarize(ctx context.Context, input dto.ListCertificatesInput) (*model.CryptoSummary, error) {
+	ctx, span := startCall(ctx, "Summarize")
+	result, err := t.next.Summarize(ctx, input)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedCertificateService) SummarizeExpiry(ctx context.Context, windows []time.Duration) (*model.ExpirySummary, error) {
+	ctx, span := startCall(ctx, "SummarizeExpiry")
+	result, err := t.next.SummarizeExpiry(ctx, windows)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedCertificateService) Update(ctx context.Context, id string, input dto.UpdateCertificateInput) (*model.Certificate, error) {
+	ctx, span := startCall(ctx, "Update", attribute.String("certwatch.certificate.id", id))
+	result, err := t.next.Update(ctx, id, input)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedCertificateService) Delete(ctx context.Context, id string) error {
+	ctx, span := startCall(ctx, "Delete", attribute.String("certwatch.certificate.id", id))
+	err := t.next.Delete(ctx, id)
+	tracing.End(span, err)
+	return err
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+// maxScanErrorLength matches the last_error column constraint.
+const maxScanErrorLength = 255
+
+type EndpointService interface {
+	Create(ctx context.Context, input dto.CreateEndpointInput) (*model.Endpoint, error)
+	List(ctx context.Context) ([]model.Endpoint, error)
+	Delete(ctx context.Context, id string) error
+	RecordScan(ctx context.Context, id string, result model.ScanResult) error
+}
+
+type endpointService struct {
+	repo  repository.EndpointRepository
+	audit repository.AuditRepository
+	clock Clock
+}
+
+// NewEndpointService creates the endpoint service. Changes refused to the
+// caller are recorded in audit unless it is nil.
+func NewEndpointService(repo repository.EndpointRepository, audit repository.AuditRepository) EndpointService {
+	return &endpointService{repo: repo, audit: audit, clock: NewClock()}
+}
+
+func (es *endpointService) Create(ctx context.Context, input dto.CreateEndpointInput) (*model.Endpoint, error) {
+
+	host := strings.ToLower(strings.TrimSpace(input.Host))
+	serverName := strings.ToLower(strings.TrimSpace(input.ServerName))
+	if serverName == "" {
+		serverName = host
+	}
+	if !validHostname(host) || !validHostname(serverName) {
+		return nil, ErrInvalidInput
+	}
+	if input.Port < 1 || input.Port > 65535 {
+		return nil, ErrInvalidInput
+	}
+	ownerTeam := strings.TrimSpace(input.OwnerTeam)
+	if len(ownerTeam) > maxOwnerTeamLength {
+		return nil, ErrInvalidInput
+	}
+	if err := authorize(ctx, es.audit, model.AuditEntityEndpoint, model.AuditEndpointCreate, "", ownerTeam); err != nil {
+		return nil, err
+	}
+
+	endpoint := model.Endpoint{
+		Id:         uuid.NewString(),
+		Host:       host,
+		Port:       input.Port,
+		ServerName: serverName,
+		OwnerTeam:  ownerTeam,
+		CreatedAt:  es.clock.Now().UTC(),
+	}
+
+	err := es.repo.Create(ctx, &endpoint)
+	if err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return nil, repository.ErrConflict
+		}
+		return nil, fmt.Errorf("Creating endpoint: %w", err)
+	}
+
+	return &endpoint, nil
+}
+
+func (es *endpointService) List(ctx context.Context) ([]model.Endpoint, error) {
+	endpoints, err := es.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Getting endpoints: %w", err)
+	}
+
+	return endpoints, nil
+}
+
+func (es *endpointService) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return ErrInvalidInput
+	}
+	endpoint, err := es.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return repository.ErrNotFound
+		}
+		return fmt.Errorf("Deleting endpoint: %w", err)
+	}
+	if err := authorize(ctx, es.audit, model.AuditEntityEndpoint, model.AuditEndpointDelete, id, endpoint.OwnerTeam); err != nil {
+		return err
+	}
+
+	err = es.repo.Delete(ctx, id, endpoint.OwnerTeam)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return repository.ErrNotFound
+		}
+		if errors.Is(err, repository.ErrStaleVersion) {
+			return repository.ErrStaleVersion
+		}
+		return fmt.Errorf("Deleting endpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (es *endpointService) RecordScan(ctx context.Context, id string, result model.ScanResult) error {
+	if id == "" {
+		return ErrInvalidInput
+	}
+	if len(result.Error) > maxScanErrorLength {
+		result.Error = result.Error[:maxScanErrorLength]
+	}
+	result.ScannedAt = result.ScannedAt.UTC()
+
+	err := es.repo.RecordScan(ctx, id, result)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return repository.ErrNotFound
+		}
+		return fmt.Errorf("Recording scan: %w", err)
+	}
+
+	return nil
+}
+
+// validHostname accepts DNS names and IP literals, and nothing that could be
+// mistaken for a URL or a host:port pair.
+func validHostname(host string) bool {
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	if strings.Contains(host, ":") {
+		return net.ParseIP(host) != nil
+	}
+	for _, r := range host {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
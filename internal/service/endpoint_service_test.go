@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hytonhan/certwatch/internal/auth"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+// FakeEndpointRepo has id1, owned by payments, and id2, without an owner.
+type FakeEndpointRepo struct {
+	deleted []string
+}
+
+func (fer *FakeEndpointRepo) Create(ctx context.Context, endpoint *model.Endpoint) error {
+	return nil
+}
+
+func (fer *FakeEndpointRepo) List(ctx context.Context) ([]model.Endpoint, error) {
+	return []model.Endpoint{}, nil
+}
+
+func (fer *FakeEndpointRepo) Get(ctx context.Context, id string) (*model.Endpoint, error) {
+	switch id {
+	case "id1":
+		return &model.Endpoint{Id: id, Host: "payments.example.com", Port: 443, OwnerTeam: "payments"}, nil
+	case "id2":
+		return &model.Endpoint{Id: id, Host: "shared.example.com", Port: 443}, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (fer *FakeEndpointRepo) Delete(ctx context.Context, id string, ownerTeam string) error {
+	fer.deleted = append(fer.deleted, id)
+	return nil
+}
+
+func (fer *FakeEndpointRepo) RecordScan(ctx context.Context, id string, result model.ScanResult) error {
+	return nil
+}
+
+func TestEndpointAuthorization(t *testing.T) {
+	admin := &auth.Principal{Kind: auth.KindAPIKey, Name: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}}
+	editor := &auth.Principal{Kind: auth.KindAPIKey, Name: "payments", Scopes: []auth.Scope{auth.ScopeCertsWrite}, Team: "payments"}
+	otherEditor := &auth.Principal{Kind: auth.KindAPIKey, Name: "billing", Scopes: []auth.Scope{auth.ScopeCertsWrite}, Team: "billing"}
+	viewer := &auth.Principal{Kind: auth.KindOIDC, Name: "alice", Scopes: []auth.Scope{auth.ScopeCertsRead}, Team: "payments"}
+	create := func(owner string) func(ctx context.Context, srv EndpointService) error {
+		return func(ctx context.Context, srv EndpointService) error {
+			_, err := srv.Create(ctx, dto.CreateEndpointInput{Host: "example.com", Port: 443, OwnerTeam: owner})
+			return err
+		}
+	}
+	remove := func(id string) func(ctx context.Context, srv EndpointService) error {
+		return func(ctx context.Context, srv EndpointService) error {
+			return srv.Delete(ctx, id)
+		}
+	}
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		change    func(ctx context.Context, srv EndpointService) error
+		expected  error
+	}{
+		{"editor creates its team's", editor, create("payments"), nil},
+		{"editor creates unowned", editor, create(""), ErrForbidden},
+		{"editor creates other team's", editor, create("billing"), ErrForbidden},
+		{"viewer creates", viewer, create("payments"), ErrForbidden},
+		{"admin creates unowned", admin, create(""), nil},
+		{"editor deletes its team's", editor, remove("id1"), nil},
+		{"editor of other team deletes", otherEditor, remove("id1"), ErrForbidden},
+		{"editor deletes unowned", editor, remove("id2"), ErrForbidden},
+		{"viewer deletes", viewer, remove("id1"), ErrForbidden},
+		{"admin deletes unowned", admin, remove("id2"), nil},
+		{"ErrNotFound bubbles", admin, remove("id3"), repository.ErrNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := &FakeEndpointRepo{}
+			audit := &FakeAuditRepo{}
+			srv := NewEndpointService(repo, audit)
+			ctx := auth.WithPrincipal(context.Background(), test.principal)
+
+			err := test.change(ctx, srv)
+			if !errors.Is(err, test.expected) {
+				t.Errorf("change error = %v; want %v", err, test.expected)
+			}
+			denied := errors.Is(test.expected, ErrForbidden)
+			if denied != (len(audit.denials) == 1) {
+				t.Errorf("denials = %+v; want one only if forbidden", audit.denials)
+			}
+			if denied && len(repo.deleted) > 0 {
+				t.Errorf("deleted = %v; want nothing when forbidden", repo.deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hytonhan/certwatch/internal/lint"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+const (
+	defaultFindingLimit = 100
+	maxFindingLimit     = 1000
+	// lintBatchSize is how many certificates LintAll reads at a time.
+	lintBatchSize = 500
+)
+
+// FindingReport is the aggregated view of the findings: how many
+// certificates violate each rule, and the findings themselves.
+type FindingReport struct {
+	Counts []model.FindingCount
+	Items  []model.Finding
+}
+
+type FindingService interface {
+	// Lint evaluates the certificate and stores its current findings.
+	Lint(ctx context.Context, cert model.Certificate) error
+	// LintAll evaluates every certificate and returns how many there were.
+	LintAll(ctx context.Context) (int, error)
+	ListForCertificate(ctx context.Context, id string) ([]model.Finding, error)
+	List(ctx context.Context, input dto.ListFindingsInput) (*FindingReport, error)
+}
+
+type findingService struct {
+	repo   repository.FindingRepository
+	certs  repository.CertificateRepository
+	linter *lint.Linter
+	clock  Clock
+}
+
+func NewFindingService(repo repository.FindingRepository, certs repository.CertificateRepository, linter *lint.Linter) FindingService {
+	return &findingService{repo: repo, certs: certs, linter: linter, clock: NewClock()}
+}
+
+func (fs *findingService) Lint(ctx context.Context, cert model.Certificate) error {
+	err := fs.repo.Replace(ctx, cert.Id, fs.linter.Lint(cert), fs.clock.Now().UTC())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return repository.ErrNotFound
+		}
+		return fmt.Errorf("Linting cert: %w", err)
+	}
+
+	return nil
+}
+
+// LintAll walks the whole inventory in pages. Certificates deleted while it
+// runs are skipped.
+func (fs *findingService) LintAll(ctx context.Context) (int, error) {
+	page := repository.Page{Sort: repository.SortCreatedAt, Limit: lintBatchSize}
+	count := 0
+	for {
+		certs, err := fs.certs.List(ctx, repository.CertificateFilter{}, page)
+		if err != nil {
+			return count, fmt.Errorf("Linting certs: %w", err)
+		}
+		for _, cert := range certs {
+			if err := fs.Lint(ctx, cert); err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return count, err
+			}
+			count++
+		}
+		if len(certs) < lintBatchSize {
+			return count, nil
+		}
+		after := repository.KeysetOf(certs[len(certs)-1], page.Sort)
+		page.After = &after
+	}
+}
+
+func (fs *findingService) ListForCertificate(ctx context.Context, id string) ([]model.Finding, error) {
+	if id == "" {
+		return nil, ErrInvalidInput
+	}
+	// Resolve the certificate first so an unknown id is a 404 rather than an empty list.
+	if _, err := fs.certs.GetByID(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("Getting findings: %w", err)
+	}
+
+	findings, err := fs.repo.ListByCertificate(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("Getting findings: %w", err)
+	}
+
+	return findings, nil
+}
+
+func (fs *findingService) List(ctx context.Context, input dto.ListFindingsInput) (*FindingReport, error) {
+	if len(input.RuleId) > 64 || input.Limit < 0 || input.Limit > maxFindingLimit {
+		return nil, ErrInvalidInput
+	}
+	severity := model.Severity(input.Severity)
+	switch severity {
+	case "", model.SeverityInfo, model.SeverityWarning, model.SeverityHigh, model.SeverityCritical:
+	default:
+		return nil, ErrInvalidInput
+	}
+	limit := input.Limit
+	if limit == 0 {
+		limit = defaultFindingLimit
+	}
+
+	filter := repository.FindingFilter{RuleId: input.RuleId, Severity: severity}
+	counts, err := fs.repo.Count(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("Getting findings: %w", err)
+	}
+	items, err := fs.repo.List(ctx, filter, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Getting findings: %w", err)
+	}
+
+	return &FindingReport{Counts: counts, Items: items}, nil
+}
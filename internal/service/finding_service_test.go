@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/lint"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+type FakeFindingRepo struct {
+	stored map[string][]model.Finding
+}
+
+func (ffr *FakeFindingRepo) Replace(ctx context.Context, certificateID string, findings []model.Finding, at time.Time) error {
+	ffr.stored[certificateID] = findings
+	return nil
+}
+
+func (ffr *FakeFindingRepo) ListByCertificate(ctx context.Context, certificateID string) ([]model.Finding, error) {
+	return ffr.stored[certificateID], nil
+}
+
+func (ffr *FakeFindingRepo) List(ctx context.Context, filter repository.FindingFilter, limit int) ([]model.Finding, error) {
+	return []model.Finding{}, nil
+}
+
+func (ffr *FakeFindingRepo) Count(ctx context.Context, filter repository.FindingFilter) ([]model.FindingCount, error) {
+	return []model.FindingCount{}, nil
+}
+
+func newTestFindingService(t *testing.T) (FindingService, *FakeFindingRepo) {
+	t.Helper()
+	linter, err := lint.New(lint.DefaultRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &FakeFindingRepo{stored: map[string][]model.Finding{}}
+	return NewFindingService(repo, FakeCertRepo{}, linter), repo
+}
+
+func TestLintAll(t *testing.T) {
+	srv, repo := newTestFindingService(t)
+
+	count, err := srv.LintAll(context.Background())
+	if err != nil {
+		t.Fatalf("LintAll() error = %v", err)
+	}
+	if count != 3 || len(repo.stored) != 3 {
+		t.Errorf("LintAll() linted %d certs, stored %d; want 3", count, len(repo.stored))
+	}
+	// The fake certificates carry no SANs, which is only known for sure of
+	// the one imported from its PEM.
+	for id, want := range map[string]bool{"id1": false, "id3": true} {
+		found := false
+		for _, finding := range repo.stored[id] {
+			found = found || finding.RuleId == "missing-sans"
+		}
+		if found != want {
+			t.Errorf("LintAll() findings of %s = %+v; want missing-sans %v", id, repo.stored[id], want)
+		}
+	}
+}
+
+func TestCreateLints(t *testing.T) {
+	findings, repo := newTestFindingService(t)
+	srv := New(FakeCertRepo{}, WithFindings(findings))
+
+	cert, err := srv.Create(systemContext(), createInput("", "", "", time.Time{}, time.Time{}, ""))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, ok := repo.stored[cert.Id]; !ok {
+		t.Errorf("Create() did not lint %v", cert.Id)
+	}
+}
+
+func TestListFindings(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    dto.ListFindingsInput
+		expected error
+	}{
+		{"no filter", dto.ListFindingsInput{}, nil},
+		{"rule and severity", dto.ListFindingsInput{RuleId: "sha1-signature", Severity: "high", Limit: 10}, nil},
+		{"unknown severity", dto.ListFindingsInput{Severity: "urgent"}, ErrInvalidInput},
+		{"limit too large", dto.ListFindingsInput{Limit: maxFindingLimit + 1}, ErrInvalidInput},
+	}
+	srv, _ := newTestFindingService(t)
+	ctx := systemContext()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := srv.List(ctx, test.input)
+			if !errors.Is(err, test.expected) {
+				t.Errorf("List() = %v; want %v", err, test.expected)
+			}
+		})
+	}
+}
+
+func TestListForCertificate(t *testing.T) {
+	srv, _ := newTestFindingService(t)
+	ctx := systemContext()
+
+	if _, err := srv.ListForCertificate(ctx, "id1"); err != nil {
+		t.Errorf("ListForCertificate(id1) error = %v", err)
+	}
+	if _, err := srv.ListForCertificate(ctx, "missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("ListForCertificate(missing) error = %v; want %v", err, repository.ErrNotFound)
+	}
+}
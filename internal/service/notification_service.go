@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/repository"
+)
+
+// maxDeliveryErrorLength matches the error column constraint.
+const maxDeliveryErrorLength = 255
+
+// ErrDigestFull means the email digest queue is full. The alert is left
+// undelivered, and queued when it is retried.
+var ErrDigestFull = errors.New("digest_full")
+
+const (
+	// maxDigestItems caps the email digest queue, counting an alert once
+	// per recipient.
+	maxDigestItems = 10000
+	// digestClaimTimeout is how long a flush may hold digest items before
+	// another flush takes them over, e.g. after the replica crashed. It
+	// outlasts a flush sending to every recipient.
+	digestClaimTimeout = 15 * time.Minute
+)
+
+type NotificationService interface {
+	RecordDelivery(ctx context.Context, delivery model.NotificationDelivery) error
+	ListDeliveries(ctx context.Context, success *bool) ([]model.NotificationDelivery, error)
+	// EnqueueDigest queues an alert for the next email digest of each of its
+	// recipients.
+	EnqueueDigest(ctx context.Context, alert model.ExpiryAlert, recipients []string) error
+	// ClaimDigest returns the queued alerts for this flush alone. Each one
+	// must be removed once sent, or released to be sent again.
+	ClaimDigest(ctx context.Context) ([]model.DigestItem, error)
+	RemoveDigest(ctx context.Context, ids []string) error
+	ReleaseDigest(ctx context.Context, ids []string) error
+}
+
+type notificationService struct {
+	repo  repository.NotificationRepository
+	clock Clock
+}
+
+func NewNotificationService(repo repository.NotificationRepository) NotificationService {
+	return &notificationService{repo: repo, clock: NewClock()}
+}
+
+func (ns *notificationService) RecordDelivery(ctx context.Context, delivery model.NotificationDelivery) error {
+	if delivery.Channel == "" || delivery.Target == "" || len(delivery.Channel) > 32 || len(delivery.Target) > 255 {
+		return ErrInvalidInput
+	}
+	if len(delivery.Error) > maxDeliveryErrorLength {
+		delivery.Error = delivery.Error[:maxDeliveryErrorLength]
+	}
+	delivery.Id = uuid.NewString()
+	delivery.CreatedAt = ns.clock.Now().UTC()
+
+	if err := ns.repo.CreateDelivery(ctx, &delivery); err != nil {
+		return fmt.Errorf("Recording delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (ns *notificationService) ListDeliveries(ctx context.Context, success *bool) ([]model.NotificationDelivery, error) {
+	deliveries, err := ns.repo.ListDeliveries(ctx, success)
+	if err != nil {
+		return nil, fmt.Errorf("Getting deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (ns *notificationService) EnqueueDigest(ctx context.Context, alert model.ExpiryAlert, recipients []string) error {
+	if alert.Certificate.Id == "" || alert.Threshold == "" || len(alert.Threshold) > 32 {
+		return ErrInvalidInput
+	}
+	now := ns.clock.Now().UTC()
+	items := make([]model.DigestItem, 0, len(recipients))
+	for _, rcpt := range recipients {
+		if rcpt == "" || len(rcpt) > 255 {
+			return ErrInvalidInput
+		}
+		items = append(items, model.DigestItem{Id: uuid.NewString(), Recipient: rcpt, Alert: alert, CreatedAt: now})
+	}
+
+	if err := ns.repo.EnqueueDigest(ctx, items, maxDigestItems); err != nil {
+		if errors.Is(err, repository.ErrQueueFull) {
+			return ErrDigestFull
+		}
+		return fmt.Errorf("Queueing digest: %w", err)
+	}
+
+	return nil
+}
+
+func (ns *notificationService) ClaimDigest(ctx context.Context) ([]model.DigestItem, error) {
+	now := ns.clock.Now().UTC()
+	items, err := ns.repo.ClaimDigest(ctx, uuid.NewString(), now, now.Add(-digestClaimTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("Claiming digest: %w", err)
+	}
+
+	return items, nil
+}
+
+func (ns *notificationService) RemoveDigest(ctx context.Context, ids []string) error {
+	if err := ns.repo.RemoveDigest(ctx, ids); err != nil {
+		return fmt.Errorf("Removing digest items: %w", err)
+	}
+
+	return nil
+}
+
+func (ns *notificationService) ReleaseDigest(ctx context.Context, ids []string) error {
+	if err := ns.repo.ReleaseDigest(ctx, ids); err != nil {
+		return fmt.Errorf("Releasing digest items: %w", err)
+	}
+
+	return nil
+}
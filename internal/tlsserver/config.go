@@ -0,0 +1,51 @@
+package tlsserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+)
+
+// ClientAuth is whether clients must present a certificate.
+type ClientAuth string
+
+const (
+	// ClientAuthOptional verifies client certificates that are presented and
+	// lets clients without one authenticate with a bearer token.
+	ClientAuthOptional ClientAuth = "optional"
+	// ClientAuthRequire refuses connections without a valid client
+	// certificate.
+	ClientAuthRequire ClientAuth = "require"
+)
+
+// Config returns the server's TLS configuration: TLS 1.2 or newer with the
+// reloader's certificate and, unless clientCAs is nil, client certificates
+// verified against clientCAs.
+func Config(reloader *Reloader, clientCAs *x509.CertPool, clientAuth ClientAuth) *tls.Config {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if clientCAs != nil {
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if clientAuth == ClientAuthRequire {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return config
+}
+
+// LoadCertPool reads a PEM bundle of CA certificates.
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no certificates found")
+	}
+	return pool, nil
+}
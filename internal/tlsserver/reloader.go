@@ -0,0 +1,190 @@
+package tlsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/audit"
+	"github.com/hytonhan/certwatch/internal/auth"
+	"github.com/hytonhan/certwatch/internal/service"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+// Actor is recorded in the audit trail for the serving certificate the server
+// imports into its own inventory.
+const Actor = "system:tls"
+
+// ServingDescription describes the serving certificate in the inventory.
+const ServingDescription = "certwatch API server certificate"
+
+// Reloader serves the certificate and key of a pair of PEM files and picks up
+// new files without a restart, e.g. after a renewal. Every certificate it
+// serves is imported into the inventory, so that its expiry is monitored like
+// any other.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	certs    service.CertificateService
+	logger   *slog.Logger
+
+	mu    sync.RWMutex
+	cert  *tls.Certificate
+	stamp fileStamp
+	// imported is false until the current certificate is in the inventory.
+	imported bool
+}
+
+// fileStamp tells whether the files changed since they were loaded.
+type fileStamp struct {
+	certModTime time.Time
+	certSize    int64
+	keyModTime  time.Time
+	keySize     int64
+}
+
+// NewReloader loads the key pair, which must be valid for the server to
+// start.
+func NewReloader(certFile string, keyFile string, interval time.Duration, certs service.CertificateService, logger *slog.Logger) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, interval: interval, certs: certs, logger: logger}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate serves as tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload loads the key pair if the files changed since it was last loaded and
+// reports whether it did. If loading fails, the current certificate is kept
+// and the next Reload tries again.
+func (r *Reloader) Reload() (bool, error) {
+	stamp, err := stampFiles(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	unchanged := r.cert != nil && stamp == r.stamp
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("Loading key pair: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.stamp = stamp
+	r.imported = false
+	r.mu.Unlock()
+	return true, nil
+}
+
+// Start imports the serving certificate into the inventory and then checks
+// the files for a new one every interval.
+func (r *Reloader) Start(ctx context.Context) {
+
+	r.logger.InfoContext(ctx, "tls reloader started",
+		"interval", r.interval)
+	r.importServing(ctx)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				r.logger.WarnContext(ctx, "reloading tls certificate failed, serving the previous one",
+					"error", err.Error())
+				continue
+			}
+			if reloaded {
+				r.logger.InfoContext(ctx, "reloaded tls certificate")
+			}
+			r.importServing(ctx)
+		}
+	}
+}
+
+// importServing imports the chain of the current certificate unless it
+// already was. A failed import is retried on the next check.
+func (r *Reloader) importServing(ctx context.Context) {
+	r.mu.RLock()
+	cert, imported := r.cert, r.imported
+	r.mu.RUnlock()
+	if imported {
+		return
+	}
+
+	chain, err := parseChain(cert)
+	if err != nil {
+		r.logger.WarnContext(ctx, "parsing tls certificate chain failed")
+		return
+	}
+	ctx = auth.WithPrincipal(ctx, auth.System("tls"))
+	ctx = audit.WithActor(ctx, Actor)
+	results, err := r.certs.ImportChain(ctx, chain, dto.Ownership{Description: ServingDescription})
+	if err != nil {
+		r.logger.WarnContext(ctx, "importing tls certificate failed")
+		return
+	}
+	r.logger.InfoContext(ctx, "monitoring tls certificate",
+		"id", results[0].Certificate.Id,
+		"not_after", results[0].Certificate.NotAfter)
+
+	r.mu.Lock()
+	if r.cert == cert {
+		r.imported = true
+	}
+	r.mu.Unlock()
+}
+
+func parseChain(cert *tls.Certificate) ([]*x509.Certificate, error) {
+	chain := make([]*x509.Certificate, 0, len(cert.Certificate))
+	for _, der := range cert.Certificate {
+		parsed, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, parsed)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("empty chain")
+	}
+	return chain, nil
+}
+
+func stampFiles(certFile string, keyFile string) (fileStamp, error) {
+	certInfo, err := os.Stat(certFile)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	keyInfo, err := os.Stat(keyFile)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{
+		certModTime: certInfo.ModTime(),
+		certSize:    certInfo.Size(),
+		keyModTime:  keyInfo.ModTime(),
+		keySize:     keyInfo.Size(),
+	}, nil
+}
@@ -0,0 +1,147 @@
+package tlsserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hytonhan/certwatch/internal/audit"
+	"github.com/hytonhan/certwatch/internal/model"
+	"github.com/hytonhan/certwatch/internal/service"
+	dto "github.com/hytonhan/certwatch/internal/service/DTO"
+)
+
+type FakeCertService struct {
+	service.CertificateService
+	imported  [][]*x509.Certificate
+	ownership []dto.Ownership
+	actors    []string
+}
+
+func (fcs *FakeCertService) ImportChain(ctx context.Context, chain []*x509.Certificate, ownership dto.Ownership) ([]service.ImportResult, error) {
+	fcs.imported = append(fcs.imported, chain)
+	fcs.ownership = append(fcs.ownership, ownership)
+	fcs.actors = append(fcs.actors, audit.ActorFrom(ctx))
+	return []service.ImportResult{{Certificate: model.Certificate{Id: chain[0].SerialNumber.String(), NotAfter: chain[0].NotAfter}, Created: true}}, nil
+}
+
+// writeKeyPair writes a self-signed certificate with the serial and its key,
+// both last modified at modTime.
+func writeKeyPair(t *testing.T, certFile string, keyFile string, serial int64, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "certwatch.example.com"},
+		DNSNames:     []string{"certwatch.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Writes within the file system's timestamp granularity would look
+	// unchanged.
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func servedSerial(t *testing.T, reloader *Reloader) int64 {
+	t.Helper()
+	cert, err := reloader.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.Leaf.SerialNumber.Int64()
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Hour)
+	writeKeyPair(t, certFile, keyFile, 1, start)
+	certs := &FakeCertService{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	reloader, err := NewReloader(certFile, keyFile, time.Minute, certs, logger)
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	if serial := servedSerial(t, reloader); serial != 1 {
+		t.Fatalf("served serial = %d; want 1", serial)
+	}
+
+	if reloaded, err := reloader.Reload(); reloaded || err != nil {
+		t.Errorf("Reload() of unchanged files = %v, %v; want false, nil", reloaded, err)
+	}
+
+	writeKeyPair(t, certFile, keyFile, 2, start.Add(time.Minute))
+	if reloaded, err := reloader.Reload(); !reloaded || err != nil {
+		t.Fatalf("Reload() of new files = %v, %v; want true, nil", reloaded, err)
+	}
+	if serial := servedSerial(t, reloader); serial != 2 {
+		t.Errorf("served serial = %d; want 2", serial)
+	}
+
+	// A half-written renewal keeps the previous certificate.
+	os.WriteFile(keyFile, []byte("not a key"), 0o600)
+	if reloaded, err := reloader.Reload(); reloaded || err == nil {
+		t.Errorf("Reload() of a broken key = %v, %v; want false and an error", reloaded, err)
+	}
+	if serial := servedSerial(t, reloader); serial != 2 {
+		t.Errorf("served serial = %d; want 2", serial)
+	}
+}
+
+func TestImportServing(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeKeyPair(t, certFile, keyFile, 7, time.Now())
+	certs := &FakeCertService{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reloader, err := NewReloader(certFile, keyFile, time.Minute, certs, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloader.importServing(context.Background())
+	reloader.importServing(context.Background())
+
+	if len(certs.imported) != 1 {
+		t.Fatalf("imported %d chains; want 1", len(certs.imported))
+	}
+	if certs.imported[0][0].SerialNumber.Int64() != 7 {
+		t.Errorf("imported serial = %v; want 7", certs.imported[0][0].SerialNumber)
+	}
+	if certs.actors[0] != Actor || certs.ownership[0].Description != ServingDescription {
+		t.Errorf("imported as %q with %+v; want %q", certs.actors[0], certs.ownership[0], Actor)
+	}
+}
@@ -0,0 +1,95 @@
+// Package tracing sets up OpenTelemetry tracing. Instrumented code gets its
+// tracer from the global provider, which does nothing until Setup replaces
+// it, so tracing costs next to nothing when it is off.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name is the instrumentation scope of certwatch's spans.
+const Name = "github.com/hytonhan/certwatch"
+
+type Config struct {
+	// Endpoint is the base URL of an OTLP/HTTP collector, e.g.
+	// http://collector:4318; spans are posted to its /v1/traces. Empty
+	// disables exporting.
+	Endpoint    string
+	ServiceName string
+	// SampleRatio is the share of traces recorded. Requests carrying a
+	// traceparent stay in the caller's trace, but are sampled by the ratio
+	// too unless TrustParentSampling is set: otherwise any client could have
+	// every one of its requests recorded, or none.
+	SampleRatio float64
+	// TrustParentSampling follows the sampling decision of the traceparent,
+	// for servers only reached through a proxy or services that set it.
+	TrustParentSampling bool
+}
+
+// Setup propagates W3C trace context in and out and, unless the endpoint is
+// empty, exports spans. The returned function flushes the spans still
+// buffered and must be called before exiting.
+func Setup(ctx context.Context, config Config) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if config.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx,
+		otlptracehttp.WithEndpointURL(strings.TrimSuffix(config.Endpoint, "/")+"/v1/traces"))
+	if err != nil {
+		return nil, fmt.Errorf("Creating trace exporter: %w", err)
+	}
+	provider := NewProvider(config, sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewProvider returns a provider of the service's spans, handed to the span
+// processor, e.g. an in-memory exporter in tests.
+func NewProvider(config Config, processor sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		processor,
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", config.ServiceName))),
+		sdktrace.WithSampler(sampler(config)),
+	)
+}
+
+// sampler samples by the ratio and follows the decision of local parents, so
+// that a trace is recorded whole. The ratio is applied to the trace id, so
+// services sampling at the same ratio still agree on a trace.
+func sampler(config Config) sdktrace.Sampler {
+	ratio := sdktrace.TraceIDRatioBased(config.SampleRatio)
+	if config.TrustParentSampling {
+		return sdktrace.ParentBased(ratio)
+	}
+	return sdktrace.ParentBased(ratio,
+		sdktrace.WithRemoteParentSampled(ratio),
+		sdktrace.WithRemoteParentNotSampled(ratio))
+}
+
+// Tracer returns certwatch's tracer from the global provider. It is looked
+// up on every use so that a provider set later, e.g. in tests, takes effect.
+func Tracer() trace.Tracer {
+	return otel.Tracer(Name)
+}
+
+// End records the error, if any, on the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
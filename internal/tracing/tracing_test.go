@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSamplerIgnoresRemoteDecision(t *testing.T) {
+	remote := func(sampled bool) context.Context {
+		traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+		flags := trace.TraceFlags(0).WithSampled(sampled)
+		return trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID, SpanID: spanID, TraceFlags: flags, Remote: true,
+		}))
+	}
+	tests := []struct {
+		name    string
+		config  Config
+		sampled bool
+		want    bool
+	}{
+		{"sampled parent below the ratio", Config{SampleRatio: 0}, true, false},
+		{"unsampled parent within the ratio", Config{SampleRatio: 1}, false, true},
+		{"trusted sampled parent", Config{SampleRatio: 0, TrustParentSampling: true}, true, true},
+		{"trusted unsampled parent", Config{SampleRatio: 1, TrustParentSampling: true}, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := NewProvider(test.config, sdktrace.WithSyncer(tracetest.NewInMemoryExporter()))
+			_, span := provider.Tracer(Name).Start(remote(test.sampled), "request")
+			defer span.End()
+			if got := span.SpanContext().IsSampled(); got != test.want {
+				t.Errorf("sampled = %v; want %v", got, test.want)
+			}
+			if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("trace id = %s; want the parent's", span.SpanContext().TraceID())
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+ALTER TABLE certificates ADD COLUMN subject_key_id TEXT NOT NULL DEFAULT '' CHECK(length(subject_key_id) <= 128);
+ALTER TABLE certificates ADD COLUMN authority_key_id TEXT NOT NULL DEFAULT '' CHECK(length(authority_key_id) <= 128);
+ALTER TABLE certificates ADD COLUMN issuer_id TEXT REFERENCES certificates(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_cert_issuer_id
+ON certificates(issuer_id);
+
+CREATE INDEX IF NOT EXISTS idx_cert_subject_key_id
+ON certificates(subject_key_id);
+
+CREATE INDEX IF NOT EXISTS idx_cert_authority_key_id
+ON certificates(authority_key_id);
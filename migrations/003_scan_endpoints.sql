@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS scan_endpoints (
+    id TEXT PRIMARY KEY,
+    host TEXT NOT NULL CHECK(length(host) <= 253),
+    port INTEGER NOT NULL CHECK(port BETWEEN 1 AND 65535),
+    server_name TEXT NOT NULL CHECK(length(server_name) <= 253),
+    created_at DATETIME NOT NULL,
+    last_scanned_at DATETIME,
+    handshake_failed INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '' CHECK(length(last_error) <= 255),
+    last_fingerprint TEXT NOT NULL DEFAULT '',
+    UNIQUE(host, port, server_name)
+);
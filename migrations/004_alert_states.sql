@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS alert_states (
+    id TEXT PRIMARY KEY,
+    certificate_id TEXT NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    threshold TEXT NOT NULL CHECK(length(threshold) <= 32),
+    first_alerted_at DATETIME NOT NULL,
+    last_alerted_at DATETIME NOT NULL,
+    acknowledged INTEGER NOT NULL DEFAULT 0,
+    acknowledged_at DATETIME,
+    UNIQUE(certificate_id, threshold)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_states_acknowledged
+ON alert_states(acknowledged);
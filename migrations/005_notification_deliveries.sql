@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id TEXT PRIMARY KEY,
+    certificate_id TEXT REFERENCES certificates(id) ON DELETE SET NULL,
+    channel TEXT NOT NULL CHECK(length(channel) <= 32),
+    target TEXT NOT NULL CHECK(length(target) <= 255),
+    threshold TEXT NOT NULL CHECK(length(threshold) <= 32),
+    attempt INTEGER NOT NULL,
+    success INTEGER NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '' CHECK(length(error) <= 255),
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_created_at
+ON notification_deliveries(created_at);
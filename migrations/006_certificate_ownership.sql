@@ -0,0 +1,6 @@
+ALTER TABLE certificates ADD COLUMN owner_team TEXT NOT NULL DEFAULT '' CHECK(length(owner_team) <= 64);
+ALTER TABLE certificates ADD COLUMN contact_email TEXT NOT NULL DEFAULT '' CHECK(length(contact_email) <= 254);
+ALTER TABLE certificates ADD COLUMN description TEXT NOT NULL DEFAULT '' CHECK(length(description) <= 1024);
+
+CREATE INDEX IF NOT EXISTS idx_cert_owner_team
+ON certificates(owner_team);
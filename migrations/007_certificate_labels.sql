@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS certificate_labels (
+    certificate_id TEXT NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    key TEXT NOT NULL CHECK(length(key) <= 317),
+    value TEXT NOT NULL CHECK(length(value) <= 63),
+    PRIMARY KEY(certificate_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_certificate_labels_key_value
+ON certificate_labels(key, value);
@@ -0,0 +1,2 @@
+ALTER TABLE certificates ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE certificates ADD COLUMN notes TEXT NOT NULL DEFAULT '' CHECK(length(notes) <= 4096);
@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS idx_cert_not_after_id ON certificates(not_after, id);
+CREATE INDEX IF NOT EXISTS idx_cert_not_before_id ON certificates(not_before, id);
+CREATE INDEX IF NOT EXISTS idx_cert_created_at_id ON certificates(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_cert_common_name_id ON certificates(common_name, id);
+CREATE INDEX IF NOT EXISTS idx_cert_issuer_id ON certificates(issuer, id);
@@ -0,0 +1,34 @@
+CREATE VIRTUAL TABLE IF NOT EXISTS certificate_search USING fts5(
+    common_name,
+    issuer,
+    sans,
+    owner,
+    notes,
+    description
+);
+
+INSERT INTO certificate_search (rowid, common_name, issuer, sans, owner, notes, description)
+SELECT rowid, common_name, issuer, '', trim(owner_team || ' ' || contact_email), notes, description
+FROM certificates;
+
+CREATE TRIGGER IF NOT EXISTS certificate_search_insert AFTER INSERT ON certificates
+BEGIN
+    INSERT INTO certificate_search (rowid, common_name, issuer, sans, owner, notes, description)
+    VALUES (new.rowid, new.common_name, new.issuer, '', trim(new.owner_team || ' ' || new.contact_email), new.notes, new.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS certificate_search_update AFTER UPDATE OF common_name, issuer, owner_team, contact_email, notes, description ON certificates
+BEGIN
+    UPDATE certificate_search
+    SET common_name = new.common_name,
+        issuer = new.issuer,
+        owner = trim(new.owner_team || ' ' || new.contact_email),
+        notes = new.notes,
+        description = new.description
+    WHERE rowid = new.rowid;
+END;
+
+CREATE TRIGGER IF NOT EXISTS certificate_search_delete AFTER DELETE ON certificates
+BEGIN
+    DELETE FROM certificate_search WHERE rowid = old.rowid;
+END;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS certificate_sans (
+    certificate_id TEXT NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK(type IN ('dns', 'ip', 'uri', 'email')),
+    value TEXT NOT NULL CHECK(length(value) <= 2048),
+    PRIMARY KEY(certificate_id, type, value)
+);
+
+CREATE INDEX IF NOT EXISTS idx_certificate_sans_type_value
+ON certificate_sans(type, value);
+
+CREATE TRIGGER IF NOT EXISTS certificate_sans_search_insert AFTER INSERT ON certificate_sans
+BEGIN
+    UPDATE certificate_search
+    SET sans = (SELECT group_concat(value, ' ') FROM certificate_sans WHERE certificate_id = new.certificate_id)
+    WHERE rowid = (SELECT rowid FROM certificates WHERE id = new.certificate_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS certificate_sans_search_delete AFTER DELETE ON certificate_sans
+BEGIN
+    UPDATE certificate_search
+    SET sans = coalesce((SELECT group_concat(value, ' ') FROM certificate_sans WHERE certificate_id = old.certificate_id), '')
+    WHERE rowid = (SELECT rowid FROM certificates WHERE id = old.certificate_id);
+END;
@@ -0,0 +1,12 @@
+ALTER TABLE certificates ADD COLUMN key_algorithm TEXT NOT NULL DEFAULT '' CHECK(length(key_algorithm) <= 64);
+ALTER TABLE certificates ADD COLUMN key_size INTEGER NOT NULL DEFAULT 0 CHECK(key_size >= 0);
+ALTER TABLE certificates ADD COLUMN key_curve TEXT NOT NULL DEFAULT '' CHECK(length(key_curve) <= 64);
+ALTER TABLE certificates ADD COLUMN signature_algorithm TEXT NOT NULL DEFAULT '' CHECK(length(signature_algorithm) <= 64);
+ALTER TABLE certificates ADD COLUMN key_usage TEXT NOT NULL DEFAULT '[]' CHECK(json_valid(key_usage));
+ALTER TABLE certificates ADD COLUMN ext_key_usage TEXT NOT NULL DEFAULT '[]' CHECK(json_valid(ext_key_usage));
+ALTER TABLE certificates ADD COLUMN is_ca INTEGER NOT NULL DEFAULT 0 CHECK(is_ca IN (0, 1));
+
+UPDATE certificates SET is_ca = 1 WHERE id IN (SELECT issuer_id FROM certificates WHERE issuer_id IS NOT NULL AND issuer_id != id);
+
+CREATE INDEX idx_cert_key_algorithm ON certificates(key_algorithm, key_size);
+CREATE INDEX idx_cert_signature_algorithm ON certificates(signature_algorithm);
@@ -0,0 +1,14 @@
+ALTER TABLE certificates ADD COLUMN self_signed INTEGER NOT NULL DEFAULT 0 CHECK(self_signed IN (0, 1));
+
+CREATE TABLE IF NOT EXISTS certificate_findings (
+    certificate_id TEXT NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    rule_id TEXT NOT NULL CHECK(length(rule_id) <= 64),
+    severity TEXT NOT NULL CHECK(severity IN ('info', 'warning', 'high', 'critical')),
+    message TEXT NOT NULL CHECK(length(message) <= 1024),
+    first_seen_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    PRIMARY KEY (certificate_id, rule_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_certificate_findings_rule
+ON certificate_findings(rule_id, severity);
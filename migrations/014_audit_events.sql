@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    occurred_at DATETIME NOT NULL,
+    actor TEXT NOT NULL CHECK(length(actor) <= 256),
+    request_id TEXT NOT NULL DEFAULT '' CHECK(length(request_id) <= 64),
+    action TEXT NOT NULL CHECK(length(action) <= 64),
+    entity_type TEXT NOT NULL CHECK(length(entity_type) <= 32),
+    entity_id TEXT NOT NULL CHECK(length(entity_id) <= 64),
+    before TEXT CHECK(before IS NULL OR json_valid(before)),
+    after TEXT CHECK(after IS NULL OR json_valid(after))
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_entity ON audit_events(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor);
+CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON audit_events(occurred_at);
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
@@ -0,0 +1,19 @@
+ALTER TABLE audit_events ADD COLUMN prev_hash TEXT NOT NULL DEFAULT '' CHECK(prev_hash = '' OR length(prev_hash) = 64);
+ALTER TABLE audit_events ADD COLUMN hash TEXT NOT NULL DEFAULT '' CHECK(hash = '' OR length(hash) = 64);
+
+DROP TRIGGER IF EXISTS audit_events_no_update;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+WHEN OLD.hash != ''
+    OR NEW.id IS NOT OLD.id
+    OR NEW.occurred_at IS NOT OLD.occurred_at
+    OR NEW.actor IS NOT OLD.actor
+    OR NEW.request_id IS NOT OLD.request_id
+    OR NEW.action IS NOT OLD.action
+    OR NEW.entity_type IS NOT OLD.entity_type
+    OR NEW.entity_id IS NOT OLD.entity_id
+    OR NEW.before IS NOT OLD.before
+    OR NEW.after IS NOT OLD.after
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE CHECK(length(name) <= 64),
+    prefix TEXT NOT NULL UNIQUE CHECK(length(prefix) = 12),
+    secret_hash TEXT NOT NULL CHECK(length(secret_hash) <= 256),
+    scopes TEXT NOT NULL CHECK(json_valid(scopes) AND json_type(scopes) = 'array'),
+    created_at DATETIME NOT NULL,
+    last_used_at DATETIME,
+    revoked_at DATETIME
+);
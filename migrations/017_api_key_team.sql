@@ -0,0 +1 @@
+ALTER TABLE api_keys ADD COLUMN team TEXT NOT NULL DEFAULT '' CHECK(length(team) <= 64);
@@ -0,0 +1,3 @@
+-- 009 names this index idx_cert_issuer_id, which 002 already uses for the
+-- issuer_id index, so 009 never created it.
+CREATE INDEX IF NOT EXISTS idx_cert_issuer_name_id ON certificates(issuer, id);
@@ -0,0 +1,5 @@
+-- Alerts stored before delivery was tracked were sent, or given up on.
+ALTER TABLE alert_states ADD COLUMN delivered INTEGER NOT NULL DEFAULT 1 CHECK(delivered IN (0, 1));
+
+CREATE INDEX IF NOT EXISTS idx_alert_states_delivered
+ON alert_states(delivered);
@@ -0,0 +1,16 @@
+-- Alerts waiting for the next email digest, one row per recipient, so that
+-- they outlive a restart and each recipient's digest is retried on its own.
+CREATE TABLE IF NOT EXISTS email_digest_items (
+    id TEXT PRIMARY KEY,
+    certificate_id TEXT NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    recipient TEXT NOT NULL CHECK(length(recipient) <= 255),
+    threshold TEXT NOT NULL CHECK(length(threshold) <= 32),
+    severity TEXT NOT NULL CHECK(length(severity) <= 16),
+    created_at DATETIME NOT NULL,
+    claimed_by TEXT,
+    claimed_at DATETIME,
+    UNIQUE(certificate_id, threshold, recipient)
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_digest_items_claimed_at
+ON email_digest_items(claimed_at);
@@ -0,0 +1,3 @@
+-- The PEM of certificates imported from the certificate itself. Certificates
+-- registered by hand, or imported before the PEM was kept, have none.
+ALTER TABLE certificates ADD COLUMN pem TEXT NOT NULL DEFAULT '' CHECK(length(pem) <= 65536);
@@ -0,0 +1,3 @@
+-- The team owning an endpoint, which its editors may delete. Endpoints added
+-- before endpoints had owners have none and only admins may delete them.
+ALTER TABLE scan_endpoints ADD COLUMN owner_team TEXT NOT NULL DEFAULT '' CHECK(length(owner_team) <= 64);
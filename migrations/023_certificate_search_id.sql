@@ -0,0 +1,61 @@
+-- 010 keyed the search index on the rowid of certificates, which has no
+-- INTEGER PRIMARY KEY, so VACUUM may renumber it and leave the index pointing
+-- at other certificates. The index is rebuilt keyed on the certificate id.
+DROP TRIGGER IF EXISTS certificate_search_insert;
+DROP TRIGGER IF EXISTS certificate_search_update;
+DROP TRIGGER IF EXISTS certificate_search_delete;
+DROP TRIGGER IF EXISTS certificate_sans_search_insert;
+DROP TRIGGER IF EXISTS certificate_sans_search_delete;
+DROP TABLE IF EXISTS certificate_search;
+
+CREATE VIRTUAL TABLE certificate_search USING fts5(
+    common_name,
+    issuer,
+    sans,
+    owner,
+    notes,
+    description,
+    certificate_id UNINDEXED
+);
+
+INSERT INTO certificate_search (common_name, issuer, sans, owner, notes, description, certificate_id)
+SELECT common_name, issuer,
+    coalesce((SELECT group_concat(value, ' ') FROM certificate_sans WHERE certificate_id = certificates.id), ''),
+    trim(owner_team || ' ' || contact_email), notes, description, id
+FROM certificates;
+
+CREATE TRIGGER certificate_search_insert AFTER INSERT ON certificates
+BEGIN
+    INSERT INTO certificate_search (common_name, issuer, sans, owner, notes, description, certificate_id)
+    VALUES (new.common_name, new.issuer, '', trim(new.owner_team || ' ' || new.contact_email), new.notes, new.description, new.id);
+END;
+
+CREATE TRIGGER certificate_search_update AFTER UPDATE OF common_name, issuer, owner_team, contact_email, notes, description ON certificates
+BEGIN
+    UPDATE certificate_search
+    SET common_name = new.common_name,
+        issuer = new.issuer,
+        owner = trim(new.owner_team || ' ' || new.contact_email),
+        notes = new.notes,
+        description = new.description
+    WHERE certificate_id = new.id;
+END;
+
+CREATE TRIGGER certificate_search_delete AFTER DELETE ON certificates
+BEGIN
+    DELETE FROM certificate_search WHERE certificate_id = old.id;
+END;
+
+CREATE TRIGGER certificate_sans_search_insert AFTER INSERT ON certificate_sans
+BEGIN
+    UPDATE certificate_search
+    SET sans = (SELECT group_concat(value, ' ') FROM certificate_sans WHERE certificate_id = new.certificate_id)
+    WHERE certificate_id = new.certificate_id;
+END;
+
+CREATE TRIGGER certificate_sans_search_delete AFTER DELETE ON certificate_sans
+BEGIN
+    UPDATE certificate_search
+    SET sans = coalesce((SELECT group_concat(value, ' ') FROM certificate_sans WHERE certificate_id = old.certificate_id), '')
+    WHERE certificate_id = old.certificate_id;
+END;
@@ -0,0 +1,4 @@
+-- 012 creates these indexes without IF NOT EXISTS. Like the other index
+-- migrations, make sure they exist without failing when they already do.
+CREATE INDEX IF NOT EXISTS idx_cert_key_algorithm ON certificates(key_algorithm, key_size);
+CREATE INDEX IF NOT EXISTS idx_cert_signature_algorithm ON certificates(signature_algorithm);